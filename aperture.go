@@ -0,0 +1,97 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+const (
+	APERTURE_ICON_BLADES     = 6
+	APERTURE_ICON_OPEN_F     = 1.0  // 絞りが全開となるF値
+	APERTURE_ICON_MIN_RATIO  = 0.12 // 絞り込んだときの開口の最小半径 (外周に対する比)
+	APERTURE_ICON_SAMPLES    = 4    // アンチエイリアスのためのサンプル数 (1辺あたり)
+	APERTURE_ICON_LINE_RATIO = 0.04 // 羽根の境界線の太さ (外周に対する比)
+)
+
+// 開口の半径の外周に対する比 (F値に反比例する)
+func apertureOpening(fNumber float64) float64 {
+	return math.Max(APERTURE_ICON_MIN_RATIO, math.Min(1, APERTURE_ICON_OPEN_F/fNumber))
+}
+
+// 絞りの羽根の形のマスクを作成する
+// 外周の円から開口 (正多角形) を除いた部分を羽根とし、羽根の境界線は抜いて描く
+func apertureMask(size int, fNumber float64) *image.Alpha {
+	mask := image.NewAlpha(image.Rect(0, 0, size, size))
+
+	outer := float64(size) / 2
+	inner := outer * apertureOpening(fNumber)
+	lineWidth := math.Max(1, outer*APERTURE_ICON_LINE_RATIO)
+
+	sector := 2 * math.Pi / APERTURE_ICON_BLADES
+	apothem := inner * math.Cos(sector/2)
+
+	// 開口の頂点と、羽根の境界線の向き (頂点から次の頂点への向き)
+	var vertices, directions [APERTURE_ICON_BLADES][2]float64
+	for k := range vertices {
+		a := sector * float64(k)
+		vertices[k] = [2]float64{inner * math.Cos(a), inner * math.Sin(a)}
+	}
+	for k := range directions {
+		next := vertices[(k+1)%APERTURE_ICON_BLADES]
+		dx, dy := next[0]-vertices[k][0], next[1]-vertices[k][1]
+		l := math.Hypot(dx, dy)
+		directions[k] = [2]float64{dx / l, dy / l}
+	}
+
+	isBlade := func(x, y float64) bool {
+		r := math.Hypot(x, y)
+		if r > outer {
+			return false
+		}
+
+		// 開口の内側
+		phi := math.Mod(math.Atan2(y, x)+2*math.Pi, sector)
+		if r*math.Cos(phi-sector/2) <= apothem {
+			return false
+		}
+
+		// 羽根の境界線 (頂点から辺の延長線上)
+		for k := range vertices {
+			px, py := x-vertices[k][0], y-vertices[k][1]
+			t := px*directions[k][0] + py*directions[k][1]
+			d := math.Abs(px*directions[k][1] - py*directions[k][0])
+			if t < 0 && d < lineWidth/2 {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	samples := APERTURE_ICON_SAMPLES * APERTURE_ICON_SAMPLES
+	for py := 0; py < size; py++ {
+		for px := 0; px < size; px++ {
+			n := 0
+			for sy := 0; sy < APERTURE_ICON_SAMPLES; sy++ {
+				for sx := 0; sx < APERTURE_ICON_SAMPLES; sx++ {
+					x := float64(px) + (float64(sx)+0.5)/APERTURE_ICON_SAMPLES - outer
+					y := float64(py) + (float64(sy)+0.5)/APERTURE_ICON_SAMPLES - outer
+					if isBlade(x, y) {
+						n++
+					}
+				}
+			}
+			mask.Pix[py*mask.Stride+px] = uint8(n * 0xff / samples)
+		}
+	}
+
+	return mask
+}
+
+// 絞りのアイコンを描画する
+func drawApertureIcon(dst draw.Image, rect LayoutRect, fNumber float64, config *Config) {
+	mask := apertureMask(rect.Width, fNumber)
+	r := image.Rect(rect.X, rect.Y, rect.X+rect.Width, rect.Y+rect.Height)
+	draw.DrawMask(dst, r, config.textColor, image.Point{}, mask, image.Point{}, draw.Over)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+/*
+# 縦横比の切り抜き (-aspect)
+
+フレームを付ける前に、画像の中央を指定した縦横比 (例: "1:1", "3:2", "16:9") で切り抜く。
+縦横比は画像の向きに合わせて使う。縦長の画像に "3:2" を指定した場合は 2:3 で切り抜く。
+切り抜いた画像の幅か高さが ASPECT_MIN_SIZE に満たない場合は、極端に細いキャンバスを作らずエラーにする。
+*/
+
+// 切り抜いた画像の幅と高さの最小値 (ピクセル)
+const ASPECT_MIN_SIZE = 16
+
+// -aspect の値 ("W:H") を幅÷高さにする
+func parseAspect(value string) (float64, error) {
+	w, h, ok := strings.Cut(value, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid aspect ratio: %q (e.g. 3:2)", value)
+	}
+
+	width, err1 := strconv.ParseFloat(strings.TrimSpace(w), 64)
+	height, err2 := strconv.ParseFloat(strings.TrimSpace(h), 64)
+	if err1 != nil || err2 != nil || width <= 0 || height <= 0 {
+		return 0, fmt.Errorf("invalid aspect ratio: %q (e.g. 3:2)", value)
+	}
+
+	return width / height, nil
+}
+
+// 画像の中央を縦横比 ratio (幅÷高さ) で切り抜く
+// ratio は画像の向きに合わせ、縦長の画像では縦長、横長の画像では横長として扱う
+func cropToAspect(img image.Image, ratio float64) (image.Image, error) {
+	b := img.Bounds()
+	cropWidth, cropHeight, err := aspectSize(b.Dx(), b.Dy(), ratio)
+	if err != nil {
+		return nil, err
+	}
+
+	return imaging.CropCenter(img, cropWidth, cropHeight), nil
+}
+
+// width x height の画像を縦横比 ratio で切り抜いた大きさ
+func aspectSize(width, height int, ratio float64) (int, int, error) {
+	if (height > width && ratio > 1) || (width > height && ratio < 1) {
+		ratio = 1 / ratio
+	}
+
+	cropWidth, cropHeight := width, height
+	if float64(width)/float64(height) > ratio {
+		cropWidth = int(float64(height)*ratio + 0.5)
+	} else {
+		cropHeight = int(float64(width)/ratio + 0.5)
+	}
+	cropWidth, cropHeight = min(cropWidth, width), min(cropHeight, height)
+
+	if cropWidth < ASPECT_MIN_SIZE || cropHeight < ASPECT_MIN_SIZE {
+		return 0, 0, fmt.Errorf("cropping %dx%d to aspect ratio gives %dx%d (minimum %d pixels)", width, height, cropWidth, cropHeight, ASPECT_MIN_SIZE)
+	}
+
+	return cropWidth, cropHeight, nil
+}
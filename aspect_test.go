@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+)
+
+func TestParseAspect(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{"1:1", 1, false},
+		{"3:2", 1.5, false},
+		{" 16 : 9 ", 16.0 / 9, false},
+		{"4:5", 0.8, false},
+		{"3", 0, true},
+		{"3:0", 0, true},
+		{"-3:2", 0, true},
+		{"a:b", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseAspect(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseAspect(%q) = %v, %v, want %v (error %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCropToAspect(t *testing.T) {
+	tests := []struct {
+		name                  string
+		width, height         int
+		ratio                 float64
+		wantWidth, wantHeight int
+		wantErr               bool
+	}{
+		{"landscape 3:2", 600, 600, 1.5, 600, 400, false},
+		{"square", 600, 400, 1, 400, 400, false},
+		// 縦横比は画像の向きに合わせる
+		{"portrait 3:2", 400, 800, 1.5, 400, 600, false},
+		{"landscape 2:3", 800, 400, 2.0 / 3, 600, 400, false},
+		{"already 3:2", 300, 200, 1.5, 300, 200, false},
+		// 小さい画像に極端な縦横比を指定しても細すぎるキャンバスを作らない
+		{"small extreme", 40, 30, 10, 40, 4, true},
+		{"small portrait extreme", 30, 40, 0.1, 4, 40, true},
+		{"tiny square", 10, 10, 1, 10, 10, true},
+		{"small at minimum", 32, 32, 2, 32, ASPECT_MIN_SIZE, false},
+		{"large extreme", 2000, 1500, 100, 2000, 20, false},
+	}
+	for _, tt := range tests {
+		img, err := cropToAspect(testImage(tt.width, tt.height, color.White), tt.ratio)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: cropToAspect = %v, want error", tt.name, img.Bounds())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: cropToAspect: %v", tt.name, err)
+			continue
+		}
+		if b := img.Bounds(); b.Dx() != tt.wantWidth || b.Dy() != tt.wantHeight {
+			t.Errorf("%s: size = %dx%d, want %dx%d", tt.name, b.Dx(), b.Dy(), tt.wantWidth, tt.wantHeight)
+		}
+	}
+}
+
+// 切り抜けない場合はフレームを付けずにエラーにする
+func TestFrameAspectTooSmall(t *testing.T) {
+	config := testConfig(t)
+	config.aspect = 20
+
+	var buf bytes.Buffer
+	if err := FrameToWriter(&buf, bytes.NewReader(testJpeg(t, 60, 40, testCameraTags()...)), config); err == nil {
+		t.Error("FrameToWriter succeeded, want error")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("wrote %d bytes, want none", buf.Len())
+	}
+}
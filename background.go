@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/disintegration/imaging"
+)
+
+// -background の画像の敷き方
+const (
+	BACKGROUND_STRETCH = "stretch" // キャンバスの大きさに引き伸ばす
+	BACKGROUND_TILE    = "tile"    // 元の大きさで並べる
+)
+
+func loadBackground(path string) (image.Image, error) {
+	img, err := imaging.Open(path, imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("opening background: %w", err)
+	}
+	return img, nil
+}
+
+// 背景の画像をキャンバス全体に描画する
+func drawBackground(dst draw.Image, bg image.Image, mode string) {
+	r := dst.Bounds()
+
+	if mode == BACKGROUND_TILE {
+		b := bg.Bounds()
+		for y := r.Min.Y; y < r.Max.Y; y += b.Dy() {
+			for x := r.Min.X; x < r.Max.X; x += b.Dx() {
+				draw.Draw(dst, image.Rect(x, y, x+b.Dx(), y+b.Dy()), bg, b.Min, draw.Src)
+			}
+		}
+		return
+	}
+
+	resized := imaging.Resize(bg, r.Dx(), r.Dy(), imaging.Lanczos)
+	draw.Draw(dst, r, resized, image.Point{}, draw.Src)
+}
+
+// 領域の平均の明るさに応じて文字色を黒か白にする
+func contrastTextColor(img image.Image, r image.Rectangle) *image.Uniform {
+	r = r.Intersect(img.Bounds())
+	if r.Empty() {
+		return image.Black
+	}
+
+	// 大きな画像でも時間がかからないよう、最大で縦横64点ずつ調べる
+	stepX, stepY := max(r.Dx()/64, 1), max(r.Dy()/64, 1)
+	var sum float64
+	n := 0
+	for y := r.Min.Y; y < r.Max.Y; y += stepY {
+		for x := r.Min.X; x < r.Max.X; x += stepX {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			// ITU-R BT.601 の輝度
+			sum += 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+			n++
+		}
+	}
+
+	if sum/float64(n) < 128 {
+		return image.White
+	}
+	return image.Black
+}
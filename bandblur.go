@@ -0,0 +1,43 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/disintegration/imaging"
+)
+
+/*
+# ぼかした写真のラベル領域 (-band-blur)
+
+写真の下端をラベル領域と同じ縦横比で切り出し、強くぼかして暗くしたものをラベル領域の背景にする。
+文字は白で描画し、半透明の黒 (スクリム) を重ねて写真の明るさにかかわらず読めるようにする。
+ぼかしは縮小した画像に対して行い、大きな画像でも時間がかからないようにする。
+*/
+
+const (
+	BAND_BLUR_SCALE = 8   // ぼかす前に縮小する比
+	BAND_BLUR_SIGMA = 6.0 // 縮小した画像でのぼかしの強さ
+	BAND_BLUR_SCRIM = 0x80
+)
+
+// ラベル領域に写真の下端をぼかして描画する
+func drawBandBlur(dst draw.Image, src image.Image, band LayoutRect) {
+	if band.Width <= 0 || band.Height <= 0 {
+		return
+	}
+
+	// 写真の下端からラベル領域と同じ縦横比の帯を切り出す
+	b := src.Bounds()
+	stripHeight := min(max(b.Dx()*band.Height/band.Width, 1), b.Dy())
+	strip := imaging.Crop(src, image.Rect(b.Min.X, b.Max.Y-stripHeight, b.Max.X, b.Max.Y))
+
+	small := imaging.Resize(strip, max(band.Width/BAND_BLUR_SCALE, 1), max(band.Height/BAND_BLUR_SCALE, 1), imaging.Linear)
+	blurred := imaging.Blur(small, BAND_BLUR_SIGMA)
+	backdrop := imaging.Resize(blurred, band.Width, band.Height, imaging.Linear)
+
+	r := image.Rect(band.X, band.Y, band.X+band.Width, band.Y+band.Height)
+	draw.Draw(dst, r, backdrop, image.Point{}, draw.Src)
+	draw.Draw(dst, r, image.NewUniform(color.NRGBA{0, 0, 0, BAND_BLUR_SCRIM}), image.Point{}, draw.Over)
+}
@@ -0,0 +1,76 @@
+package main
+
+import "golang.org/x/image/font"
+
+/*
+# 書体に合わせたベースライン (-font-baselines)
+
+レイアウト定義の y は組み込みのフォントに合わせた固定のベースラインのため、上下の大きさが異なるフォント (-font-name) では
+行が詰まったり離れすぎたりする。-font-baselines の場合は、y が同じ行 (左右に並ぶ行) を1つの段として、
+各段のベースラインを書体の ascent と descent から求め直す。
+
+- 段の ascent と descent は段で最も大きい値とし、左右の行は書体が異なっても同じベースラインに揃える
+- 組み込みのフォントの ascent と descent との差の分だけ段をずらす (組み込みのフォントでは位置は変わらない)
+- 最後の段が定義より下にずれた分だけラベル領域を広げる
+*/
+
+// ベースラインを揃える段 (レイアウト定義の y が同じ行)
+type baselineRow struct {
+	specY   int    // レイアウト定義の y
+	ascent  [2]int // 段で最も大きい ascent (使用するフォント、組み込みのフォント)
+	descent [2]int // 段で最も大きい descent (使用するフォント、組み込みのフォント)
+	lines   []int  // layout.Lines の添字
+}
+
+// y が同じ行を段にまとめる (段は y の小さい順)
+// specs は layout.Lines と同じ順のレイアウト定義、reference は組み込みのフォント
+func baselineRows(lines []LayoutLine, specs []LineSpec, reference *Faces) []*baselineRow {
+	var rows []*baselineRow
+	for i, line := range lines {
+		var row *baselineRow
+		for _, r := range rows {
+			if r.specY == specs[i].Y {
+				row = r
+			}
+		}
+		if row == nil {
+			row = &baselineRow{specY: specs[i].Y}
+			at := len(rows)
+			for at > 0 && rows[at-1].specY > row.specY {
+				at--
+			}
+			rows = append(rows[:at], append([]*baselineRow{row}, rows[at:]...)...)
+		}
+
+		for j, metrics := range []font.Metrics{line.face.Metrics(), reference.face(specs[i].Font, specs[i].Size).Metrics()} {
+			row.ascent[j] = max(row.ascent[j], metrics.Ascent.Ceil())
+			row.descent[j] = max(row.descent[j], metrics.Descent.Ceil())
+		}
+		row.lines = append(row.lines, i)
+	}
+	return rows
+}
+
+// 各段のベースラインを書体の ascent と descent から求め直し、ラベル領域を広げる高さを返す
+func alignBaselines(layout *Layout, specs []LineSpec, reference *Faces) int {
+	rows := baselineRows(layout.Lines, specs, reference)
+
+	// 段の上端と前の段の下端が、組み込みのフォントとの差の分だけ動く
+	shift := 0
+	for i, row := range rows {
+		shift += row.ascent[0] - row.ascent[1]
+		if i > 0 {
+			shift += rows[i-1].descent[0] - rows[i-1].descent[1]
+		}
+
+		for _, j := range row.lines {
+			layout.Lines[j].Y += shift
+		}
+	}
+
+	if len(rows) == 0 {
+		return 0
+	}
+	last := rows[len(rows)-1]
+	return max(shift+last.descent[0]-last.descent[1], 0)
+}
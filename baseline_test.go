@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+)
+
+// ascent と descent を差し替えたFace (上下の大きさが異なるフォントを再現する)
+type metricsFace struct {
+	font.Face
+	ascent, descent int
+}
+
+func (f metricsFace) Metrics() font.Metrics {
+	m := f.Face.Metrics()
+	m.Ascent, m.Descent = fixed.I(f.ascent), fixed.I(f.descent)
+	m.Height = m.Ascent + m.Descent
+	return m
+}
+
+func TestAlignBaselines(t *testing.T) {
+	reference, err := loadFaces(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	specs := []LineSpec{
+		{Name: "camera", Font: "bold", Size: 200, Y: 200},
+		{Name: "lens", Font: "regular", Size: 150, Y: 400},
+		{Name: "exposure", Font: "bold", Size: 150, Y: 200},
+		{Name: "date", Font: "regular", Size: 150, Y: 400},
+	}
+	metrics := func(i int) font.Metrics { return reference.face(specs[i].Font, specs[i].Size).Metrics() }
+
+	tests := []struct {
+		name  string
+		extra [4][2]int // 組み込みのフォントに足す ascent と descent
+		shift [2]int    // 段ごとのベースラインの移動
+		grow  int
+	}{
+		{"embedded font", [4][2]int{}, [2]int{0, 0}, 0},
+		// 右の行の ascent が大きくなっても段の最大 (大きい文字のカメラの行) を超えなければ動かない
+		{"taller right", [4][2]int{{0, 0}, {0, 0}, {20, 0}, {0, 0}}, [2]int{0, 0}, 0},
+		{"taller fonts", [4][2]int{{40, 10}, {20, 5}, {0, 0}, {30, 20}}, [2]int{40, 40 + 10 + 30}, 40 + 10 + 30 + 20},
+		{"shorter fonts", [4][2]int{{-20, 0}, {-10, -5}, {-20, 0}, {-10, -5}}, [2]int{-20, -20 - 10}, 0},
+	}
+	for _, tt := range tests {
+		layout := &Layout{}
+		for i, spec := range specs {
+			m := metrics(i)
+			face := metricsFace{reference.face(spec.Font, spec.Size), m.Ascent.Ceil() + tt.extra[i][0], m.Descent.Ceil() + tt.extra[i][1]}
+			layout.Lines = append(layout.Lines, LayoutLine{Name: spec.Name, Y: spec.Y, face: face})
+		}
+
+		grow := alignBaselines(layout, specs, reference)
+
+		camera, lens, exposure, date := layout.Lines[0], layout.Lines[1], layout.Lines[2], layout.Lines[3]
+		if camera.Y != exposure.Y || lens.Y != date.Y {
+			t.Errorf("%s: camera, exposure baselines = %d, %d, lens, date = %d, %d, want the same", tt.name, camera.Y, exposure.Y, lens.Y, date.Y)
+		}
+		if camera.Y-200 != tt.shift[0] || lens.Y-400 != tt.shift[1] {
+			t.Errorf("%s: baselines shifted by %d, %d, want %d, %d", tt.name, camera.Y-200, lens.Y-400, tt.shift[0], tt.shift[1])
+		}
+		if grow != tt.grow {
+			t.Errorf("%s: grow = %d, want %d", tt.name, grow, tt.grow)
+		}
+	}
+}
+
+// 書体の異なるフォントでもカメラの行と撮影データの行は同じベースラインに並ぶ
+func TestFontBaselinesCameraExposure(t *testing.T) {
+	config := testConfig(t)
+	config.fonts = map[string][]byte{"bold": gobold.TTF, "regular": goregular.TTF}
+	config.fontName = "Go"
+	config.fontBaselines = true
+
+	layout := testLayout(t, 4000, 3000, config, testExifData())
+
+	y := map[string]int{}
+	for _, line := range layout.Lines {
+		y[line.Name] = line.Y
+	}
+	if y["camera"] == 0 || y["camera"] != y["exposure"] {
+		t.Errorf("camera, exposure baselines = %d, %d, want the same", y["camera"], y["exposure"])
+	}
+	if y["lens"] == 0 || y["lens"] != y["date"] {
+		t.Errorf("lens, date baselines = %d, %d, want the same", y["lens"], y["date"])
+	}
+}
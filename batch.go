@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FrameBatchの1ファイル分の結果
+type Result struct {
+	Path    string   // 入力ファイル
+	Outputs []string // 出力ファイル (形式ごと、失敗した形式は含まない)
+	Err     error
+	Stats   *Stats    // -stats の場合のみ
+	Exif    *ExifData // 読み込んだExif情報 (読み込む前に失敗した場合はnil)
+}
+
+// 複数のファイルにフレームを付けて exiframe-* として保存する
+// ctxがキャンセルされると未処理のファイルは処理せず、Errにctx.Err()を設定して返す
+func FrameBatch(ctx context.Context, paths []string, config *Config) ([]Result, error) {
+	results := make([]Result, len(paths))
+
+	workers := config.workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(paths) {
+		workers = len(paths)
+	}
+
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// フォントの準備はワーカーごとに1回だけ行い、処理するすべてのファイルで使う
+			renderer, err := NewRenderer(config)
+
+			for i := range jobs {
+				if err != nil {
+					results[i] = Result{Path: paths[i], Err: err}
+					continue
+				}
+
+				// 処理中にConfigへ書き込むため、ファイルごとに複製する
+				c := renderer.config
+				c.filePath = paths[i]
+				c.fileName = filepath.Base(paths[i])
+				if config.stats {
+					c.timing = &Stats{}
+				}
+
+				outputs, err := frameFile(ctx, &c)
+				results[i] = Result{Path: paths[i], Outputs: outputs, Err: err, Stats: c.timing, Exif: c.exifData}
+			}
+		}()
+	}
+
+feed:
+	for i := range paths {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			for j := i; j < len(paths); j++ {
+				results[j] = Result{Path: paths[j], Err: ctx.Err()}
+			}
+			break feed
+		}
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	return results, ctx.Err()
+}
+
+// 1ファイル分の処理 (各段階の間でキャンセルを確認する)
+// フレームの作成は1回だけ行い、出力形式ごとにエンコードする
+func frameFile(ctx context.Context, config *Config) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(config.filePath)
+	if err != nil {
+		return nil, ioError(fmt.Errorf("opening file: %w", err))
+	}
+
+	start := time.Now()
+	exifData, err := getExif(data, config)
+	config.timing.record(STAGE_EXIF, start)
+	if err != nil {
+		return nil, err
+	}
+
+	err = applyOverrideFile(exifData, config.filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkMinRating(exifData, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	dst, layout, err := composeFrame(data, config, exifData)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var timestamp time.Time
+	if config.keepTimestamps {
+		timestamp, err = outputTimestamp(exifData, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// exiframe-* として保存
+	start = time.Now()
+	outputs, err := writeOutputs(dst, config)
+	config.timing.record(STAGE_ENCODE, start)
+	if len(outputs) == 0 {
+		return nil, err
+	}
+
+	// -reveal の場合はラベルが現れるアニメーションGIFも保存する
+	if config.reveal {
+		output, revealErr := writeReveal(dst, layout, config)
+		if revealErr != nil {
+			return outputs, errors.Join(err, revealErr)
+		}
+		outputs = append(outputs, output)
+	}
+
+	// -extract-thumb の場合は埋め込みのサムネイルも保存する (Exifが無い画像では行わない)
+	if config.extractThumb && config.rawExif != nil {
+		output, thumbErr := writeThumbnail(config)
+		if thumbErr != nil {
+			return outputs, errors.Join(err, thumbErr)
+		}
+		outputs = append(outputs, output)
+	}
+
+	if config.dumpJson {
+		jsonErr := writeExifJson(outputs[0]+".json", config)
+		if jsonErr != nil {
+			return outputs, errors.Join(err, ioError(fmt.Errorf("writing Exif JSON: %w", jsonErr)))
+		}
+	}
+
+	// -exif-to-sidecar の場合は出力から取り除いたExifを隣のファイルに残す
+	if config.exifSidecar {
+		sidecarErr := writeExifJson(outputs[0]+EXIF_SIDECAR_SUFFIX, config)
+		if sidecarErr != nil {
+			return outputs, errors.Join(err, ioError(fmt.Errorf("writing Exif sidecar: %w", sidecarErr)))
+		}
+	}
+
+	// -keep-timestamps の場合は出力ファイルの日時を元のファイルに合わせる
+	if config.keepTimestamps {
+		timeErr := setTimestamps(outputs, timestamp)
+		if timeErr != nil {
+			return outputs, errors.Join(err, timeErr)
+		}
+	}
+
+	return outputs, err
+}
+
+// -min-rating に満たない画像は ErrBelowMinRating を返す (Ratingが無い画像は0とする)
+func checkMinRating(exifData *ExifData, config *Config) error {
+	if exifData.Rating < config.minRating {
+		return fmt.Errorf("%w: %d (minimum %d)", ErrBelowMinRating, exifData.Rating, config.minRating)
+	}
+	return nil
+}
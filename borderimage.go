@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+/*
+# 装飾の枠 (-border-image)
+
+CSSの border-image と同じように、画像を9つに分けてフレームの色の代わりにキャンバスに描画する。
+
+- -border-slice で上、右、下、左の切り分ける位置 (画像の端からのピクセル) を指定する。1つだけの場合は4辺とも同じ値にする。
+  省略した場合は画像の幅と高さの3分の1で切り分ける
+- 四隅はそのままの大きさでキャンバスの四隅に置く
+- 辺は四隅の間に、中央はキャンバスの残りの部分に、-border-repeat に従って引き伸ばすか並べる
+- 文字色はラベル領域の明るさに合わせる (-theme で文字色が決まっている場合はそれを使う)
+*/
+
+// -border-slice の値 ("N" または "上,右,下,左") を読み込む
+func parseBorderSlice(value string) ([4]int, error) {
+	var slice [4]int
+	parts := strings.Split(value, ",")
+	if len(parts) != 1 && len(parts) != 4 {
+		return slice, fmt.Errorf("invalid border slice: %q (e.g. 40 or 40,60,40,60)", value)
+	}
+
+	for i := range slice {
+		n, err := strconv.Atoi(strings.TrimSpace(parts[i%len(parts)]))
+		if err != nil || n < 0 {
+			return slice, fmt.Errorf("invalid border slice: %q (e.g. 40 or 40,60,40,60)", value)
+		}
+		slice[i] = n
+	}
+
+	return slice, nil
+}
+
+func loadBorderImage(path string) (image.Image, error) {
+	img, err := imaging.Open(path, imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("opening border image: %w", err)
+	}
+	return img, nil
+}
+
+// 9つに分けた画像をキャンバス全体に描画する
+// slice が全て0の場合は画像の幅と高さの3分の1で切り分ける
+func drawBorderImage(dst draw.Image, img image.Image, slice [4]int, mode string) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if slice == [4]int{} {
+		slice = [4]int{h / 3, w / 3, h / 3, w / 3}
+	}
+
+	// 切り分ける位置は画像とキャンバスの大きさに収める
+	r := dst.Bounds()
+	top, bottom := min(slice[0], h, r.Dy()/2), min(slice[2], h, r.Dy()/2)
+	left, right := min(slice[3], w, r.Dx()/2), min(slice[1], w, r.Dx()/2)
+	top, left = min(top, h-bottom), min(left, w-right)
+
+	// 画像の切り分ける位置 (xs, ys) とキャンバスの対応する位置 (xd, yd)
+	xs := [4]int{b.Min.X, b.Min.X + left, b.Max.X - right, b.Max.X}
+	ys := [4]int{b.Min.Y, b.Min.Y + top, b.Max.Y - bottom, b.Max.Y}
+	xd := [4]int{r.Min.X, r.Min.X + left, r.Max.X - right, r.Max.X}
+	yd := [4]int{r.Min.Y, r.Min.Y + top, r.Max.Y - bottom, r.Max.Y}
+
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			sr := image.Rect(xs[j], ys[i], xs[j+1], ys[i+1])
+			dr := image.Rect(xd[j], yd[i], xd[j+1], yd[i+1])
+			drawBorderSlice(dst, dr, img, sr, mode)
+		}
+	}
+}
+
+// 切り分けた1つを dr に引き伸ばすか並べる (四隅は同じ大きさのためそのまま置かれる)
+func drawBorderSlice(dst draw.Image, dr image.Rectangle, img image.Image, sr image.Rectangle, mode string) {
+	if dr.Empty() || sr.Empty() {
+		return
+	}
+
+	part := imaging.Crop(img, sr)
+	if mode == BACKGROUND_TILE {
+		for y := dr.Min.Y; y < dr.Max.Y; y += sr.Dy() {
+			for x := dr.Min.X; x < dr.Max.X; x += sr.Dx() {
+				draw.Draw(dst, image.Rect(x, y, x+sr.Dx(), y+sr.Dy()).Intersect(dr), part, image.Point{}, draw.Over)
+			}
+		}
+		return
+	}
+
+	if dr.Dx() != sr.Dx() || dr.Dy() != sr.Dy() {
+		part = imaging.Resize(part, dr.Dx(), dr.Dy(), imaging.Lanczos)
+	}
+	draw.Draw(dst, dr, part, image.Point{}, draw.Over)
+}
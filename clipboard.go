@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/dsoprea/go-exif/v3"
+)
+
+// クリップボードの画像を入力とするときのファイル名
+const CLIPBOARD_FILE_NAME = "clipboard"
+
+// -from-clipboard / -to-clipboard の処理
+// クリップボードの画像 (またはpath) にフレームを付け、クリップボード (またはファイル) に出力する
+// クリップボードの画像はExifを持たないため、Exifが無い場合はラベルを空にする
+func frameClipboard(path string, fromClipboard, toClipboard bool, config *Config) ([]string, error) {
+	var data []byte
+	var err error
+	if fromClipboard {
+		data, err = readClipboardImage()
+		if err != nil {
+			return nil, fmt.Errorf("reading clipboard: %w", err)
+		}
+		config.fileName = CLIPBOARD_FILE_NAME
+	} else {
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, ioError(fmt.Errorf("opening file: %w", err))
+		}
+		config.filePath = path
+		config.fileName = filepath.Base(path)
+	}
+
+	exifData, err := getExif(data, config)
+	if errors.Is(err, exif.ErrNoExif) {
+		exifData = &ExifData{}
+		config.exifData = exifData
+	} else if err != nil {
+		return nil, err
+	}
+
+	dst, _, err := composeFrame(data, config, exifData)
+	if err != nil {
+		return nil, err
+	}
+
+	if !toClipboard {
+		return writeOutputs(dst, config)
+	}
+
+	// クリップボードへはPNGで出力する
+	var buf bytes.Buffer
+	err = png.Encode(&buf, dst)
+	if err != nil {
+		return nil, fmt.Errorf("encoding PNG: %w", err)
+	}
+
+	err = writeClipboardImage(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("writing clipboard: %w", err)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,65 @@
+//go:build darwin
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// osascript でクリップボードのPNGを一時ファイルと受け渡す
+func runOsascript(lines ...string) error {
+	var args []string
+	for _, line := range lines {
+		args = append(args, "-e", line)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("osascript", args...)
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("osascript: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	return nil
+}
+
+func readClipboardImage() ([]byte, error) {
+	f, err := os.CreateTemp("", "exiframe-*.png")
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	err = runOsascript(
+		"set f to open for access POSIX file "+strconv.Quote(f.Name())+" with write permission",
+		"write (the clipboard as «class PNGf») to f",
+		"close access f",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("no image in clipboard: %w", err)
+	}
+
+	return os.ReadFile(f.Name())
+}
+
+func writeClipboardImage(data []byte) error {
+	f, err := os.CreateTemp("", "exiframe-*.png")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	return runOsascript("set the clipboard to (read (POSIX file " + strconv.Quote(f.Name()) + ") as «class PNGf»)")
+}
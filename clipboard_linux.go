@@ -0,0 +1,67 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Wayland では wl-clipboard、X11 では xclip を使う
+func clipboardCommand(write bool) (*exec.Cmd, error) {
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if _, err := exec.LookPath("wl-paste"); err == nil {
+			if write {
+				return exec.Command("wl-copy", "--type", "image/png"), nil
+			}
+			return exec.Command("wl-paste", "--type", "image/png"), nil
+		}
+	}
+
+	if _, err := exec.LookPath("xclip"); err == nil {
+		if write {
+			return exec.Command("xclip", "-selection", "clipboard", "-t", "image/png", "-i"), nil
+		}
+		return exec.Command("xclip", "-selection", "clipboard", "-t", "image/png", "-o"), nil
+	}
+
+	return nil, fmt.Errorf("clipboard requires wl-clipboard (Wayland) or xclip (X11)")
+}
+
+func readClipboardImage() ([]byte, error) {
+	cmd, err := clipboardCommand(false)
+	if err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	data, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", cmd.Path, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no image in clipboard")
+	}
+
+	return data, nil
+}
+
+func writeClipboardImage(data []byte) error {
+	cmd, err := clipboardCommand(true)
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", cmd.Path, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	return nil
+}
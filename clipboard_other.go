@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+var errClipboardUnsupported = errors.New("clipboard is not supported on this platform")
+
+func readClipboardImage() ([]byte, error) {
+	return nil, errClipboardUnsupported
+}
+
+func writeClipboardImage(data []byte) error {
+	return errClipboardUnsupported
+}
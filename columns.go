@@ -0,0 +1,89 @@
+package main
+
+/*
+# 複数列のラベル (-columns)
+
+レイアウト定義の各行を上から順に N 列に振り分け、ラベル領域に横に並べる。
+例: -columns 2 では左の列にカメラとレンズ、右の列に撮影データと日時を置く。
+各列の行数は差が1以内になるようにし、左端の列は左揃え、右端の列は右揃え、間の列は中央揃えにする。
+列を増やすほど行数が減り、ラベル領域は低くなる。列の幅に収まらない行は末尾を "…" で省略する。
+*/
+
+// 列と列の間隔
+const COLUMN_GAP = FONT_SIZE
+
+// ラベルを複数列に並べたレイアウトを計算する
+func computeColumnsLayout(srcWidth, srcHeight int, config *Config, exifData *ExifData, faces *Faces) *Layout {
+	// 枠と画像の位置、各行の文字列と書体は通常のレイアウトと同じ
+	// 焦点距離の目盛りは左右の行の間に置くため、列に並べる場合は描画しない
+	c := *config
+	c.columns = 0
+	c.focalBar = false
+	layout := computeLayout(srcWidth, srcHeight, &c, exifData, faces)
+
+	lines := layout.Lines
+	layout.Lines = nil
+	if len(lines) == 0 {
+		return layout
+	}
+
+	n := min(config.columns, len(lines))
+	rows := (len(lines) + n - 1) / n
+
+	// 行の高さは最も大きい書体に合わせる
+	ascent, height := 0, 0
+	for _, line := range lines {
+		ascent = max(ascent, line.face.Metrics().Ascent.Ceil())
+		height = max(height, line.face.Metrics().Height.Ceil())
+	}
+
+	// 枠の有無にかかわらず、左右に FRAME_PIXEL の余白を空ける
+	left := FRAME_PIXEL
+	columnWidth := (layout.Canvas.Width - 2*FRAME_PIXEL - (n-1)*COLUMN_GAP) / n
+
+	i := 0
+	for column := range n {
+		// 前の列ほど1行多くなるように振り分ける
+		count := len(lines) / n
+		if column < len(lines)%n {
+			count++
+		}
+
+		x0 := left + column*(columnWidth+COLUMN_GAP)
+		for row, line := range lines[i : i+count] {
+			line.Text, line.Width = truncateText(line.face, line.Text, columnWidth)
+
+			switch {
+			case column == 0:
+				line.X = x0
+			case column == n-1:
+				line.X = x0 + columnWidth - line.Width
+			default:
+				line.X = x0 + (columnWidth-line.Width)/2
+			}
+			line.Y = layout.Band.Y + config.labelGap + ascent + row*height
+
+			layout.Lines = append(layout.Lines, line)
+		}
+		i += count
+	}
+
+	// 絞りのアイコンを撮影データの行に合わせて置き直す
+	if layout.ApertureIcon != nil {
+		layout.ApertureIcon = nil
+		for _, line := range layout.Lines {
+			if line.Name == "exposure" && line.Text != "" {
+				size := line.face.Metrics().Ascent.Ceil()
+				layout.ApertureIcon = &LayoutRect{line.X - size*3/2, line.Y - size, size, size}
+				break
+			}
+		}
+	}
+
+	// ラベル領域の高さを行数に合わせる (下の余白は上と同じ)
+	bandHeight := config.labelGap + rows*height + config.labelGap
+	layout.Canvas.Height += bandHeight - layout.Band.Height
+	layout.Band.Height = bandHeight
+
+	return layout
+}
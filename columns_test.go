@@ -0,0 +1,75 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// レイアウトに使う撮影情報
+func testExifData() *ExifData {
+	return &ExifData{
+		Make:                    "SONY",
+		Model:                   "ILCE-7M4",
+		LensModel:               "FE 50mm F1.4 GM",
+		FNumber:                 "2.8",
+		ExposureTime:            "1/250",
+		PhotographicSensitivity: "400",
+		FocalLength:             "50",
+		DateTimeOriginal:        "2024/05/01 12:34",
+	}
+}
+
+func testLayout(t testing.TB, width, height int, config *Config, exifData *ExifData) *Layout {
+	t.Helper()
+
+	faces, err := configFaces(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return computeLayout(width, height, config, exifData, faces)
+}
+
+func TestColumnsLayout(t *testing.T) {
+	single := testLayout(t, 4000, 3000, testConfig(t), testExifData())
+
+	tests := []struct {
+		columns int
+		want    [][]string // 列ごとの行の名前
+	}{
+		{2, [][]string{{"camera", "lens"}, {"exposure", "date"}}},
+		{3, [][]string{{"camera", "lens"}, {"exposure"}, {"date"}}},
+	}
+	for _, tt := range tests {
+		config := testConfig(t)
+		config.columns = tt.columns
+		layout := testLayout(t, 4000, 3000, config, testExifData())
+
+		// 行は左の列から順に並び、列が変わると y 座標が先頭の行に戻る
+		var got [][]string
+		right := 0 // 前の行までの列の右端
+		for i, line := range layout.Lines {
+			if line.X < 0 || line.X+line.Width > layout.Canvas.Width {
+				t.Errorf("columns %d: line %q at x %d..%d is outside the canvas", tt.columns, line.Name, line.X, line.X+line.Width)
+			}
+			if i == 0 || line.Y <= layout.Lines[i-1].Y {
+				if line.X < right {
+					t.Errorf("columns %d: line %q overlaps the previous column", tt.columns, line.Name)
+				}
+				got = append(got, nil)
+			}
+			got[len(got)-1] = append(got[len(got)-1], line.Name)
+			right = max(right, line.X+line.Width)
+		}
+		if !slices.EqualFunc(got, tt.want, slices.Equal) {
+			t.Errorf("columns %d: lines = %v, want %v", tt.columns, got, tt.want)
+		}
+
+		// 1列あたりの行数が減るため、ラベル領域は1列に並べるより低い
+		if layout.Band.Height >= single.Band.Height {
+			t.Errorf("columns %d: band height = %d, want less than %d", tt.columns, layout.Band.Height, single.Band.Height)
+		}
+		if layout.Canvas.Height-layout.Band.Height != single.Canvas.Height-single.Band.Height {
+			t.Errorf("columns %d: canvas height = %d with band %d, want the photo area unchanged", tt.columns, layout.Canvas.Height, layout.Band.Height)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/image/font"
+)
+
+/*
+# 1行のラベル (-compact)
+
+レイアウト定義の各行の文字列を区切り文字でつなぎ、ラベル領域の中央に1行で描画する。
+例: "SONY ILCE-7M4 · SONY FE 24-70mm F2.8 GM II · 50mm  f/2.8  1/250s  ISO400 · 2024/05/01 12:34"
+幅に収まらない場合は文字を小さくし、COMPACT_MIN_FONT_SIZE でも収まらない場合は末尾を "…" で省略する。
+*/
+
+const (
+	COMPACT_SEPARATOR     = " · "
+	COMPACT_MIN_FONT_SIZE = 60
+)
+
+// ラベルを1行にまとめたレイアウトを計算する
+func computeCompactLayout(srcWidth, srcHeight int, config *Config, exifData *ExifData, faces *Faces) *Layout {
+	// 枠と画像の位置、各行の文字列は通常のレイアウトと同じ
+	c := *config
+	c.compact = false
+	layout := computeLayout(srcWidth, srcHeight, &c, exifData, faces)
+
+	var texts []string
+	for _, line := range layout.Lines {
+		if text := strings.TrimSpace(line.Text); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	layout.Lines = nil
+	layout.ApertureIcon = nil
+
+	separator := config.compactSeparator
+	if separator == "" {
+		separator = COMPACT_SEPARATOR
+	}
+	text := strings.Join(texts, separator)
+
+	// 枠の有無にかかわらず、左右に FRAME_PIXEL の余白を空ける
+	maxWidth := layout.Canvas.Width - 2*FRAME_PIXEL
+
+	face := faces.face("regular", FONT_SIZE)
+	width := font.MeasureString(face, text).Ceil()
+	if width > maxWidth {
+		size := max(float64(FONT_SIZE)*float64(maxWidth)/float64(width), COMPACT_MIN_FONT_SIZE)
+		face = faces.face("regular", size)
+		text, width = truncateText(face, text, maxWidth)
+	}
+
+	y := layout.Band.Y + config.labelGap + face.Metrics().Ascent.Ceil()
+	if text != "" {
+		layout.Lines = append(layout.Lines, LayoutLine{
+			Name:  "compact",
+			Text:  text,
+			X:     (layout.Canvas.Width - width) / 2,
+			Y:     y,
+			Width: width,
+			face:  face,
+		})
+	}
+
+	// ラベル領域の高さを1行に合わせる (下の余白は上と同じ)
+	bandHeight := config.labelGap + face.Metrics().Height.Ceil() + config.labelGap
+	layout.Canvas.Height += bandHeight - layout.Band.Height
+	layout.Band.Height = bandHeight
+
+	return layout
+}
+
+// maxWidth に収まるよう末尾を "…" で省略する
+func truncateText(face font.Face, text string, maxWidth int) (string, int) {
+	width := font.MeasureString(face, text).Ceil()
+	if width <= maxWidth {
+		return text, width
+	}
+
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		truncated := strings.TrimSpace(string(runes)) + "…"
+		width = font.MeasureString(face, truncated).Ceil()
+		if width <= maxWidth {
+			return truncated, width
+		}
+	}
+
+	return "", 0
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	CONTACT_THUMB_RATIO = 6 // サムネイルの高さ = キャンバスの幅 / CONTACT_THUMB_RATIO
+	CONTACT_GUTTER      = 60
+)
+
+// 1枚目の画像にフレームを付け、残りの画像をサムネイルとして下に並べる
+// Exif情報は1枚目の画像のものだけを使う
+func frameContact(paths []string, config *Config) ([]string, error) {
+	mainPath := paths[0]
+	config.filePath = mainPath
+	config.fileName = filepath.Base(mainPath)
+
+	data, err := os.ReadFile(mainPath)
+	if err != nil {
+		return nil, ioError(fmt.Errorf("opening file: %w", err))
+	}
+
+	exifData, err := getExif(data, config)
+	if err != nil {
+		return nil, err
+	}
+
+	frame, layout, err := composeFrame(data, config, exifData)
+	if err != nil {
+		return nil, err
+	}
+
+	var thumbs []image.Image
+	for _, path := range paths[1:] {
+		thumbData, err := os.ReadFile(path)
+		if err != nil {
+			return nil, ioError(fmt.Errorf("opening file: %w", err))
+		}
+
+		thumb, err := loadImage(thumbData, config)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		thumbs = append(thumbs, thumb)
+	}
+
+	dst := drawContactStrip(frame, layout, thumbs, config)
+
+	// exiframe-* として保存
+	return writeOutputs(dst, config)
+}
+
+// フレームの下にサムネイルを同じ高さで並べた画像を作成する
+// 幅に収まらない場合はサムネイルを縮小する
+func drawContactStrip(frame *image.RGBA, layout *Layout, thumbs []image.Image, config *Config) *image.RGBA {
+	if len(thumbs) == 0 {
+		return frame
+	}
+
+	margin := max(layout.Image.X, CONTACT_GUTTER)
+	availableWidth := layout.Canvas.Width - margin*2
+
+	thumbHeight := layout.Canvas.Width / CONTACT_THUMB_RATIO
+
+	// 高さを揃えたときの合計幅
+	totalWidth := CONTACT_GUTTER * (len(thumbs) - 1)
+	for _, thumb := range thumbs {
+		b := thumb.Bounds()
+		totalWidth += b.Dx() * thumbHeight / b.Dy()
+	}
+	if totalWidth > availableWidth {
+		gutters := CONTACT_GUTTER * (len(thumbs) - 1)
+		thumbHeight = thumbHeight * (availableWidth - gutters) / (totalWidth - gutters)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, layout.Canvas.Width, layout.Canvas.Height+thumbHeight+margin))
+	draw.Draw(dst, dst.Bounds(), config.frameColor, image.Point{}, draw.Src)
+	draw.Draw(dst, frame.Bounds(), frame, image.Point{}, draw.Src)
+
+	x := margin
+	y := layout.Canvas.Height
+	for _, thumb := range thumbs {
+		resized := imaging.Resize(thumb, 0, max(thumbHeight, 1), imaging.Lanczos)
+
+		op := draw.Src
+		if hasAlpha(resized) {
+			op = draw.Over
+		}
+		draw.Draw(dst, resized.Bounds().Add(image.Point{x, y}), resized, image.Point{}, op)
+
+		x += resized.Bounds().Dx() + CONTACT_GUTTER
+	}
+
+	return dst
+}
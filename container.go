@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/dsoprea/go-exif/v3"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+)
+
+/*
+# WebP / AVIF / BMP のExif
+
+WebP は RIFF の EXIF チャンク、AVIF は meta ボックスの Exif アイテムからExifを取り出す。
+どちらもExifが無い画像が多いため、Exifが無い場合は空のExif情報として扱う。
+
+WebP のデコードは golang.org/x/image/webp を使う。AVIF のデコーダーは無いため、AVIF はExifの読み込み (exif, -validate など) のみ対応し、
+フレームを付けようとした場合はデコードの前に ErrAvifNotSupported (終了コード3) にする。
+BMP にはExifを入れる場所が無いため、常に空のExif情報 (-manual-* を指定した場合はその値) とする。
+PNG と GIF もExifの無い画像 (透過した画像や書き出した画像) が多いため、Exifが無い場合は同じように空のExif情報とする。
+Exifが無い画像は -keep-exif を指定しても出力にExifを埋め込まない。
+*/
+
+// AVIFの画像にフレームを付けようとした場合のエラー
+var ErrAvifNotSupported = errors.New("avif images are not supported for framing (only their Exif data can be read)")
+
+// 画像のコンテナ形式 ("webp", "avif", "bmp", それ以外は空文字列)
+func imageContainer(data []byte) string {
+	if len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP" {
+		return "webp"
+	}
+	if len(data) >= 12 && string(data[4:8]) == "ftyp" {
+		brand := string(data[8:12])
+		if brand == "avif" || brand == "avis" {
+			return "avif"
+		}
+	}
+	if len(data) >= 2 && string(data[0:2]) == "BM" {
+		return "bmp"
+	}
+	return ""
+}
+
+// Exifが無いことの多い形式か (Exifが無くてもエラーにしない)
+func rarelyHasExif(data []byte) bool {
+	return imageContainer(data) != "" || bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")) || bytes.HasPrefix(data, []byte("GIF8"))
+}
+
+// コンテナ形式に応じてExif (TIFFヘッダーから始まるデータ) を取り出す
+func extractRawExif(data []byte) ([]byte, error) {
+	switch imageContainer(data) {
+	case "webp":
+		return webpExif(data)
+	case "avif":
+		return avifExif(data)
+	case "bmp":
+		return nil, exif.ErrNoExif
+	}
+
+	rawExif, err := exif.SearchAndExtractExif(data)
+	if err != nil {
+		return nil, fmt.Errorf("SearchAndExtractExif: %w", err)
+	}
+	return rawExif, nil
+}
+
+// 先頭に "Exif\0\0" がある場合は取り除く
+func trimExifHeader(raw []byte) []byte {
+	return bytes.TrimPrefix(raw, []byte(EXIF_HEADER))
+}
+
+// WebPのEXIFチャンク
+func webpExif(data []byte) ([]byte, error) {
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		body := pos + 8
+		if size < 0 || body+size > len(data) {
+			return nil, errors.New("webp: truncated chunk")
+		}
+
+		if id == "EXIF" {
+			return trimExifHeader(data[body : body+size]), nil
+		}
+
+		// チャンクは偶数バイトに揃えられる
+		pos = body + size + size%2
+	}
+
+	return nil, exif.ErrNoExif
+}
+
+// ISOBMFFのボックス (typ, 中身)
+type isoBox struct {
+	typ  string
+	body []byte
+}
+
+// data に並ぶボックスを読む
+func readIsoBoxes(data []byte) ([]isoBox, error) {
+	var boxes []isoBox
+	for pos := 0; pos+8 <= len(data); {
+		size := uint64(binary.BigEndian.Uint32(data[pos : pos+4]))
+		typ := string(data[pos+4 : pos+8])
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data) - pos)
+		case 1:
+			if pos+16 > len(data) {
+				return nil, errors.New("avif: truncated box")
+			}
+			size = binary.BigEndian.Uint64(data[pos+8 : pos+16])
+			header = 16
+		}
+		if size < header || size > uint64(len(data)-pos) {
+			return nil, errors.New("avif: truncated box")
+		}
+
+		boxes = append(boxes, isoBox{typ, data[uint64(pos)+header : uint64(pos)+size]})
+		pos += int(size)
+	}
+	return boxes, nil
+}
+
+// AVIFのExifアイテム (meta の iinf で種類が "Exif" のアイテムを iloc の位置から読む)
+// アイテムの先頭4バイトはTIFFヘッダーまでのオフセット
+func avifExif(data []byte) ([]byte, error) {
+	boxes, err := readIsoBoxes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var meta []byte
+	for _, box := range boxes {
+		if box.typ == "meta" && len(box.body) >= 4 {
+			meta = box.body[4:]
+		}
+	}
+	if meta == nil {
+		return nil, exif.ErrNoExif
+	}
+
+	children, err := readIsoBoxes(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &boxReader{}
+	itemId := uint32(0)
+	for _, box := range children {
+		if box.typ == "iinf" {
+			itemId = r.findExifItem(box.body)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if itemId == 0 {
+		return nil, exif.ErrNoExif
+	}
+
+	for _, box := range children {
+		if box.typ != "iloc" {
+			continue
+		}
+
+		offset, length := r.findItemLocation(box.body, itemId)
+		if r.err != nil {
+			return nil, r.err
+		}
+		// offset+length は桁あふれする場合があるため足さずに比べる
+		if length < 4 || offset > uint64(len(data)) || length > uint64(len(data))-offset {
+			return nil, errors.New("avif: invalid Exif item location")
+		}
+
+		item := data[offset : offset+length]
+		tiffOffset := uint64(binary.BigEndian.Uint32(item[0:4]))
+		if 4+tiffOffset > uint64(len(item)) {
+			return nil, errors.New("avif: invalid Exif header offset")
+		}
+		return trimExifHeader(item[4+tiffOffset:]), nil
+	}
+
+	return nil, exif.ErrNoExif
+}
+
+// ボックスの中身をビッグエンディアンで順に読む (範囲外の場合はerrを設定し0を返す)
+type boxReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *boxReader) uint(n int) uint64 {
+	if r.err != nil {
+		return 0
+	}
+	if n == 0 {
+		return 0
+	}
+	if r.pos+n > len(r.data) {
+		r.err = errors.New("avif: truncated box")
+		return 0
+	}
+
+	var v uint64
+	for _, b := range r.data[r.pos : r.pos+n] {
+		v = v<<8 | uint64(b)
+	}
+	r.pos += n
+	return v
+}
+
+// iinf から種類が "Exif" のアイテムのIDを探す (無ければ0)
+func (r *boxReader) findExifItem(iinf []byte) uint32 {
+	if len(iinf) < 4 {
+		return 0
+	}
+	version := iinf[0]
+	countSize := 2
+	if version > 0 {
+		countSize = 4
+	}
+	if len(iinf) < 4+countSize {
+		return 0
+	}
+
+	entries, err := readIsoBoxes(iinf[4+countSize:])
+	if err != nil {
+		r.err = err
+		return 0
+	}
+
+	for _, entry := range entries {
+		// infe バージョン2以上: item_ID, item_protection_index, item_type
+		if entry.typ != "infe" || len(entry.body) < 4 || entry.body[0] < 2 {
+			continue
+		}
+
+		er := &boxReader{data: entry.body, pos: 4}
+		idSize := 2
+		if entry.body[0] >= 3 {
+			idSize = 4
+		}
+		id := er.uint(idSize)
+		er.uint(2)
+		itemType := er.uint(4)
+		if er.err == nil && itemType == uint64(binary.BigEndian.Uint32([]byte("Exif"))) {
+			return uint32(id)
+		}
+	}
+
+	return 0
+}
+
+// iloc からアイテムのファイル内の位置と長さを求める (最初のエクステントのみ)
+func (r *boxReader) findItemLocation(iloc []byte, itemId uint32) (offset, length uint64) {
+	r.data, r.pos = iloc, 0
+
+	version := r.uint(1)
+	r.uint(3)
+	sizes := r.uint(2)
+	offsetSize, lengthSize := int(sizes>>12&0xf), int(sizes>>8&0xf)
+	baseOffsetSize, indexSize := int(sizes>>4&0xf), int(sizes&0xf)
+	if version == 0 {
+		indexSize = 0
+	}
+
+	idSize := 2
+	if version == 2 {
+		idSize = 4
+	}
+	count := r.uint(idSize)
+
+	for range count {
+		// 途中で切れている場合は残りの件数を読まない
+		if r.err != nil {
+			return 0, 0
+		}
+
+		id := r.uint(idSize)
+		constructionMethod := uint64(0)
+		if version == 1 || version == 2 {
+			constructionMethod = r.uint(2) & 0xf
+		}
+		r.uint(2)
+		baseOffset := r.uint(baseOffsetSize)
+		extentCount := r.uint(2)
+
+		for i := range extentCount {
+			r.uint(indexSize)
+			extentOffset := r.uint(offsetSize)
+			extentLength := r.uint(lengthSize)
+
+			if uint32(id) == itemId && i == 0 {
+				if constructionMethod != 0 {
+					r.err = errors.New("avif: unsupported Exif item construction method")
+					return 0, 0
+				}
+				return baseOffset + extentOffset, extentLength
+			}
+		}
+	}
+
+	return 0, 0
+}
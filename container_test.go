@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// ISOBMFFのボックス
+func testBox(typ string, body ...[]byte) []byte {
+	b := bytes.Join(body, nil)
+	return append(binary.BigEndian.AppendUint32(nil, uint32(8+len(b))), append([]byte(typ), b...)...)
+}
+
+// Exifアイテムを1つ持つAVIF (offset が0の場合はmdatの中を指す、offsetSize は4か8)
+func testAvif(tiff []byte, offset uint64, offsetSize int) []byte {
+	ftyp := testBox("ftyp", []byte("avif"), []byte{0, 0, 0, 0}, []byte("mif1"))
+
+	// infe バージョン2: item_ID=1, item_protection_index=0, item_type="Exif"
+	infe := testBox("infe", []byte{2, 0, 0, 0}, []byte{0, 1}, []byte{0, 0}, []byte("Exif"))
+	iinf := testBox("iinf", []byte{0, 0, 0, 0}, []byte{0, 1}, infe)
+
+	item := append(binary.BigEndian.AppendUint32(nil, 6), append([]byte(EXIF_HEADER), tiff...)...)
+
+	iloc := func(offset uint64) []byte {
+		b := []byte{0, 0, 0, 0, byte(offsetSize<<4 | 4), 0, 0, 1, 0, 1, 0, 0, 0, 1}
+		if offsetSize == 8 {
+			b = binary.BigEndian.AppendUint64(b, offset)
+		} else {
+			b = binary.BigEndian.AppendUint32(b, uint32(offset))
+		}
+		return testBox("iloc", binary.BigEndian.AppendUint32(b, uint32(len(item))))
+	}
+
+	meta := func(offset uint64) []byte {
+		return testBox("meta", []byte{0, 0, 0, 0}, iinf, iloc(offset))
+	}
+
+	if offset == 0 {
+		offset = uint64(len(ftyp) + len(meta(0)) + 8)
+	}
+	return bytes.Join([][]byte{ftyp, meta(offset), testBox("mdat", item)}, nil)
+}
+
+func TestAvifExif(t *testing.T) {
+	exifData, err := ReadExif(bytes.NewReader(testAvif(testExif(t, testCameraTags()...), 0, 4)))
+	if err != nil {
+		t.Fatalf("ReadExif: %v", err)
+	}
+	if exifData.Make != "SONY" || exifData.Model != "ILCE-7M4" {
+		t.Errorf("Make, Model = %q, %q, want SONY, ILCE-7M4", exifData.Make, exifData.Model)
+	}
+}
+
+// 範囲外の iloc はパニックせずにエラーにする
+func TestAvifExifInvalidLocation(t *testing.T) {
+	tiff := testExif(t, testCameraTags()...)
+
+	for _, offset := range []uint64{1 << 40, ^uint64(0) - 3, ^uint64(0)} {
+		data := testAvif(tiff, offset, 8)
+		if _, err := avifExif(data); err == nil {
+			t.Errorf("offset %#x: avifExif succeeded, want error", offset)
+		}
+	}
+}
+
+// 途中で切れた iloc は残りの件数を読まずにエラーにする
+func TestAvifExifTruncatedIloc(t *testing.T) {
+	r := &boxReader{}
+	iloc := []byte{2, 0, 0, 0, 0x44, 0, 0xff, 0xff, 0xff, 0xff}
+	if _, length := r.findItemLocation(iloc, 1); length != 0 || r.err == nil {
+		t.Errorf("findItemLocation = %d, %v, want 0 and an error", length, r.err)
+	}
+}
+
+func TestFrameAvifNotSupported(t *testing.T) {
+	data := testAvif(testExif(t, testCameraTags()...), 0, 4)
+
+	err := FrameToWriter(&bytes.Buffer{}, bytes.NewReader(data), testConfig(t))
+	if !errors.Is(err, ErrAvifNotSupported) {
+		t.Fatalf("FrameToWriter error = %v, want %v", err, ErrAvifNotSupported)
+	}
+	if got := exitCode(err); got != EXIT_DECODE {
+		t.Errorf("exitCode = %d, want %d", got, EXIT_DECODE)
+	}
+}
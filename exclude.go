@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+/*
+# 除外するファイル (-exclude)
+
+ファイル名 (ディレクトリを除いた部分) がパターンに一致する入力を処理しない。
+"*.jpg" のように指定したファイルから "*_edit.jpg" などを除くために使う。
+
+- パターンは filepath.Match の形式で、カンマ区切りまたは繰り返して複数指定できる
+- -zip の場合はzipの中のエントリ名に適用する
+- 除外した入力は結果に含めず、-verbose の場合に1つずつ表示する。ファイルの場合は除外した数も最後に表示する
+*/
+
+// 繰り返し指定できる -exclude の値
+type excludeFlag []string
+
+func (f *excludeFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *excludeFlag) Set(value string) error {
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		// 不正なパターンはここでエラーにする
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return err
+		}
+		*f = append(*f, pattern)
+	}
+	return nil
+}
+
+// ファイル名がいずれかのパターンに一致するか (name は "/" 区切りのzipのエントリ名でもよい)
+func excluded(name string, patterns []string) bool {
+	base := path.Base(filepath.ToSlash(name))
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// パターンに一致しない入力だけを残す
+func excludePaths(paths []string, patterns []string) (kept []string, excludedCount int) {
+	for _, p := range paths {
+		if excluded(p, patterns) {
+			logInfo("Exclude", p)
+			excludedCount++
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept, excludedCount
+}
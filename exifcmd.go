@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"text/tabwriter"
+)
+
+// exif サブコマンド: フレームを付けずにExif情報を表示する
+// 既定では go-exiframe が読み込む項目 (ExifData) を、-all の場合はすべてのタグも表示する
+func exifCommand(args []string) {
+	fs := flag.NewFlagSet("exif", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Print as JSON")
+	all := fs.Bool("all", false, "Also print all raw tags in the file")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		exitUsage("Please provide image files")
+	}
+
+	var firstErr error
+	for _, path := range fs.Args() {
+		err := printExif(path, *jsonOutput, *all)
+		if err != nil {
+			logError(fmt.Sprintf("Error %s: %v", path, err))
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if firstErr != nil {
+		os.Exit(exitCode(firstErr))
+	}
+}
+
+func printExif(path string, jsonOutput bool, all bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ioError(fmt.Errorf("opening file: %w", err))
+	}
+
+	config := &Config{}
+	exifData, err := getExif(data, config)
+	if err != nil {
+		return err
+	}
+
+	var tags []RawTag
+	if all && len(config.rawExif) > 0 {
+		tags, err = collectRawTags(config.rawExif)
+		if err != nil {
+			return decodeError(err)
+		}
+	}
+
+	if jsonOutput {
+		out, err := json.MarshalIndent(struct {
+			File string    `json:"file"`
+			Exif *ExifData `json:"exif"`
+			Tags []RawTag  `json:"tags,omitempty"`
+		}{path, exifData, tags}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "== %s\n", path)
+
+	// ExifData の項目を定義の順に表示する (空の項目は省く)
+	v := reflect.ValueOf(*exifData)
+	for i := range v.NumField() {
+		value := fmt.Sprint(v.Field(i).Interface())
+		if value == "" || value == "0" {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\n", v.Type().Field(i).Name, value)
+	}
+
+	if all {
+		fmt.Fprintln(w)
+		for _, tag := range tags {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", tag.IfdPath, tag.TagId, tag.Name, tag.Value)
+		}
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dsoprea/go-exif/v3"
+)
+
+/*
+# 任意のExifのタグ (-exif-field)
+
+組み込みの項目に無いタグを、IFDのパスとタグIDで指定してラベルの最後に1行ずつ追加する。
+
+	-exif-field "IFD/Exif:0xa431=Serial"   ->  "Serial: 012345"
+	-exif-field "IFD:0x013b"               ->  "Artist: NAME" (ラベルを省略した場合はタグ名)
+
+- IFDのパスは exif -all や -dump-json の ifd と同じ (IFD, IFD/Exif, IFD/GPSInfo など)
+- 値が複数あるタグは空白で区切って並べる
+- IFDやタグが無い画像では行を追加しない
+- MakerNote の中のタグはIFDとして読み込まれないため指定できない
+*/
+
+const EXIF_FIELD_SEPARATOR = ": "
+
+// -exif-field で指定したタグ
+type ExifField struct {
+	IfdPath string
+	TagId   uint16
+	Label   string // 空の場合はタグ名
+}
+
+// 繰り返し指定できる -exif-field の値
+type exifFieldFlag []ExifField
+
+func (f *exifFieldFlag) String() string {
+	var fields []string
+	for _, field := range *f {
+		fields = append(fields, fmt.Sprintf("%s:0x%04x=%s", field.IfdPath, field.TagId, field.Label))
+	}
+	return strings.Join(fields, ", ")
+}
+
+func (f *exifFieldFlag) Set(value string) error {
+	field, err := parseExifField(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, field)
+	return nil
+}
+
+// "IFDのパス:タグID=ラベル" (ラベルは省略できる)
+func parseExifField(value string) (ExifField, error) {
+	tag, label, _ := strings.Cut(value, "=")
+	path, id, ok := strings.Cut(tag, ":")
+	if !ok || path == "" {
+		return ExifField{}, fmt.Errorf("invalid exif field %q (want IFD/Exif:0xa431=Label)", value)
+	}
+
+	tagId, err := strconv.ParseUint(id, 0, 16)
+	if err != nil {
+		return ExifField{}, fmt.Errorf("invalid exif field %q (want IFD/Exif:0xa431=Label)", value)
+	}
+
+	return ExifField{path, uint16(tagId), label}, nil
+}
+
+// 指定したタグを "ラベル: 値" の行にする (無いタグは除く)
+func readExifFields(rootIfd *exif.Ifd, fields []ExifField) []string {
+	var lines []string
+	for _, field := range fields {
+		ifd, err := exif.FindIfdFromRootIfd(rootIfd, field.IfdPath)
+		if err != nil {
+			logDebug(fmt.Sprintf("IFD %s not found", field.IfdPath))
+			continue
+		}
+
+		results, err := ifd.FindTagWithId(field.TagId)
+		if err != nil || len(results) == 0 {
+			if err != nil && !errors.Is(err, exif.ErrTagNotFound) {
+				logWarn(fmt.Sprintf("Error reading %s:0x%04x: %v", field.IfdPath, field.TagId, err))
+			}
+			logDebug(fmt.Sprintf("Tag %s:0x%04x not found", field.IfdPath, field.TagId))
+			continue
+		}
+
+		values, err := formatValues(results[0])
+		if err != nil {
+			logWarn(fmt.Sprintf("Error reading %s:0x%04x: %v", field.IfdPath, field.TagId, err))
+			continue
+		}
+		value := strings.TrimSpace(strings.Join(values, " "))
+		if value == "" {
+			continue
+		}
+
+		label := field.Label
+		if label == "" {
+			label = results[0].TagName()
+		}
+		lines = append(lines, label+EXIF_FIELD_SEPARATOR+value)
+	}
+	return lines
+}
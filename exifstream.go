@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/dsoprea/go-exif/v3"
+)
+
+// JPEGのセグメントを先頭から順に調べ、ExifのAPP1セグメントだけを読み込んでExif情報を返す
+// 画素データ (SOS以降) は読まないため、大きな画像でも少ないメモリで撮影情報を確認できる
+// JPEG以外の画像は ReadExif と同じく全体を読み込む
+func ReadExifFromReaderAt(r io.ReaderAt, size int64) (ExifData, error) {
+	var head [4]byte
+	_, err := r.ReadAt(head[:2], 0)
+	if err != nil {
+		return ExifData{}, fmt.Errorf("reading image: %w", err)
+	}
+
+	if head[0] != 0xff || head[1] != SOI_MARKER {
+		exifData, err := ReadExif(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return ExifData{}, err
+		}
+		return *exifData, nil
+	}
+
+	pos := int64(2)
+	for pos+4 <= size {
+		_, err := r.ReadAt(head[:], pos)
+		if err != nil {
+			return ExifData{}, fmt.Errorf("reading image: %w", err)
+		}
+		if head[0] != 0xff {
+			return ExifData{}, decodeError(fmt.Errorf("invalid marker at %d", pos))
+		}
+		marker := head[1]
+		if marker == 0xff {
+			// 詰め物の0xff
+			pos++
+			continue
+		}
+		if marker == SOS_MARKER {
+			break
+		}
+
+		length := int64(head[2])<<8 | int64(head[3])
+		if length < 2 || pos+2+length > size {
+			return ExifData{}, decodeError(fmt.Errorf("truncated segment %#x at %d", marker, pos))
+		}
+
+		if marker == APP1_MARKER && length >= 2+int64(len(EXIF_HEADER)) {
+			segment := make([]byte, length-2)
+			_, err := r.ReadAt(segment, pos+4)
+			if err != nil {
+				return ExifData{}, fmt.Errorf("reading image: %w", err)
+			}
+
+			// XMPなど他のAPP1セグメントは読み飛ばす
+			if bytes.HasPrefix(segment, []byte(EXIF_HEADER)) {
+				exifData, err := getExif(segment, &Config{})
+				if err != nil {
+					return ExifData{}, err
+				}
+				return *exifData, nil
+			}
+		}
+
+		pos += 2 + length
+	}
+
+	return ExifData{}, decodeError(fmt.Errorf("SearchAndExtractExif: %w", exif.ErrNoExif))
+}
@@ -0,0 +1,60 @@
+package main
+
+import "errors"
+
+/*
+# 終了コード
+
+- 0: 成功
+- 1: その他のエラー
+- 2: 引数の誤り、-validate の検証エラー
+- 3: 画像やExif情報の読み込みに失敗
+- 4: ファイルの読み書きやエンコードに失敗
+- 5: 複数ファイルのうち一部だけ失敗
+*/
+
+const (
+	EXIT_FAILURE = 1
+	EXIT_USAGE   = 2
+	EXIT_DECODE  = 3
+	EXIT_IO      = 4
+	EXIT_PARTIAL = 5
+)
+
+// 終了コードを決めるためにエラーの種類を付ける (メッセージはそのまま)
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func decodeError(err error) error {
+	return &exitError{EXIT_DECODE, err}
+}
+
+func ioError(err error) error {
+	return &exitError{EXIT_IO, err}
+}
+
+// エラーに対応する終了コード (種類の無いエラーは EXIT_FAILURE)
+func exitCode(err error) int {
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+
+	return EXIT_FAILURE
+}
+
+// 引数の誤りを表示して終了する
+func exitUsage(a ...any) {
+	logError(a...)
+	exit(EXIT_USAGE)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"plain", errors.New("x"), EXIT_FAILURE},
+		{"decode", decodeError(errors.New("x")), EXIT_DECODE},
+		{"io", ioError(errors.New("x")), EXIT_IO},
+		{"wrapped decode", fmt.Errorf("file: %w", decodeError(errors.New("x"))), EXIT_DECODE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// 壊れたExifは読み込みの失敗 (EXIT_DECODE) にする
+func TestCorruptExifExitCode(t *testing.T) {
+	valid := testExif(t, testCameraTags()...)
+
+	// Make (0x010f, ASCII) の値のオフセットを範囲外にする
+	badValue := bytes.Clone(valid)
+	i := bytes.Index(badValue, []byte{0x01, 0x0f, 0x00, 0x02})
+	if i < 0 {
+		t.Fatal("Make entry not found")
+	}
+	copy(badValue[i+8:], []byte{0x7f, 0xff, 0xff, 0x00})
+
+	// IFD0のオフセットを範囲外にする
+	badIfd := bytes.Clone(valid)
+	copy(badIfd[4:], []byte{0x7f, 0xff, 0xff, 0x00})
+
+	for name, exifData := range map[string][]byte{"tag value": badValue, "ifd offset": badIfd} {
+		t.Run(name, func(t *testing.T) {
+			var jpegData bytes.Buffer
+			if err := writeJpegWithExif(&jpegData, testJpeg(t, 60, 40), exifData); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := ReadExif(bytes.NewReader(jpegData.Bytes()))
+			if err == nil {
+				t.Fatal("ReadExif succeeded, want error")
+			}
+			if got := exitCode(err); got != EXIT_DECODE {
+				t.Errorf("exitCode = %d, want %d (%v)", got, EXIT_DECODE, err)
+			}
+		})
+	}
+}
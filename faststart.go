@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+const (
+	SOI_MARKER  = 0xd8
+	SOS_MARKER  = 0xda
+	DQT_MARKER  = 0xdb
+	DRI_MARKER  = 0xdd
+	APP0_MARKER = 0xe0
+)
+
+// JPEGのセグメント (マーカーと長さを含む)
+type jpegSegment struct {
+	marker byte
+	data   []byte
+}
+
+// SOIの後からSOSの前までのセグメントと、SOS以降のデータに分ける
+func splitJpegSegments(data []byte) ([]jpegSegment, []byte, error) {
+	if len(data) < 4 || data[0] != 0xff || data[1] != SOI_MARKER {
+		return nil, nil, errors.New("not a JPEG")
+	}
+
+	var segments []jpegSegment
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xff {
+			return nil, nil, fmt.Errorf("invalid marker at %d", pos)
+		}
+		marker := data[pos+1]
+		if marker == 0xff {
+			// 詰め物の0xff
+			pos++
+			continue
+		}
+		if marker == SOS_MARKER {
+			return segments, data[pos:], nil
+		}
+
+		length := int(data[pos+2])<<8 | int(data[pos+3])
+		if length < 2 || pos+2+length > len(data) {
+			return nil, nil, fmt.Errorf("truncated segment %#x at %d", marker, pos)
+		}
+
+		segments = append(segments, jpegSegment{marker, data[pos : pos+2+length]})
+		pos += 2 + length
+	}
+
+	return nil, nil, errors.New("no SOS marker")
+}
+
+// 画像の大きさ (SOF) と量子化・ハフマンテーブルを先頭に寄せる (-faststart)
+// ICCプロファイルなどの大きなAPPセグメントを読む前に、ブラウザが画像の大きさを知りデコードの準備ができる
+// JFIF (APP0) とExif (APP1) は規格どおりSOIの直後に残す
+func faststartJpeg(data []byte) ([]byte, error) {
+	segments, scan, err := splitJpegSegments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// SOF0-SOF15 (0xc0-0xcf、JPG 0xc8 を除く。DHT 0xc4 と DAC 0xcc もテーブル) と DQT, DRI
+	isTable := func(m byte) bool {
+		return (m >= 0xc0 && m <= 0xcf && m != 0xc8) || m == DQT_MARKER || m == DRI_MARKER
+	}
+
+	var head, tables, rest [][]byte
+	for i, s := range segments {
+		switch {
+		case isTable(s.marker):
+			tables = append(tables, s.data)
+		case i == len(head) && (s.marker == APP0_MARKER || (s.marker == APP1_MARKER && bytes.HasPrefix(s.data[4:], []byte(EXIF_HEADER)))):
+			head = append(head, s.data)
+		default:
+			rest = append(rest, s.data)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(data))
+	buf.Write(data[:2])
+	for _, group := range [][][]byte{head, tables, rest} {
+		for _, s := range group {
+			buf.Write(s)
+		}
+	}
+	buf.Write(scan)
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"image/jpeg"
+	"slices"
+	"testing"
+)
+
+// SOIの後のセグメントのマーカー (SOSまで)
+func jpegMarkers(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	segments, _, err := splitJpegSegments(data)
+	if err != nil {
+		t.Fatalf("splitJpegSegments: %v", err)
+	}
+	var markers []byte
+	for _, s := range segments {
+		markers = append(markers, s.marker)
+	}
+	return markers
+}
+
+func TestFaststartJpeg(t *testing.T) {
+	// Exif (APP1) の後、テーブルの前に大きなAPP2 (ICCプロファイルなど) を入れる
+	data := testJpeg(t, 64, 48, testCameraTags()...)
+	segments, scan, err := splitJpegSegments(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app2 := append([]byte{0xff, 0xe2, 0x10, 0x02}, make([]byte, 0x1000)...)
+
+	var src bytes.Buffer
+	src.Write(data[:2])
+	for i, s := range segments {
+		src.Write(s.data)
+		if i == 0 {
+			src.Write(app2)
+		}
+	}
+	src.Write(scan)
+
+	out, err := faststartJpeg(src.Bytes())
+	if err != nil {
+		t.Fatalf("faststartJpeg: %v", err)
+	}
+
+	markers := jpegMarkers(t, out)
+	if markers[0] != APP1_MARKER {
+		t.Errorf("first segment = %#x, want Exif APP1 right after SOI", markers[0])
+	}
+	app2Index := slices.Index(markers, 0xe2)
+	for _, m := range []byte{DQT_MARKER, 0xc0, 0xc4} {
+		if i := slices.Index(markers, m); i < 0 || i > app2Index {
+			t.Errorf("segment %#x at %d, want before APP2 at %d (order %x)", m, i, app2Index, markers)
+		}
+	}
+
+	if len(out) != src.Len() {
+		t.Errorf("length = %d, want %d", len(out), src.Len())
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("reordered JPEG does not decode: %v", err)
+	}
+	if exifData, err := ReadExif(bytes.NewReader(out)); err != nil || exifData.Make != "SONY" {
+		t.Errorf("ReadExif after reordering = %v, %v", exifData, err)
+	}
+}
+
+func TestFaststartJpegInvalid(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"not jpeg":  []byte("\x89PNG\r\n\x1a\n"),
+		"truncated": {0xff, SOI_MARKER, 0xff, 0xe1, 0x10, 0x00, 0x00},
+		"no sos":    {0xff, SOI_MARKER, 0xff, 0xe0, 0x00, 0x02},
+	} {
+		if _, err := faststartJpeg(data); err == nil {
+			t.Errorf("%s: faststartJpeg succeeded, want error", name)
+		}
+	}
+}
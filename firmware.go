@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dsoprea/go-exif/v3"
+)
+
+/*
+# ファームウェアのバージョン (-inventory)
+
+機材の記録用に、カメラのファームウェアのバージョンを読み込む。記録方法はメーカーごとに異なるため取得できる範囲で行う。
+
+- Canon: MakerNote の FirmwareVersion (例: "Firmware Version 1.0.6")
+- その他: IFD0 の Software。多くのカメラはファームウェアを "Ver.1.10" や "ILCE-7M4 v2.00" のように記録する。
+  現像ソフトなどで書き換えられた値 ("Adobe Photoshop Lightroom Classic 13.0" など) を除くため、
+  "Ver", "Version", "v" に続くバージョン番号がある場合だけを使う
+
+-inventory の場合はカメラ名の後ろに "FW 1.10" のように併記する。取得できない場合は何も表示しない。
+*/
+
+const (
+	SOFTWARE_TAG_ID               = 0x0131
+	CANON_FIRMWARE_VERSION_TAG_ID = 0x0007
+	FIRMWARE_PREFIX               = "FW "
+)
+
+// "Ver.1.10", "Version 1.2", "v2.00", "Firmware Version 1.0.6" などのバージョン番号
+var FIRMWARE_VERSION_PATTERN = regexp.MustCompile(`(?i)(?:^|[^a-z])(?:ver(?:sion)?\.?\s*|v)(\d+(?:\.\d+)+)`)
+
+// ファームウェアのバージョン番号を求める (取得できない場合は空文字列)
+func readFirmware(rawExif []byte, rootIfd *exif.Ifd, exifIfd *exif.Ifd, cameraMake string) (string, error) {
+	if exifIfd != nil && strings.HasPrefix(strings.ToUpper(cameraMake), "CANON") {
+		firmware, err := canonFirmware(rawExif, exifIfd)
+		if err != nil || firmware != "" {
+			return firmware, err
+		}
+	}
+
+	results, err := rootIfd.FindTagWithId(SOFTWARE_TAG_ID)
+	if err != nil || len(results) == 0 {
+		return "", nil
+	}
+
+	value, err := results[0].Value()
+	if err != nil {
+		return "", err
+	}
+	software, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected Software %v", value)
+	}
+
+	return firmwareVersion(software), nil
+}
+
+// CanonのMakerNoteからFirmwareVersionを取得する
+func canonFirmware(rawExif []byte, exifIfd *exif.Ifd) (string, error) {
+	results, err := exifIfd.FindTagWithId(MAKER_NOTE_TAG_ID)
+	if err != nil || len(results) == 0 {
+		return "", nil
+	}
+
+	makerNote, err := results[0].GetRawBytes()
+	if err != nil {
+		return "", fmt.Errorf("GetRawBytes MakerNote: %w", err)
+	}
+
+	// オフセットはTIFFヘッダー基準のため、Exif内でのMakerNoteの位置を求める
+	pos := bytes.Index(rawExif, makerNote)
+	if pos < 0 {
+		return "", fmt.Errorf("MakerNote not found in Exif data")
+	}
+
+	entries, err := readMakerNoteIfd(rawExif, uint32(pos), exifIfd.ByteOrder())
+	if err != nil {
+		return "", fmt.Errorf("reading Canon MakerNote: %w", err)
+	}
+
+	version, ok := entries[CANON_FIRMWARE_VERSION_TAG_ID]
+	if !ok || version.tagType != 2 {
+		return "", nil
+	}
+
+	return firmwareVersion(string(bytes.TrimRight(version.value, "\x00"))), nil
+}
+
+// 文字列の中のバージョン番号 ("Ver.1.10" -> "1.10"、無い場合は空文字列)
+func firmwareVersion(value string) string {
+	match := FIRMWARE_VERSION_PATTERN.FindStringSubmatch(value)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
@@ -0,0 +1,42 @@
+package main
+
+import "fmt"
+
+/*
+# ラベル領域に収める (-fit-labels)
+
+ラベル領域の高さはレイアウト定義の band_height (テーマや -layout-spec) で決まるため、
+行が多い場合や文字が大きい場合は下の行がラベル領域からはみ出す。
+-fit-labels の場合は、最も下の行の descent までの高さがラベル領域に収まるよう、全ての行の文字の大きさと y を同じ比率で縮める。
+
+- 収まっている場合は何も変えない (大きくはしない)
+- extend の行はラベル領域を広げるため、必要な高さには含めない (縮める比率は同じように適用する)
+- -keywords や -exif-field の行はラベル領域を広げて追加するため対象外
+- -table, -compact, -columns や縦長の画像の配置はラベル領域を行に合わせて決めるため影響しない
+*/
+
+// 行がラベル領域の高さ bandHeight に収まるよう縮めたレイアウト定義の行
+func fitLines(lines []LineSpec, bandHeight int, faces *Faces, text func(LineSpec) string) []LineSpec {
+	needed := 0
+	for _, lineSpec := range lines {
+		if lineSpec.Extend || (lineSpec.Optional && text(lineSpec) == "") {
+			continue
+		}
+		descent := faces.face(lineSpec.Font, lineSpec.Size).Metrics().Descent.Ceil()
+		needed = max(needed, lineSpec.Y+descent)
+	}
+	if needed <= bandHeight || needed <= 0 {
+		return lines
+	}
+
+	k := float64(bandHeight) / float64(needed)
+	logDebug(fmt.Sprintf("Scale label lines by %.3f to fit band height %d (needed %d)", k, bandHeight, needed))
+
+	fitted := make([]LineSpec, len(lines))
+	for i, lineSpec := range lines {
+		lineSpec.Y = int(float64(lineSpec.Y) * k)
+		lineSpec.Size *= k
+		fitted[i] = lineSpec
+	}
+	return fitted
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// band_height の低いラベル領域に多くの行を並べたレイアウト定義
+func testTallLayoutSpec(t testing.TB, lines int) *LayoutSpec {
+	t.Helper()
+
+	json := `{"band_height": 400, "lines": [`
+	for i := range lines {
+		if i > 0 {
+			json += ","
+		}
+		json += fmt.Sprintf(`{"name": "line%d", "text": "${Camera} ${Lens}", "font": "regular", "size": 150, "align": "left", "y": %d}`, i, 200+i*200)
+	}
+	json += `]}`
+
+	spec, err := parseLayoutSpec([]byte(json))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+// -fit-labels の場合は全ての行がラベル領域に収まるよう、同じ比率で縮める
+func TestFitLabelsManyLines(t *testing.T) {
+	for _, fitLabels := range []bool{false, true} {
+		config := testConfig(t)
+		config.layoutSpec = testTallLayoutSpec(t, 8)
+		config.fitLabels = fitLabels
+
+		layout := testLayout(t, 4000, 3000, config, testExifData())
+		if len(layout.Lines) != 8 {
+			t.Fatalf("fitLabels %v: %d lines, want 8", fitLabels, len(layout.Lines))
+		}
+
+		band := layout.Band
+		overflow := false
+		for i, line := range layout.Lines {
+			metrics := line.face.Metrics()
+			if line.Y-metrics.Ascent.Ceil() < band.Y || line.Y+metrics.Descent.Ceil() > band.Y+band.Height {
+				overflow = true
+			}
+			// 行の間隔と文字の大きさは全ての行で同じ比率になる
+			if i > 0 && line.face.Metrics().Height != layout.Lines[0].face.Metrics().Height {
+				t.Errorf("fitLabels %v: line %d height = %v, want %v", fitLabels, i, metrics.Height, layout.Lines[0].face.Metrics().Height)
+			}
+		}
+		if overflow == fitLabels {
+			t.Errorf("fitLabels %v: lines outside band %+v = %v", fitLabels, band, overflow)
+		}
+		// ラベル領域は広げない (上の余白と band_height)
+		if want := config.labelGap + 400; band.Height != want {
+			t.Errorf("fitLabels %v: band height = %d, want %d", fitLabels, band.Height, want)
+		}
+	}
+}
+
+func TestFitLines(t *testing.T) {
+	faces, err := loadFaces(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := func(lineSpec LineSpec) string { return lineSpec.Text }
+	lines := []LineSpec{
+		{Name: "a", Text: "a", Font: "bold", Size: 200, Y: 200},
+		{Name: "b", Text: "b", Font: "regular", Size: 150, Y: 400},
+		{Name: "c", Text: "", Font: "regular", Size: 150, Y: 1000, Optional: true},
+		{Name: "d", Text: "d", Font: "regular", Size: 150, Y: 1200, Extend: true},
+	}
+	descent := faces.face("regular", 150).Metrics().Descent.Ceil()
+
+	tests := []struct {
+		name       string
+		bandHeight int
+		want       float64 // 縮める比率
+	}{
+		// 空の optional の行と extend の行は必要な高さに含めない
+		{"fits", 400 + descent, 1},
+		{"larger band", 1000, 1},
+		{"half", (400 + descent) / 2, float64((400+descent)/2) / float64(400+descent)},
+	}
+	for _, tt := range tests {
+		fitted := fitLines(lines, tt.bandHeight, faces, text)
+		for i, line := range fitted {
+			if want := lines[i].Size * tt.want; line.Size != want {
+				t.Errorf("%s: line %s size = %g, want %g", tt.name, line.Name, line.Size, want)
+			}
+			if want := int(float64(lines[i].Y) * tt.want); line.Y != want {
+				t.Errorf("%s: line %s y = %d, want %d", tt.name, line.Name, line.Y, want)
+			}
+		}
+	}
+}
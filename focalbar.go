@@ -0,0 +1,101 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/*
+# 焦点距離の目盛り (-focal-bar)
+
+35mm換算の焦点距離 14mm〜800mm を対数の目盛りで横に並べ、撮影時の焦点距離の位置に三角の印を付ける。
+画角の広さが一目で分かるよう、ラベル領域の中央に描画し、印の下に焦点距離を添える。
+*/
+
+const (
+	FOCAL_BAR_MIN       = 14.0
+	FOCAL_BAR_MAX       = 800.0
+	FOCAL_BAR_WIDTH     = 5  // 目盛りの幅 (キャンバスの幅に対する比の逆数)
+	FOCAL_BAR_LINE      = 12 // 線の太さ (目盛りの高さに対する比の逆数)
+	FOCAL_BAR_FONT_SIZE = 100
+)
+
+// 目盛りを付ける焦点距離
+var FOCAL_BAR_TICKS = []float64{14, 24, 35, 50, 85, 135, 200, 400, 800}
+
+// 35mm換算の焦点距離 (FocalLengthIn35mmFilm が無い場合は FocalLength、どちらも無い場合は0)
+func focalLength35mm(exifData *ExifData) float64 {
+	if f, err := strconv.ParseFloat(exifData.FocalLengthIn35mmFilm, 64); err == nil && f > 0 {
+		return f
+	}
+
+	numerator, denominator, ok := strings.Cut(exifData.FocalLength, "/")
+	if !ok {
+		f, _ := strconv.ParseFloat(exifData.FocalLength, 64)
+		return f
+	}
+	n, err1 := strconv.ParseFloat(numerator, 64)
+	d, err2 := strconv.ParseFloat(denominator, 64)
+	if err1 != nil || err2 != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+// 焦点距離の目盛り上の位置 (0〜1)
+func focalBarPosition(focalLength float64) float64 {
+	f := math.Max(FOCAL_BAR_MIN, math.Min(FOCAL_BAR_MAX, focalLength))
+	return math.Log(f/FOCAL_BAR_MIN) / math.Log(FOCAL_BAR_MAX/FOCAL_BAR_MIN)
+}
+
+// 焦点距離の目盛りを描画する
+// rect の下端に横線と目盛り、上半分に焦点距離の位置を示す下向きの三角を描く
+func drawFocalBar(dst draw.Image, rect LayoutRect, focalLength float64, config *Config) {
+	line := max(rect.Height/FOCAL_BAR_LINE, 1)
+	bottom := rect.Y + rect.Height
+
+	// 横線
+	draw.Draw(dst, image.Rect(rect.X, bottom-line, rect.X+rect.Width, bottom), config.textColor, image.Point{}, draw.Over)
+
+	// 目盛り
+	for _, tick := range FOCAL_BAR_TICKS {
+		x := rect.X + int(focalBarPosition(tick)*float64(rect.Width-line))
+		draw.Draw(dst, image.Rect(x, bottom-rect.Height/3, x+line, bottom), config.textColor, image.Point{}, draw.Over)
+	}
+
+	// 焦点距離の位置の三角
+	size := rect.Height / 2
+	cx := rect.X + line/2 + int(focalBarPosition(focalLength)*float64(rect.Width-line))
+	mask := triangleMask(size)
+	r := image.Rect(cx-size/2, rect.Y, cx-size/2+size, rect.Y+size)
+	draw.DrawMask(dst, r, config.textColor, image.Point{}, mask, image.Point{}, draw.Over)
+}
+
+// 下向きの二等辺三角形のマスク (アンチエイリアスのため1辺あたり APERTURE_ICON_SAMPLES 点を調べる)
+func triangleMask(size int) *image.Alpha {
+	mask := image.NewAlpha(image.Rect(0, 0, size, size))
+
+	samples := APERTURE_ICON_SAMPLES * APERTURE_ICON_SAMPLES
+	half := float64(size) / 2
+	for py := 0; py < size; py++ {
+		for px := 0; px < size; px++ {
+			n := 0
+			for sy := 0; sy < APERTURE_ICON_SAMPLES; sy++ {
+				for sx := 0; sx < APERTURE_ICON_SAMPLES; sx++ {
+					x := float64(px) + (float64(sx)+0.5)/APERTURE_ICON_SAMPLES
+					y := float64(py) + (float64(sy)+0.5)/APERTURE_ICON_SAMPLES
+					// 上辺から下の頂点に向かって幅が狭くなる
+					if math.Abs(x-half) <= half*(1-y/float64(size)) {
+						n++
+					}
+				}
+			}
+			mask.Pix[py*mask.Stride+px] = uint8(n * 0xff / samples)
+		}
+	}
+
+	return mask
+}
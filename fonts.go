@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/golang/freetype/truetype"
+)
+
+// システムのフォントを探すディレクトリ
+func fontDirs() []string {
+	home, _ := os.UserHomeDir()
+
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"/System/Library/Fonts", "/Library/Fonts", filepath.Join(home, "Library/Fonts")}
+	case "windows":
+		return []string{filepath.Join(os.Getenv("WINDIR"), "Fonts"), filepath.Join(os.Getenv("LOCALAPPDATA"), "Microsoft/Windows/Fonts")}
+	default:
+		return []string{"/usr/share/fonts", "/usr/local/share/fonts", filepath.Join(home, ".local/share/fonts"), filepath.Join(home, ".fonts")}
+	}
+}
+
+// -font-name で指定されたファミリー名のフォントをシステムから探す
+// レイアウト定義のフォント名 (regular, bold) ごとのTTFを返す。太字が無い場合は標準の太さを使う
+// TrueType (.ttf) のみ対応
+func findSystemFonts(family string) (map[string][]byte, error) {
+	fonts := map[string][]byte{}
+
+	for _, dir := range fontDirs() {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".ttf") {
+				return nil
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			fnt, err := truetype.Parse(data)
+			if err != nil || !strings.EqualFold(fnt.Name(truetype.NameIDFontFamily), family) {
+				return nil
+			}
+
+			switch strings.ToLower(fnt.Name(truetype.NameIDFontSubfamily)) {
+			case "regular", "book", "roman":
+				fonts["regular"] = data
+			case "bold":
+				fonts["bold"] = data
+			}
+
+			return nil
+		})
+	}
+
+	if fonts["regular"] == nil {
+		return nil, fmt.Errorf("font %q not found", family)
+	}
+	if fonts["bold"] == nil {
+		fonts["bold"] = fonts["regular"]
+	}
+
+	return fonts, nil
+}
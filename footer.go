@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+)
+
+/*
+# フッター (-footer-logo, -footer-text)
+
+ラベル領域の下に細い帯を追加し、ロゴ画像とURLなどの文字を並べて描画する。
+納品する写真に撮影者や配信先の名前を入れるためのもので、カメラのロゴとは別に扱う。
+ロゴは帯の高さの半分に縮小し、文字の左に置く。-footer-align で中央揃えか右揃えを選ぶ。
+*/
+
+// -footer-align の値
+const (
+	FOOTER_ALIGN_CENTER = "center"
+	FOOTER_ALIGN_RIGHT  = "right"
+)
+
+const (
+	FOOTER_HEIGHT    = 240
+	FOOTER_FONT_SIZE = 100
+	FOOTER_LOGO_GAP  = 60 // ロゴと文字の間隔
+)
+
+func loadFooterLogo(path string) (image.Image, error) {
+	img, err := imaging.Open(path, imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("opening footer logo: %w", err)
+	}
+	return img, nil
+}
+
+// キャンバスの下にフッターの領域を追加し、ロゴと文字を配置する
+func addFooter(layout *Layout, config *Config, faces *Faces) {
+	if config.footerLogo == nil && config.footerText == "" {
+		return
+	}
+
+	footer := LayoutRect{0, layout.Canvas.Height, layout.Canvas.Width, FOOTER_HEIGHT}
+	layout.Footer = &footer
+	layout.Canvas.Height += FOOTER_HEIGHT
+
+	// ロゴは縦横比を保って帯の高さの半分にする
+	var logoWidth, logoHeight int
+	if config.footerLogo != nil {
+		b := config.footerLogo.Bounds()
+		logoHeight = FOOTER_HEIGHT / 2
+		logoWidth = max(b.Dx()*logoHeight/max(b.Dy(), 1), 1)
+	}
+
+	face := faces.face("regular", FOOTER_FONT_SIZE)
+	textWidth := 0
+	if config.footerText != "" {
+		textWidth = font.MeasureString(face, config.footerText).Ceil()
+	}
+
+	width := logoWidth + textWidth
+	if logoWidth > 0 && textWidth > 0 {
+		width += FOOTER_LOGO_GAP
+	}
+
+	x := (footer.Width - width) / 2
+	if config.footerAlign == FOOTER_ALIGN_RIGHT {
+		x = footer.Width - FRAME_PIXEL - width
+	}
+
+	if logoWidth > 0 {
+		layout.FooterLogo = &LayoutRect{x, footer.Y + (FOOTER_HEIGHT-logoHeight)/2, logoWidth, logoHeight}
+		x += logoWidth + FOOTER_LOGO_GAP
+	}
+
+	if textWidth > 0 {
+		metrics := face.Metrics()
+		layout.Lines = append(layout.Lines, LayoutLine{
+			Name:  "footer",
+			Text:  config.footerText,
+			X:     x,
+			Y:     footer.Y + (FOOTER_HEIGHT+metrics.Ascent.Ceil()-metrics.Descent.Ceil())/2,
+			Width: textWidth,
+			face:  face,
+		})
+	}
+}
+
+// フッターのロゴを描画する
+func drawFooterLogo(dst draw.Image, rect LayoutRect, logo image.Image) {
+	resized := imaging.Resize(logo, rect.Width, rect.Height, imaging.Lanczos)
+	r := image.Rect(rect.X, rect.Y, rect.X+rect.Width, rect.Y+rect.Height)
+	draw.Draw(dst, r, resized, image.Point{}, draw.Over)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+)
+
+/*
+# タグの整形 (RegisterFormatter)
+
+ライブラリとして使う場合に、Exifの値をラベルに表示する形式を変更できるようにする。
+getExif はタグを読み込むたびに登録された関数を探し、あればExifの値 (有理数は "28/10" の形式) を渡してその戻り値を使う。
+
+- 対象は ExifData の文字列の項目 (ExposureTime, FNumber, FocalLength, Model など)。数値の項目や GPSDateTime, UserComment には使わない
+- RegisterFormatter で登録した関数は組み込みの整形 (ExposureTime, FNumber, FocusDistance, DateTimeOriginal) より優先する
+- nil を登録すると組み込みの整形に戻す
+- -manual-* や -settings の値には使わない
+- 複数のゴルーチンから呼び出してもよいが、処理中の画像にどちらの関数が使われるかは決まらないため、処理を始める前に登録する
+*/
+
+// 組み込みの整形
+var BUILTIN_FORMATTERS = map[string]func(raw string) string{
+	"ExposureTime":  formatExposureTime,
+	"FNumber":       formatFNumber,
+	"FocusDistance": formatFocusDistance,
+}
+
+var (
+	formattersMu sync.RWMutex
+	formatters   = map[string]func(raw string) string{}
+)
+
+// タグの値を整形する関数を登録する (tag は ExifData の項目名)
+func RegisterFormatter(tag string, fn func(raw string) string) {
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+
+	if fn == nil {
+		delete(formatters, tag)
+		return
+	}
+	formatters[tag] = fn
+}
+
+// 登録された関数、無ければ組み込みの整形 (どちらも無い場合はnil)
+func lookupFormatter(tag string) func(raw string) string {
+	formattersMu.RLock()
+	fn := formatters[tag]
+	formattersMu.RUnlock()
+
+	if fn != nil {
+		return fn
+	}
+	return BUILTIN_FORMATTERS[tag]
+}
+
+// 登録された関数があればExifDataの文字列の項目に整形した値を設定する
+// 設定した場合は true を返す
+func applyFormatter(exifData *ExifData, tag string, raw string) bool {
+	fn := lookupFormatter(tag)
+	if fn == nil {
+		return false
+	}
+
+	field := reflect.ValueOf(exifData).Elem().FieldByName(tag)
+	if !field.IsValid() || field.Kind() != reflect.String {
+		return false
+	}
+
+	field.SetString(fn(raw))
+	return true
+}
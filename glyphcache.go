@@ -0,0 +1,125 @@
+package main
+
+import (
+	"container/list"
+	"image"
+	"image/draw"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// ラベルのグリフのマスク (フォント名、サイズ、文字、描画位置の端数ごと)
+// 画像ごとにFaceを作成しても同じ文字を再びラスタライズしないよう、全体で共有する
+// serve のように長く動く場合や -fit-labels などで文字の大きさが画像ごとに変わる場合に増え続けないよう、
+// マスクの合計が GLYPH_CACHE_MAX_BYTES を超えたら最も長く使われていないものから捨てる
+var GLYPH_CACHE = newGlyphCache(GLYPH_CACHE_MAX_BYTES)
+
+const GLYPH_CACHE_MAX_BYTES = 64 << 20
+
+type glyphCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	bytes    int                        // 保持しているマスクの合計
+	order    *list.List                 // 使った順 (先頭が最近、要素は *glyphEntry)
+	glyphs   map[glyphKey]*list.Element // order の要素
+}
+
+type glyphEntry struct {
+	key   glyphKey
+	glyph cachedGlyph
+}
+
+func newGlyphCache(maxBytes int) *glyphCache {
+	return &glyphCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		glyphs:   map[glyphKey]*list.Element{},
+	}
+}
+
+// キャッシュしたグリフを返す (使った順の先頭に移す)
+func (c *glyphCache) get(key glyphKey) (cachedGlyph, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.glyphs[key]
+	if !ok {
+		return cachedGlyph{}, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*glyphEntry).glyph, true
+}
+
+// グリフを追加し、上限を超えた分を古いものから捨てる (追加したものは残す)
+func (c *glyphCache) put(key glyphKey, g cachedGlyph) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.glyphs[key]; ok {
+		return
+	}
+	c.glyphs[key] = c.order.PushFront(&glyphEntry{key, g})
+	c.bytes += g.size()
+
+	for c.bytes > c.maxBytes && c.order.Len() > 1 {
+		oldest := c.order.Remove(c.order.Back()).(*glyphEntry)
+		delete(c.glyphs, oldest.key)
+		c.bytes -= oldest.glyph.size()
+	}
+}
+
+type glyphKey struct {
+	name  string
+	size  float64
+	r     rune
+	fracX fixed.Int26_6
+	fracY fixed.Int26_6
+}
+
+type cachedGlyph struct {
+	dr      image.Rectangle // 描画位置の整数部分からの相対位置
+	mask    *image.Alpha
+	advance fixed.Int26_6
+	ok      bool
+}
+
+// キャッシュの上限に数えるマスクの大きさ
+func (g cachedGlyph) size() int {
+	if g.mask == nil {
+		return 0
+	}
+	return len(g.mask.Pix)
+}
+
+// Glyphの結果をキャッシュするFace (その他のメソッドは元のFaceのまま)
+type cachedFace struct {
+	font.Face
+	name  string
+	size  float64
+	cache *glyphCache
+}
+
+func (f *cachedFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	origin := image.Point{dot.X.Floor(), dot.Y.Floor()}
+	key := glyphKey{f.name, f.size, r, dot.X & 63, dot.Y & 63}
+
+	g, hit := f.cache.get(key)
+	if !hit {
+		// 元のFaceが返すマスクは次の呼び出しで上書きされるためコピーして保持する
+		dr, mask, maskp, advance, ok := f.Face.Glyph(dot, r)
+		g = cachedGlyph{dr: dr.Sub(origin), advance: advance, ok: ok}
+		if ok {
+			g.mask = image.NewAlpha(image.Rect(0, 0, dr.Dx(), dr.Dy()))
+			draw.Draw(g.mask, g.mask.Bounds(), mask, maskp, draw.Src)
+		}
+		f.cache.put(key, g)
+	}
+
+	if !g.ok {
+		return image.Rectangle{}, nil, image.Point{}, g.advance, false
+	}
+
+	return g.dr.Add(origin), g.mask, image.Point{}, g.advance, true
+}
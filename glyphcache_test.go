@@ -0,0 +1,118 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+func testGlyph(size int) cachedGlyph {
+	return cachedGlyph{mask: image.NewAlpha(image.Rect(0, 0, size, 1)), ok: true}
+}
+
+// 上限を超えたら最も長く使われていないグリフから捨てる
+func TestGlyphCacheEviction(t *testing.T) {
+	cache := newGlyphCache(300)
+	key := func(r rune) glyphKey { return glyphKey{name: "regular", size: 150, r: r} }
+
+	cache.put(key('a'), testGlyph(100))
+	cache.put(key('b'), testGlyph(100))
+	cache.put(key('c'), testGlyph(100))
+	cache.get(key('a'))
+	cache.put(key('d'), testGlyph(100))
+
+	for r, want := range map[rune]bool{'a': true, 'b': false, 'c': true, 'd': true} {
+		if _, ok := cache.get(key(r)); ok != want {
+			t.Errorf("glyph %q cached = %v, want %v", r, ok, want)
+		}
+	}
+	if cache.bytes > cache.maxBytes {
+		t.Errorf("bytes = %d, want at most %d", cache.bytes, cache.maxBytes)
+	}
+
+	// 上限より大きいグリフも追加したものは残す
+	cache.put(key('e'), testGlyph(1000))
+	if _, ok := cache.get(key('e')); !ok || cache.order.Len() != 1 {
+		t.Errorf("large glyph cached = %v with %d entries, want only it", ok, cache.order.Len())
+	}
+}
+
+// 文字の大きさが変わり続けてもマスクの合計は上限を超えない
+func TestGlyphCacheBounded(t *testing.T) {
+	fnt, err := truetype.Parse(LAYOUT_FONTS["regular"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := newGlyphCache(1 << 20)
+	dst := image.NewRGBA(image.Rect(0, 0, 2000, 300))
+	for size := 100.0; size < 200; size += 0.5 {
+		face := &cachedFace{Face: truetype.NewFace(fnt, &truetype.Options{Size: size}), name: "regular", size: size, cache: cache}
+		d := &font.Drawer{Dst: dst, Src: image.Black, Face: face, Dot: fixed.P(0, 200)}
+		d.DrawString("ILCE-7M4 1/250s f/2.8")
+	}
+
+	if cache.bytes > cache.maxBytes {
+		t.Errorf("bytes = %d, want at most %d", cache.bytes, cache.maxBytes)
+	}
+}
+
+// キャッシュしたグリフと元のFaceのグリフは同じ画素になる
+func TestCachedFaceMatchesFace(t *testing.T) {
+	fnt, err := truetype.Parse(LAYOUT_FONTS["regular"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	draw := func(face font.Face) *image.RGBA {
+		dst := testImage(1200, 200, color.White)
+		for range 2 {
+			d := &font.Drawer{Dst: dst, Src: image.Black, Face: face, Dot: fixed.Point26_6{X: fixed.I(10) + 17, Y: fixed.I(150)}}
+			d.DrawString("2024/05/01 12:34")
+		}
+		return dst
+	}
+
+	options := &truetype.Options{Size: FONT_SIZE / 2}
+	want := draw(truetype.NewFace(fnt, options))
+	got := draw(&cachedFace{Face: truetype.NewFace(fnt, options), name: "regular", size: FONT_SIZE / 2, cache: newGlyphCache(GLYPH_CACHE_MAX_BYTES)})
+	if string(got.Pix) != string(want.Pix) {
+		t.Error("cached glyphs differ from the face's glyphs")
+	}
+}
+
+// 多くの画像で同じ文字を描画する場合のキャッシュの効果
+func BenchmarkDrawLabel(b *testing.B) {
+	fnt, err := truetype.Parse(LAYOUT_FONTS["regular"])
+	if err != nil {
+		b.Fatal(err)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, 3000, 300))
+	labels := []string{"SONY ILCE-7M4", "FE 50mm F1.4 GM", "50mm  f/2.8  1/250s  ISO400", "2024/05/01 12:34"}
+
+	faces := map[string]func() font.Face{
+		"uncached": func() font.Face {
+			return truetype.NewFace(fnt, &truetype.Options{Size: FONT_SIZE})
+		},
+		"cached": func() font.Face {
+			return &cachedFace{Face: truetype.NewFace(fnt, &truetype.Options{Size: FONT_SIZE}), name: "regular", size: FONT_SIZE, cache: GLYPH_CACHE}
+		},
+	}
+
+	for _, name := range []string{"uncached", "cached"} {
+		b.Run(name, func(b *testing.B) {
+			for b.Loop() {
+				// 画像ごとにFaceを作る
+				d := &font.Drawer{Dst: dst, Src: image.Black, Face: faces[name]()}
+				for i, label := range labels {
+					d.Dot = fixed.P(0, 150+i%2*100)
+					d.DrawString(label)
+				}
+			}
+		})
+	}
+}
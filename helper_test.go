@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"net/url"
+	"testing"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+// テスト用の画像に書き込むExifのタグ
+type testTag struct {
+	ifd   string // IFD_PATH, EXIF_IFD_PATH, GPS_IFD_PATH
+	name  string
+	value any
+}
+
+// 規格の型に関わらずSHORTで書き込む値 (整数で記録するExifの書き込みソフトを再現する)
+type testShort []uint16
+
+// 既定値の設定 (serve と同じ)
+func testConfig(t testing.TB) *Config {
+	t.Helper()
+
+	config, err := configFromQuery(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+// 単色の画像
+func testImage(width, height int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+// PNGの IHDR (シグネチャ8バイト + チャンク25バイト) の後にチャンクを入れる
+func testPngChunk(png []byte, chunkType string, content []byte) []byte {
+	body := append([]byte(chunkType), content...)
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(body)-4))
+	chunk = append(chunk, body...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(body))
+
+	return append(append(append([]byte{}, png[:33]...), chunk...), png[33:]...)
+}
+
+// 指定したタグのExifを持つJPEG (タグの無いIFDは作らない)
+func testJpeg(t testing.TB, width, height int, tags ...testTag) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(width, height, color.Gray{128}), nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) == 0 {
+		return buf.Bytes()
+	}
+
+	var out bytes.Buffer
+	if err := writeJpegWithExif(&out, buf.Bytes(), testExif(t, tags...)); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+// 指定したタグのExifデータ (ヘッダーを除く)
+func testExif(t testing.TB, tags ...testTag) []byte {
+	t.Helper()
+
+	im, err := exifcommon.NewIfdMappingWithStandard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ti := exif.NewTagIndex()
+
+	root := exif.NewIfdBuilder(im, ti, exifcommon.IfdStandardIfdIdentity, binary.BigEndian)
+	identities := map[string]*exifcommon.IfdIdentity{
+		EXIF_IFD_PATH: exifcommon.IfdExifStandardIfdIdentity,
+		GPS_IFD_PATH:  exifcommon.IfdGpsInfoStandardIfdIdentity,
+	}
+	children := map[string]*exif.IfdBuilder{}
+
+	for _, tag := range tags {
+		ib := root
+		if tag.ifd != IFD_PATH {
+			ib = children[tag.ifd]
+			if ib == nil {
+				ib = exif.NewIfdBuilder(im, ti, identities[tag.ifd], binary.BigEndian)
+				children[tag.ifd] = ib
+			}
+		}
+		if err := addTestTag(ib, ti, tag); err != nil {
+			t.Fatalf("%s %s: %v", tag.ifd, tag.name, err)
+		}
+	}
+
+	for _, path := range []string{EXIF_IFD_PATH, GPS_IFD_PATH} {
+		if ib := children[path]; ib != nil {
+			if err := root.AddChildIb(ib); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	data, err := exif.NewIfdByteEncoder().EncodeToExif(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func addTestTag(ib *exif.IfdBuilder, ti *exif.TagIndex, tag testTag) error {
+	values, ok := tag.value.(testShort)
+	if !ok {
+		return ib.AddStandardWithName(tag.name, tag.value)
+	}
+
+	it, err := ti.GetWithName(ib.IfdIdentity(), tag.name)
+	if err != nil {
+		return err
+	}
+
+	var raw []byte
+	for _, v := range values {
+		raw = binary.BigEndian.AppendUint16(raw, v)
+	}
+	value := exif.NewIfdBuilderTagValueFromBytes(raw)
+	return ib.Add(exif.NewBuilderTag(ib.IfdIdentity().UnindexedString(), it.Id, exifcommon.TypeShort, value, binary.BigEndian))
+}
+
+// カメラが記録する主なタグ (GPS IFDは無い)
+func testCameraTags() []testTag {
+	return []testTag{
+		{IFD_PATH, "Make", "SONY"},
+		{IFD_PATH, "Model", "ILCE-7M4"},
+		{EXIF_IFD_PATH, "DateTimeOriginal", "2024:05:01 12:34:56"},
+		{EXIF_IFD_PATH, "FNumber", []exifcommon.Rational{{Numerator: 28, Denominator: 10}}},
+		{EXIF_IFD_PATH, "ExposureTime", []exifcommon.Rational{{Numerator: 1, Denominator: 250}}},
+		{EXIF_IFD_PATH, "ISOSpeedRatings", []uint16{400}},
+		{EXIF_IFD_PATH, "FocalLength", []exifcommon.Rational{{Numerator: 50, Denominator: 1}}},
+		{EXIF_IFD_PATH, "LensModel", "FE 50mm F1.4 GM"},
+	}
+}
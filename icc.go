@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+)
+
+/*
+# ICCプロファイル
+
+- JPEG: APP2 に "ICC_PROFILE\0" + 連番(1Byte) + 総数(1Byte) + プロファイルを分割して格納する
+- PNG: IHDR の直後に iCCP チャンク (名前 + NUL + 圧縮方式 + zlib圧縮したプロファイル) を置く
+*/
+
+const (
+	APP2_MARKER          = 0xe2
+	ICC_JPEG_HEADER      = "ICC_PROFILE\x00"
+	ICC_JPEG_CHUNK_SIZE  = 0xffff - 2 - len(ICC_JPEG_HEADER) - 2
+	ICC_HEADER_SIZE      = 128
+	ICC_SIGNATURE_OFFSET = 36
+	ICC_SIGNATURE        = "acsp"
+	ICC_PNG_NAME         = "ICC Profile"
+	PNG_IHDR_END         = 8 + 4 + 4 + 13 + 4 // シグネチャ + IHDRチャンク
+)
+
+// -icc-embed で指定されたICCプロファイルを読み込み、ヘッダーを検証する
+func loadIccProfile(path string) ([]byte, error) {
+	profile, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(profile) < ICC_HEADER_SIZE {
+		return nil, fmt.Errorf("%s: too short for ICC profile: %d bytes", path, len(profile))
+	}
+
+	// ヘッダーの先頭4バイトはプロファイル全体のサイズ
+	if size := binary.BigEndian.Uint32(profile); int(size) != len(profile) {
+		return nil, fmt.Errorf("%s: profile size in header is %d, file is %d bytes", path, size, len(profile))
+	}
+
+	if string(profile[ICC_SIGNATURE_OFFSET:ICC_SIGNATURE_OFFSET+4]) != ICC_SIGNATURE {
+		return nil, fmt.Errorf("%s: missing ICC profile signature %q", path, ICC_SIGNATURE)
+	}
+
+	return profile, nil
+}
+
+// JPEGのSOIの直後にICCプロファイルのAPP2セグメントを挿入する
+func insertJpegIcc(jpegData []byte, profile []byte) ([]byte, error) {
+	count := (len(profile) + ICC_JPEG_CHUNK_SIZE - 1) / ICC_JPEG_CHUNK_SIZE
+	if count > 0xff {
+		return nil, fmt.Errorf("ICC profile too large: %d bytes", len(profile))
+	}
+
+	var buf bytes.Buffer
+	buf.Write(jpegData[:2])
+	for i := 0; i < count; i++ {
+		chunk := profile[i*ICC_JPEG_CHUNK_SIZE : min((i+1)*ICC_JPEG_CHUNK_SIZE, len(profile))]
+		length := 2 + len(ICC_JPEG_HEADER) + 2 + len(chunk)
+
+		buf.Write([]byte{0xff, APP2_MARKER, byte(length >> 8), byte(length)})
+		buf.WriteString(ICC_JPEG_HEADER)
+		buf.Write([]byte{byte(i + 1), byte(count)})
+		buf.Write(chunk)
+	}
+	buf.Write(jpegData[2:])
+
+	return buf.Bytes(), nil
+}
+
+// PNGのIHDRの直後にICCプロファイルのiCCPチャンクを挿入する
+func insertPngIcc(pngData []byte, profile []byte) ([]byte, error) {
+	if len(pngData) < PNG_IHDR_END {
+		return nil, fmt.Errorf("invalid PNG data")
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(profile); err != nil {
+		return nil, fmt.Errorf("compressing ICC profile: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("compressing ICC profile: %w", err)
+	}
+
+	chunk := append([]byte("iCCP"+ICC_PNG_NAME+"\x00\x00"), compressed.Bytes()...)
+
+	var buf bytes.Buffer
+	buf.Write(pngData[:PNG_IHDR_END])
+	binary.Write(&buf, binary.BigEndian, uint32(len(chunk)-4))
+	buf.Write(chunk)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	buf.Write(pngData[PNG_IHDR_END:])
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"image"
+)
+
+/*
+# 内側の影 (-inner-shadow)
+
+フレームが写真の上に落とす影として、写真の周囲の内側を暗くし、マットに埋め込んだように見せる。
+影は写真の端で -inner-shadow-opacity の濃さになり、-inner-shadow-width の距離で消えるように2乗で薄くする。
+写真を描画した後に写真の範囲だけに描画するため、フレームやラベル領域の色は変わらない。
+*/
+
+const (
+	INNER_SHADOW_WIDTH   = 60
+	INNER_SHADOW_OPACITY = 0.5
+)
+
+// 写真の範囲の内側に影を描画する
+func drawInnerShadow(dst *image.RGBA, rect LayoutRect, width int, opacity float64) {
+	r := image.Rect(rect.X, rect.Y, rect.X+rect.Width, rect.Y+rect.Height).Intersect(dst.Bounds())
+	width = min(width, r.Dx()/2, r.Dy()/2)
+	if width <= 0 || opacity <= 0 {
+		return
+	}
+
+	// 端からの距離ごとに残す明るさ
+	keep := make([]float64, width)
+	for d := range keep {
+		t := 1 - (float64(d)+0.5)/float64(width)
+		keep[d] = 1 - opacity*t*t
+	}
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		dy := min(y-r.Min.Y, r.Max.Y-1-y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			d := min(dy, x-r.Min.X, r.Max.X-1-x)
+			if d >= width {
+				// 影の無い中央は飛ばす
+				x = max(x, r.Max.X-width-1)
+				continue
+			}
+
+			// 黒を重ねる (RGBAは乗算済みのため、アルファも黒の分だけ増やす)
+			k := keep[d]
+			i := dst.PixOffset(x, y)
+			p := dst.Pix[i : i+4 : i+4]
+			p[0] = uint8(float64(p[0]) * k)
+			p[1] = uint8(float64(p[1]) * k)
+			p[2] = uint8(float64(p[2]) * k)
+			p[3] = uint8(float64(p[3])*k + 255*(1-k))
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+)
+
+/*
+# レイアウト定義 (-layout-spec)
+
+ラベルの各行の文字列、フォント、色、位置をJSONで定義する。既定のレイアウトは layout.json。
+
+- band_height: ラベル領域の高さ (省略時は600)
+- text: ${Make} のようにExif情報の項目名を書くと値に置き換える。
+  ${Camera}, ${Lens} はメーカー名とモデル名を結合したもの (-no-model の場合は空)。
+  -gps-time の場合、${DateTimeOriginal} にはGPS時刻 (UTC) を併記する。
+  -infer-tz の場合はタイムゾーン (OffsetTimeOriginal、無ければGPS時刻との差) を併記する
+- font: bold, regular
+- align: left, center, right (ラベル領域の左端、中央、右端に揃える)
+- y: ラベル領域の上端からベースラインまでの距離 (ピクセル)
+- color: "#rrggbb" (省略時は文字色)
+- optional: 置き換え後の文字列が空の場合は描画しない
+- extend: 描画する場合はフォントの高さだけラベル領域を広げる
+- priority: -max-label-lines を超える場合に残す順 (大きい行から残す、省略時は0)
+
+-fields で行の name を並べると、指定した行だけを指定の順に描画する。
+各行は定義の順に並んだ位置 (font, size, align, y, extend) に前から順に配置する。
+gps は -gps-time、caption は comment と同じ。
+*/
+
+//go:embed layout.json
+var DEFAULT_LAYOUT_SPEC_JSON []byte
+
+var DEFAULT_LAYOUT_SPEC = mustParseLayoutSpec(DEFAULT_LAYOUT_SPEC_JSON)
+
+// レイアウト定義で使えるフォント
+var LAYOUT_FONTS = map[string][]byte{
+	"bold":    gomonobold.TTF,
+	"regular": gomono.TTF,
+}
+
+type LayoutSpec struct {
+	BandHeight int        `json:"band_height,omitempty"`
+	Lines      []LineSpec `json:"lines"`
+}
+
+type LineSpec struct {
+	Name     string  `json:"name"`
+	Text     string  `json:"text"`
+	Font     string  `json:"font"`
+	Size     float64 `json:"size"`
+	Align    string  `json:"align"`
+	Y        int     `json:"y"`
+	Color    string  `json:"color,omitempty"`
+	Optional bool    `json:"optional,omitempty"`
+	Extend   bool    `json:"extend,omitempty"`
+	Priority int     `json:"priority,omitempty"`
+
+	color *image.Uniform
+}
+
+// -layout-spec で指定されたJSONファイルを読み込む
+func loadLayoutSpec(path string) (*LayoutSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := parseLayoutSpec(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	return spec, nil
+}
+
+func parseLayoutSpec(data []byte) (*LayoutSpec, error) {
+	spec := &LayoutSpec{}
+	err := json.Unmarshal(data, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range spec.Lines {
+		line := &spec.Lines[i]
+
+		if _, ok := LAYOUT_FONTS[line.Font]; !ok {
+			return nil, fmt.Errorf("line %q: unknown font %q", line.Name, line.Font)
+		}
+		if line.Size <= 0 {
+			return nil, fmt.Errorf("line %q: size must be positive", line.Name)
+		}
+
+		switch line.Align {
+		case "left", "center", "right":
+		default:
+			return nil, fmt.Errorf("line %q: unknown align %q", line.Name, line.Align)
+		}
+
+		if line.Color != "" {
+			line.color, err = parseHexColor(line.Color)
+			if err != nil {
+				return nil, fmt.Errorf("line %q: %w", line.Name, err)
+			}
+		}
+	}
+
+	return spec, nil
+}
+
+// -fields の値 (例: "camera,exposure,date") を行の名前の一覧にする
+// gps と caption (comment) は対応するフラグも有効にする
+func parseFields(value string, spec *LayoutSpec, config *Config) ([]string, error) {
+	var fields []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "gps":
+			config.gpsTime = true
+			continue
+		case "caption":
+			name = "comment"
+		}
+
+		if !slices.ContainsFunc(spec.Lines, func(line LineSpec) bool { return line.Name == name }) {
+			return nil, fmt.Errorf("unknown field: %q", name)
+		}
+		if name == "comment" {
+			config.drawComment = true
+		}
+		fields = append(fields, name)
+	}
+
+	return fields, nil
+}
+
+// -fields で指定された行を指定の順に、定義の行の位置に並べ直す
+func selectLines(spec *LayoutSpec, fields []string) []LineSpec {
+	if fields == nil {
+		return spec.Lines
+	}
+
+	var lines []LineSpec
+	for i, name := range fields {
+		if i >= len(spec.Lines) {
+			break
+		}
+
+		for _, line := range spec.Lines {
+			if line.Name != name {
+				continue
+			}
+
+			slot := spec.Lines[i]
+			line.Font, line.Size, line.Align, line.Y, line.Extend = slot.Font, slot.Size, slot.Align, slot.Y, slot.Extend
+			lines = append(lines, line)
+			break
+		}
+	}
+
+	return lines
+}
+
+func mustParseLayoutSpec(data []byte) *LayoutSpec {
+	spec, err := parseLayoutSpec(data)
+	if err != nil {
+		panic(fmt.Sprintf("parsing default layout spec: %v", err))
+	}
+
+	return spec
+}
+
+// レイアウト定義の ${...} に使えるExif情報
+func layoutFields(exifData *ExifData, config *Config) map[string]string {
+	fields := map[string]string{
+		"Make":                    exifData.Make,
+		"Model":                   exifData.Model,
+		"LensMake":                exifData.LensMake,
+		"LensModel":               exifData.LensModel,
+		"ExposureTime":            exifData.ExposureTime,
+		"FNumber":                 exifData.FNumber,
+		"PhotographicSensitivity": exifData.PhotographicSensitivity,
+		"FocalLengthIn35mmFilm":   exifData.FocalLengthIn35mmFilm,
+		"FocalLength":             exifData.FocalLength,
+		"DateTimeOriginal":        exifData.DateTimeOriginal,
+		"PixelXDimension":         strconv.Itoa(exifData.PixelXDimension),
+		"PixelYDimension":         strconv.Itoa(exifData.PixelYDimension),
+		"Orientation":             exifData.Orientation,
+		"Rating":                  strconv.Itoa(exifData.Rating),
+	}
+
+	if !config.noIsoGrouping {
+		fields["PhotographicSensitivity"] = groupIso(exifData.PhotographicSensitivity)
+	}
+
+	if !config.noModelData {
+		fields["Camera"] = joinMakeModel(exifData.Make, exifData.Model, !config.noDedup)
+		fields["Lens"] = joinMakeModel(exifData.LensMake, exifData.LensModel, !config.noDedup)
+	}
+
+	// テレコンバーターを付けた場合はレンズ名に併記する (例: "M.Zuiko 150-400mm + MC-14")
+	// LensModel にすでに含まれている場合は付けない
+	fields["Teleconverter"] = exifData.Teleconverter
+	if !config.noModelData && exifData.Teleconverter != "" &&
+		!strings.Contains(strings.ToUpper(fields["Lens"]), strings.ToUpper(exifData.Teleconverter)) {
+		fields["Lens"] = strings.TrimSpace(fields["Lens"] + " + " + exifData.Teleconverter)
+	}
+
+	// -sensor の場合はカメラ名にセンサーのフォーマットを併記する
+	fields["SensorFormat"] = exifData.SensorFormat
+	if config.sensor && !config.noModelData && exifData.SensorFormat != "" {
+		fields["Camera"] = strings.TrimSpace(fields["Camera"] + "  " + exifData.SensorFormat)
+	}
+
+	// -inventory の場合はカメラ名にファームウェアのバージョンを併記する
+	fields["Firmware"] = exifData.Firmware
+	if config.inventory && !config.noModelData && exifData.Firmware != "" {
+		fields["Camera"] = strings.TrimSpace(fields["Camera"] + "  " + FIRMWARE_PREFIX + exifData.Firmware)
+	}
+
+	fields["GPSDateTime"] = exifData.GPSDateTime
+	fields["Keywords"] = exifData.Keywords
+	fields["FocusDistance"] = exifData.FocusDistance
+
+	// -focus の場合はレンズ名に被写体距離を併記する
+	if config.focus && !config.noModelData && exifData.FocusDistance != "" {
+		fields["Lens"] = strings.TrimSpace(fields["Lens"] + "  " + exifData.FocusDistance)
+	}
+	fields["OffsetTimeOriginal"] = exifData.OffsetTimeOriginal
+
+	// -infer-tz の場合は撮影日時にタイムゾーンを併記する
+	if config.inferTz {
+		if offset := captureTimeZone(exifData); offset != "" {
+			fields["DateTimeOriginal"] += " " + offset
+		}
+	}
+
+	// -gps-time の場合は撮影日時にUTCの時刻を併記する
+	if config.gpsTime && exifData.GPSDateTime != "" {
+		fields["DateTimeOriginal"] = strings.TrimSpace(fields["DateTimeOriginal"] + "  UTC " + exifData.GPSDateTime)
+	}
+
+	if config.drawComment {
+		fields["UserComment"] = exifData.UserComment
+	}
+
+	return fields
+}
+
+// 5桁以上のISO感度を3桁ごとに区切る ("102400" -> "102,400", "6400" はそのまま)
+func groupIso(value string) string {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 9999 {
+		return value
+	}
+
+	s := strconv.Itoa(n)
+	var b strings.Builder
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
+
+// ラベルの描画に使うフォント (フォント名とサイズごとにFaceを作成する)
+type Faces struct {
+	family string  // -font-name のファミリー名 (組み込みのフォントの場合は空)
+	points float64 // -font-size-pt の場合、レイアウト定義の1ピクセルあたりのポイント数 (それ以外は0)
+	dpi    float64 // -font-size-pt の場合の -dpi
+	fonts  map[string]*truetype.Font
+	faces  map[faceKey]font.Face
+}
+
+type faceKey struct {
+	name string
+	size float64
+}
+
+// config.fonts (-font-name) があれば組み込みのフォントの代わりに使う
+func loadFaces(config *Config) (*Faces, error) {
+	faces := &Faces{
+		fonts: map[string]*truetype.Font{},
+		faces: map[faceKey]font.Face{},
+	}
+
+	fonts := LAYOUT_FONTS
+	if config.fonts != nil {
+		fonts = config.fonts
+		faces.family = config.fontName
+	}
+
+	// -font-size-pt の場合は FONT_SIZE の行が指定したポイントになるようにする
+	if config.fontSizePt > 0 {
+		faces.points = config.fontSizePt / FONT_SIZE
+		faces.dpi = config.dpi
+	}
+
+	for name, ttf := range fonts {
+		fnt, err := truetype.Parse(ttf)
+		if err != nil {
+			return nil, fmt.Errorf("parsing font: %w", err)
+		}
+		faces.fonts[name] = fnt
+	}
+
+	return faces, nil
+}
+
+// フォント名とサイズに対応するFaceを返す (フォント名はparseLayoutSpecで検証済み)
+func (f *Faces) face(name string, size float64) font.Face {
+	key := faceKey{name, size}
+	if face, ok := f.faces[key]; ok {
+		return face
+	}
+
+	// -font-size-pt の場合はポイントとDPIで描画する (グリフのキャッシュは実際のピクセルの大きさで区別する)
+	options := &truetype.Options{Size: size}
+	pixels := size
+	if f.points > 0 {
+		options.Size, options.DPI = size*f.points, f.dpi
+		pixels = pointsToPixels(options.Size, options.DPI)
+	}
+
+	face := &cachedFace{
+		Face:  truetype.NewFace(f.fonts[name], options),
+		name:  f.family + "/" + name,
+		size:  pixels,
+		cache: GLYPH_CACHE,
+	}
+	f.faces[key] = face
+
+	return face
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestGroupIso(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"100", "100"},
+		{"6400", "6400"},
+		{"9999", "9999"},
+		{"10000", "10,000"},
+		{"102400", "102,400"},
+		{"3280000", "3,280,000"},
+		{"", ""},
+		{"Auto", "Auto"},
+	}
+
+	for _, tt := range tests {
+		if got := groupIso(tt.value); got != tt.want {
+			t.Errorf("groupIso(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLayoutFieldsIsoGrouping(t *testing.T) {
+	exifData := &ExifData{PhotographicSensitivity: "102400"}
+
+	for noIsoGrouping, want := range map[bool]string{false: "102,400", true: "102400"} {
+		config := testConfig(t)
+		config.noIsoGrouping = noIsoGrouping
+		if got := layoutFields(exifData, config)["PhotographicSensitivity"]; got != want {
+			t.Errorf("noIsoGrouping %v: PhotographicSensitivity = %q, want %q", noIsoGrouping, got, want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+/*
+# メッセージの出力 (-quiet, -verbose, -debug)
+
+エラーや進行状況のメッセージはレベルを付けて出力し、表示するかどうかをまとめて切り替える。
+
+- -quiet: エラーだけを表示する
+- 指定なし: エラーと警告を表示する
+- -verbose: 出力したファイル名などの情報も表示する
+- -debug: タグごとの読み込みの詳細も表示する
+
+エラーはレベルにかかわらず常に標準エラー出力に書く。警告とデバッグ情報も標準エラー出力、情報は標準出力に書く。
+exif や check サブコマンド、-validate、-report、-stats の結果はメッセージではないため、このレベルにかかわらず出力する。
+exiframe serve は待ち受けるアドレスを表示するため、情報まで表示する。
+*/
+
+// メッセージのレベル
+const (
+	LOG_ERROR = iota
+	LOG_WARN
+	LOG_INFO
+	LOG_DEBUG
+)
+
+// 表示するメッセージのレベル (このレベル以下を表示する)
+var logLevel = LOG_WARN
+
+// エラーを表示する (-quiet でも表示する)
+func logError(a ...any) {
+	fmt.Fprintln(os.Stderr, a...)
+}
+
+// 警告を表示する
+func logWarn(a ...any) {
+	if logLevel >= LOG_WARN {
+		fmt.Fprintln(os.Stderr, a...)
+	}
+}
+
+// 情報を表示する (-verbose)
+func logInfo(a ...any) {
+	if logLevel >= LOG_INFO {
+		fmt.Fprintln(os.Stdout, a...)
+	}
+}
+
+// タグの読み込みなどの詳細を表示する (-debug)
+func logDebug(a ...any) {
+	if logLevel >= LOG_DEBUG {
+		fmt.Fprintln(os.Stderr, a...)
+	}
+}
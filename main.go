@@ -1,24 +1,34 @@
 package main
 
 import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
 	"image/jpeg"
+	"io"
+	"math"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf16"
 
 	"github.com/disintegration/imaging"
 	"github.com/dsoprea/go-exif/v3"
 	exifcommon "github.com/dsoprea/go-exif/v3/common"
-	"github.com/golang/freetype/truetype"
 	"golang.org/x/image/font"
-	"golang.org/x/image/font/gofont/gomono"
-	"golang.org/x/image/font/gofont/gomonobold"
 	"golang.org/x/image/math/fixed"
 )
 
@@ -57,29 +67,135 @@ type ExifData struct {
 	FocalLengthIn35mmFilm   string // 35mm換算レンズ焦点距離 [TAG=0xa405]
 	FocalLength             string // レンズ焦点距離 [TAG=0x920a]
 
-	DateTimeOriginal string // 原画像データの生成日時 [TAG=0x9003]
-	PixelXDimension  int    // 実効画像幅 [TAG=0xa002]
-	PixelYDimension  int    // 実効画像高さ [TAG=0xa003]
-	Orientation      string // 画像の向き [TAG=0x0112]
+	DateTimeOriginal   string // 原画像データの生成日時 [TAG=0x9003]
+	OffsetTimeOriginal string // 原画像データの生成日時のタイムゾーン [TAG=0x9011]
+	PixelXDimension    int    // 実効画像幅 [TAG=0xa002]
+	PixelYDimension    int    // 実効画像高さ [TAG=0xa003]
+	Orientation        string // 画像の向き [TAG=0x0112]
+	UserComment        string // ユーザーコメント [TAG=0x9286]
+	GPSDateTime        string // GPS時刻 (UTC) [TAG=0x001d, 0x0007]
+	Rating             int    // レーティング (0-5、無い場合は0) [TAG=0x4746]
+	FocusDistance      string // 被写体距離 ("2.3m", 無限遠は "∞") [TAG=0x9206]
+	Teleconverter      string // テレコンバーター (MakerNote、Olympus / OM System のみ)
+	Keywords           string // キーワード (XMPの dc:subject を ", " でつないだもの)
+	SensorFormat       string // センサーのフォーマット ("Full Frame", "APS-C" など) [TAG=0xa20e, 0xa20f, 0xa210]
+	Firmware           string // ファームウェアのバージョン ("1.10"、MakerNote または Software から取得できる場合のみ) [TAG=0x0131]
 }
 
 // go-exiframeの設定
 type Config struct {
-	filePath        string
-	frameColorBlack bool
-	noFrame         bool
-	noModelData     bool
+	filePath         string
+	frameColorBlack  bool
+	noFrame          bool
+	noModelData      bool
+	keepExif         bool
+	stripGps         bool
+	verbose          bool
+	layoutJson       bool
+	drawComment      bool
+	dumpJson         bool
+	apertureIcon     bool
+	borderOnly       bool
+	bandOnly         bool
+	noDedup          bool
+	textOutline      bool
+	textOutlineWidth int
+	frameInset       int
+	labelGap         int
+	gpsTime          bool
+	maxMegapixels    float64
+	minRating        int
+	verticalText     string
+	portraitText     string
+	bestEffort       bool
+	brightness       float64
+	contrast         float64
+	trim             bool
+	trimTolerance    int
+	fontName         string
+	table            bool
+	report           bool
+	inferTz          bool
+	inPlace          bool
+	backup           bool
+	noIsoGrouping    bool
+	progressive      bool
+	stats            bool
+	renameIgnoreCase bool
+	backgroundMode   string
+	faststart        bool
+	reveal           bool
+	compact          bool
+	focus            bool
+	textBackground   string
+	sizes            []int
+	focalBar         bool
+	frameColorMode   string
+	seed             uint64 // 乱数を使う処理 (-frame-color palette) のシード
+	keepTimestamps   bool
+	columns          int
+	tonemap          bool
+	footerText       string
+	footerAlign      string
+	aspect           float64 // -aspect の幅÷高さ (0の場合は切り抜かない)
+	mark             bool
+	bandBlur         bool
+	maxLabelLines    int
+	extractThumb     bool
+	innerShadow      bool
+	shadowWidth      int
+	shadowOpacity    float64
+	keywords         bool
+	sensor           bool
+	fontBaselines    bool
+	fitLabels        bool
+	inventory        bool
+	fontSizePt       float64
+	dpi              float64
+	borderSlice      [4]int // -border-slice の上、右、下、左 (全て0の場合は画像の3分の1)
+	borderRepeat     string
+	exifSidecar      bool
+	frameRadius      int
+	roundFrame       bool
+	exifTimestamp    bool
+	compactSeparator string
 
 	fileName   string
 	frameColor *image.Uniform
 	textColor  *image.Uniform
+	insetColor *image.Uniform
+	layoutSpec *LayoutSpec
+	iccProfile []byte
+	background image.Image // -background の画像
+	footerLogo image.Image // -footer-logo の画像
+	border     image.Image // -border-image の画像
+	manual     *ExifData
+	workers    int
+	formats    []string
+	fields     []string
+	renames    []RenameRule
+	exifFields []ExifField
+	excludes   []string // -exclude のパターン (-zip のエントリに使う)
+	exifLines  []string // -exif-field で読み込んだ行 (ファイルごと)
+	fonts      map[string][]byte
+	timing     *Stats // -stats の場合の処理時間 (ファイルごと)
+	rawExif    []byte
+	lensDb     map[string]string
+	faces      *Faces // NewRenderer で準備したFace (nil の場合は描画ごとに作る)
+	exifData   *ExifData
+	layout     *Layout  // -mark の印に入れるレイアウト
+	settings   []string // -mark の印に入れるオプション
+
+	// -theme で指定された色
+	themeFrameColor *image.Uniform
+	themeTextColor  *image.Uniform
+
+	// フレームを付けた画像をエンコードする前に加工する (ライブラリとして使う場合のみ、CLIでは使わない)
+	// エラーを返した場合は出力しない
+	PostProcess func(image.Image) (image.Image, error)
 }
 
 var (
-	filePath     string
-	framePixel   int = 180
-	noFramePixel int = 0
-
 	IFD_PATH_MAP = map[string]struct {
 		tagId uint16
 		path  string
@@ -94,9 +210,25 @@ var (
 		"FocalLengthIn35mmFilm":   {0xa405, EXIF_IFD_PATH},
 		"FocalLength":             {0x920a, EXIF_IFD_PATH},
 		"DateTimeOriginal":        {0x9003, EXIF_IFD_PATH},
+		"OffsetTimeOriginal":      {0x9011, EXIF_IFD_PATH},
 		"PixelXDimension":         {0xa002, EXIF_IFD_PATH},
 		"PixelYDimension":         {0xa003, EXIF_IFD_PATH},
 		"Orientation":             {0x0112, IFD_PATH},
+		"UserComment":             {0x9286, EXIF_IFD_PATH},
+		"GPSDateTime":             {GPS_TIME_STAMP_TAG_ID, GPS_IFD_PATH},
+		"Rating":                  {0x4746, IFD_PATH},
+		"FocusDistance":           {0x9206, EXIF_IFD_PATH},
+	}
+
+	// DateTimeOriginalとして受け付けるフォーマット (先頭から順に試す)
+	DATE_TIME_LAYOUTS = []struct {
+		layout string
+		output string
+	}{
+		{"2006:01:02 15:04:05", "2006/01/02 15:04"},
+		{"2006:01:02 15:04", "2006/01/02 15:04"},
+		{"2006:01:02 15", "2006/01/02 15:00"},
+		{"2006:01:02", "2006/01/02"},
 	}
 )
 
@@ -105,289 +237,2229 @@ const (
 	EXIF_IFD_PATH = "IFD/Exif"
 	GPS_IFD_PATH  = "IFD/GPSInfo"
 
+	FRAME_PIXEL       = 180
 	EXIF_LABEL_HEIGHT = 600
 	LARGE_FONT_SIZE   = 200
 	FONT_SIZE         = 150
 
 	FILE_NAME_PREFIX = "exiframe-"
+
+	INSET_COLOR = "#e5e5e5"
+
+	TEXT_OUTLINE_WIDTH     = 2
+	MAX_TEXT_OUTLINE_WIDTH = 3
+
+	ORIENTATION_TAG_ID    = 0x0112
+	GPS_DATE_STAMP_TAG_ID = 0x001d
+	GPS_TIME_STAMP_TAG_ID = 0x0007
+	SHUTTER_SPEED_TAG_ID  = 0x9201
+	APP1_MARKER           = 0xe1
+	EXIF_HEADER           = "Exif\x00\x00"
+
+	// 小数で記録されたExposureTimeを 1/N 秒とみなす誤差 (Nに対する割合)
+	EXPOSURE_FRACTION_TOLERANCE = 0.01
 )
 
-func getExif(config *Config) (exifData *ExifData) {
-	rawExif, err := exif.SearchFileAndExtractExif(config.filePath)
+// -max-megapixels を超える画像のエラー
+var ErrImageTooLarge = errors.New("image too large")
+
+// -min-rating に満たない画像のエラー
+var ErrBelowMinRating = errors.New("rating below minimum")
+
+// UserCommentの文字コード (先頭8バイト、大文字小文字は区別しない)
+var (
+	USER_COMMENT_ASCII   = []byte("ASCII\x00\x00\x00")
+	USER_COMMENT_JIS     = []byte("JIS\x00\x00\x00\x00\x00")
+	USER_COMMENT_UNICODE = []byte("UNICODE\x00")
+)
+
+func getExif(data []byte, config *Config) (exifData *ExifData, err error) {
+	rawExif, err := extractRawExif(data)
+	if errors.Is(err, exif.ErrNoExif) && (config.manual != nil || rarelyHasExif(data)) {
+		// フィルムのスキャンなどExifが無い画像は手入力の値だけを使う
+		// WebP / AVIF / BMP / PNG / GIF はExifが無いことが多いため、手入力が無くても空のExif情報とする
+		exifData = &ExifData{Keywords: readKeywords(data)}
+		if config.manual != nil {
+			applyManualExif(exifData, config.manual)
+		}
+		config.exifData = exifData
+		return exifData, nil
+	}
 	if err != nil {
-		fmt.Println("Error SearchFileAndExtractExif:", err)
-		os.Exit(1)
+		return nil, decodeError(err)
 	}
 
-	im, _ := exifcommon.NewIfdMappingWithStandard()
+	config.rawExif = rawExif
+
+	im, err := exifcommon.NewIfdMappingWithStandard()
 	if err != nil {
-		fmt.Println("Error NewIfdMappingWithStandard:", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("NewIfdMappingWithStandard: %w", err)
 	}
 
-	ti := exif.NewTagIndex()
+	ti, err := newExifTagIndex()
+	if err != nil {
+		return nil, fmt.Errorf("newExifTagIndex: %w", err)
+	}
 
 	_, index, err := exif.Collect(im, ti, rawExif)
 	if err != nil {
-		fmt.Println("Error Collect:", err)
-		os.Exit(1)
+		return nil, decodeError(fmt.Errorf("Collect: %w", err))
 	}
 
 	rootIfd := index.RootIfd
 
 	exifData = &ExifData{}
+	config.exifData = exifData
 
-	for tagName, tagInfo := range IFD_PATH_MAP {
-		tagId := tagInfo.tagId
-		ifdPath := tagInfo.path
+	for tagName := range IFD_PATH_MAP {
+		err := readExifTag(rootIfd, tagName, exifData, config)
+		if err != nil {
+			// ReadExifBestEffort の場合は読み込めないタグを無視する
+			if config.bestEffort {
+				continue
+			}
+			return nil, decodeError(err)
+		}
+	}
 
-		ifd, err := exif.FindIfdFromRootIfd(rootIfd, ifdPath)
+	// ExposureTimeが無い場合はShutterSpeedValue (APEX) から求める
+	if exifData.ExposureTime == "" {
+		exposureTime, err := readShutterSpeedValue(rootIfd)
 		if err != nil {
-			fmt.Println("Error FindIfdFromRootIfd:", err)
-			os.Exit(1)
+			logWarn("Error parsing ShutterSpeedValue:", err)
+		} else {
+			exifData.ExposureTime = exposureTime
 		}
+	}
+
+	// LensModelが無い場合はMakerNoteのレンズIDから求める
+	if exifData.LensModel == "" {
+		exifIfd, err := exif.FindIfdFromRootIfd(rootIfd, EXIF_IFD_PATH)
+		if err == nil {
+			lensModel, err := resolveLensModel(rawExif, exifIfd, exifData.Make, config.lensDb)
+			if err != nil {
+				logWarn("Error resolving lens ID:", err)
+			} else {
+				exifData.LensModel = lensModel
+			}
+		}
+	}
 
-		results, err := ifd.FindTagWithId(tagId)
+	// テレコンバーターはMakerNoteにのみ記録される (記録が無い場合はレンズ名だけを表示する)
+	if exifIfd, err := exif.FindIfdFromRootIfd(rootIfd, EXIF_IFD_PATH); err == nil {
+		teleconverter, err := resolveTeleconverter(exifIfd)
 		if err != nil {
-			fmt.Println("Error FindTagWithName:", err)
-			os.Exit(1)
+			logWarn("Error reading teleconverter:", err)
+		} else {
+			exifData.Teleconverter = teleconverter
 		}
 
-		if len(results) == 0 {
-			fmt.Printf("Tag %s not found\n", tagName)
-			continue
+		// センサーのフォーマットは撮像面の解像度と画素数から求める
+		sensorFormat, err := readSensorFormat(exifIfd, exifData.PixelXDimension, exifData.PixelYDimension)
+		if err != nil {
+			logWarn("Error reading focal plane resolution:", err)
+		} else {
+			exifData.SensorFormat = sensorFormat
 		}
+	}
 
-		item := results[0]
-
-		value, _ := item.FormatFirst()
-		if err != nil {
-			fmt.Println("Error Value:", err)
-			os.Exit(1)
-		}
-
-		switch tagName {
-		case "Make":
-			exifData.Make = value
-		case "Model":
-			exifData.Model = value
-		case "LensMake":
-			exifData.LensMake = value
-		case "LensModel":
-			exifData.LensModel = value
-		case "ExposureTime":
-			exifData.ExposureTime = value
-		case "FNumber":
-			var output string
-			if strings.Contains(value, "/") {
-				parts := strings.Split(value, "/")
-				numerator, _ := strconv.Atoi(parts[0])
-				denominator, _ := strconv.Atoi(parts[1])
-				f := float32(numerator) / float32(denominator)
-				output = fmt.Sprintf("%.1f", f)
-			} else {
-				output = value
-			}
+	// -exif-field で指定したタグを読み込む
+	config.exifLines = readExifFields(rootIfd, config.exifFields)
 
-			exifData.FNumber = output
-		case "PhotographicSensitivity":
-			exifData.PhotographicSensitivity = value
-		case "FocalLengthIn35mmFilm":
-			exifData.FocalLengthIn35mmFilm = value
-		case "FocalLength":
-			exifData.FocalLength = value
-		case "DateTimeOriginal":
-			t, err := time.Parse("2006:01:02 15:04:05", value)
-			if err != nil {
-				fmt.Println("Error parsing DateTimeOriginal:", err)
-				continue
+	// ファームウェアはメーカーごとに記録方法が異なるため取得できる場合だけ使う
+	exifIfd, _ := exif.FindIfdFromRootIfd(rootIfd, EXIF_IFD_PATH)
+	firmware, err := readFirmware(rawExif, rootIfd, exifIfd, exifData.Make)
+	if err != nil {
+		logWarn("Error reading firmware version:", err)
+	} else {
+		exifData.Firmware = firmware
+	}
+
+	exifData.Keywords = readKeywords(data)
+
+	// -report の場合はカメラが記録した項目の充足度を表示する (手入力の値は含めない)
+	// -json の場合はJSONと混ざらないよう標準エラー出力に書く
+	if config.report || config.verbose {
+		out := os.Stdout
+		if config.layoutJson {
+			out = os.Stderr
+		}
+		fmt.Fprintln(out, formatReport(config.fileName, exifData))
+	}
+
+	if config.manual != nil {
+		applyManualExif(exifData, config.manual)
+	}
+
+	return exifData, nil
+}
+
+// 規格の型 (RATIONAL) 以外に整数 (SHORT, LONG) でも記録されることがあるタグ
+// go-exif は型が規格と異なるタグを読み飛ばすため、受け付ける型に加える
+var INTEGER_RATIONAL_TAGS = []string{"ExposureTime", "FNumber"}
+
+// ラベルに使うタグを読むための TagIndex
+func newExifTagIndex() (*exif.TagIndex, error) {
+	ti := exif.NewTagIndex()
+	for _, tagName := range INTEGER_RATIONAL_TAGS {
+		it, err := ti.Get(exifcommon.IfdExifStandardIfdIdentity, IFD_PATH_MAP[tagName].tagId)
+		if err != nil {
+			return nil, err
+		}
+		it.SupportedTypes = append(it.SupportedTypes, exifcommon.TypeShort, exifcommon.TypeLong)
+	}
+	return ti, nil
+}
+
+// タグを1つ読み込んでexifDataに設定する
+func readExifTag(rootIfd *exif.Ifd, tagName string, exifData *ExifData, config *Config) error {
+	tagInfo := IFD_PATH_MAP[tagName]
+
+	// GPS IFDのように、IFD自体が無い場合もタグが無いものとして扱う
+	// (FindIfdFromRootIfd はIFDが無い場合に ErrTagNotFound ではないエラーを返す)
+	ifd, err := exif.FindIfdFromRootIfd(rootIfd, tagInfo.path)
+	if err != nil {
+		logDebug("IFD", tagInfo.path, "not found for", tagName)
+		return nil
+	}
+
+	results, err := ifd.FindTagWithId(tagInfo.tagId)
+	if err != nil && !errors.Is(err, exif.ErrTagNotFound) {
+		return fmt.Errorf("FindTagWithId: %w", err)
+	}
+
+	if len(results) == 0 {
+		logDebug("Tag", tagName, "not found")
+		return nil
+	}
+
+	item := results[0]
+
+	// GPSTimeStamp (時, 分, 秒の有理数) とGPSDateStamp ("YYYY:MM:DD") を組み合わせる
+	if tagName == "GPSDateTime" {
+		gpsDateTime, err := readGpsDateTime(ifd, item)
+		if err != nil {
+			logWarn("Error parsing GPS time:", err)
+			return nil
+		}
+
+		exifData.GPSDateTime = gpsDateTime
+		return nil
+	}
+
+	// UserCommentは文字コードの接頭辞付きのため生データから解析する
+	if tagName == "UserComment" {
+		raw, err := item.GetRawBytes()
+		if err != nil {
+			return fmt.Errorf("GetRawBytes %s: %w", tagName, err)
+		}
+
+		comment, err := decodeUserComment(raw, ifd.ByteOrder())
+		if err != nil {
+			logWarn("Error decoding UserComment:", err)
+			return nil
+		}
+
+		exifData.UserComment = comment
+		return nil
+	}
+
+	// 値が複数あるタグもすべての値を読む (FormatFirst では2つ目以降の値が失われる)
+	values, err := formatValues(item)
+	if err != nil {
+		return fmt.Errorf("formatValues %s: %w", tagName, err)
+	}
+	value := values[0]
+
+	// 個数が不定のタグのため、0 (不明) でない最初の値を使う
+	if tagName == "PhotographicSensitivity" {
+		for _, v := range values {
+			if v != "0" {
+				value = v
+				break
 			}
-			output := t.Format("2006/01/02 15:04")
+		}
+	}
 
-			exifData.DateTimeOriginal = output
-		case "PixelXDimension":
-			output, err := strconv.Atoi(value)
-			if err != nil {
-				fmt.Println("Error parsing PixelXDimension:", err)
-				os.Exit(1)
+	// RegisterFormatter で登録された関数や組み込みの整形があれば使う
+	if applyFormatter(exifData, tagName, value) {
+		return nil
+	}
+
+	switch tagName {
+	case "Make":
+		exifData.Make = value
+	case "Model":
+		exifData.Model = value
+	case "LensMake":
+		exifData.LensMake = value
+	case "LensModel":
+		exifData.LensModel = value
+	case "PhotographicSensitivity":
+		exifData.PhotographicSensitivity = value
+	case "FocalLengthIn35mmFilm":
+		exifData.FocalLengthIn35mmFilm = value
+	case "FocalLength":
+		exifData.FocalLength = value
+	case "DateTimeOriginal":
+		output, layout, err := parseDateTimeOriginal(value)
+		if err != nil {
+			logWarn("Error parsing DateTimeOriginal:", err)
+			return nil
+		}
+		logDebug(fmt.Sprintf("DateTimeOriginal %q matched layout %q", value, layout))
+
+		exifData.DateTimeOriginal = output
+	case "OffsetTimeOriginal":
+		exifData.OffsetTimeOriginal = strings.TrimRight(value, " \x00")
+	case "PixelXDimension":
+		output, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("parsing PixelXDimension: %w", err)
+		}
+
+		exifData.PixelXDimension = output
+	case "PixelYDimension":
+		output, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("parsing PixelYDimension: %w", err)
+		}
+
+		exifData.PixelYDimension = output
+	case "Orientation":
+		exifData.Orientation = value
+	case "Rating":
+		output, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("parsing Rating: %w", err)
+		}
+
+		exifData.Rating = output
+	}
+
+	return nil
+}
+
+// Exifのタグ (-dump-json で出力)
+type RawTag struct {
+	IfdPath string `json:"ifd"`
+	TagId   string `json:"id"`
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+}
+
+// 全てのIFDのタグを取得する
+func collectRawTags(rawExif []byte) ([]RawTag, error) {
+	im, err := exifcommon.NewIfdMappingWithStandard()
+	if err != nil {
+		return nil, fmt.Errorf("NewIfdMappingWithStandard: %w", err)
+	}
+
+	ti := exif.NewTagIndex()
+
+	_, index, err := exif.Collect(im, ti, rawExif)
+	if err != nil {
+		return nil, fmt.Errorf("Collect: %w", err)
+	}
+
+	var tags []RawTag
+	for _, ifd := range index.Ifds {
+		for _, entry := range ifd.Entries() {
+			// 子IFDへのポインタは除く
+			if entry.ChildIfdPath() != "" {
+				continue
 			}
 
-			exifData.PixelXDimension = output
-		case "PixelYDimension":
-			output, err := strconv.Atoi(value)
+			value, err := entry.Format()
 			if err != nil {
-				fmt.Println("Error parsing PixelYDimension:", err)
-				os.Exit(1)
+				value = ""
 			}
 
-			exifData.PixelYDimension = output
-		case "Orientation":
-			exifData.Orientation = value
+			tags = append(tags, RawTag{
+				IfdPath: ifd.IfdIdentity().UnindexedString(),
+				TagId:   fmt.Sprintf("0x%04x", entry.TagId()),
+				Name:    entry.TagName(),
+				Value:   value,
+			})
 		}
 	}
 
-	return exifData
+	return tags, nil
 }
 
-func drawFrame(config *Config, exifData *ExifData) {
-	fSrc, err := os.Open(filePath)
+// -exif-to-sidecar のファイル名の末尾 (<output>.exif.json)
+const EXIF_SIDECAR_SUFFIX = ".exif.json"
+
+// 解析したExif情報をJSONファイルとして書き出す (-dump-json, -exif-to-sidecar)
+// Exif情報が何も得られなかった場合は書き出さない
+func writeExifJson(path string, config *Config) error {
+	if config.exifData == nil || len(config.rawExif) == 0 {
+		return nil
+	}
+
+	tags, err := collectRawTags(config.rawExif)
 	if err != nil {
-		fmt.Println("Error opening file:", err)
-		os.Exit(1)
+		return err
+	}
+
+	if *config.exifData == (ExifData{}) && len(tags) == 0 {
+		return nil
 	}
-	defer fSrc.Close()
 
-	src, err := imaging.Open(filePath, imaging.AutoOrientation(true))
+	data, err := json.MarshalIndent(struct {
+		Exif *ExifData `json:"exif"`
+		Tags []RawTag  `json:"tags"`
+	}{config.exifData, tags}, "", "  ")
 	if err != nil {
-		fmt.Println("Error Decode:", err)
-		os.Exit(1)
+		return err
 	}
 
-	// 画像のサイズを取得
-	srcBounds := src.Bounds()
-	srcWidth := srcBounds.Max.X
-	srcHeight := srcBounds.Max.Y
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
 
-	if config.noFrame {
-		framePixel = 0
-		noFramePixel = 180
+// タグのすべての値を文字列にする (文字列のタグは1つの値、有理数は "分子/分母")
+func formatValues(item *exif.IfdTagEntry) ([]string, error) {
+	value, err := item.Value()
+	if err != nil {
+		return nil, err
 	}
 
-	// 背景フレームの作成
-	dst := image.NewRGBA(image.Rect(0, 0, srcWidth+framePixel*2, srcHeight+framePixel*2+EXIF_LABEL_HEIGHT+noFramePixel))
+	var values []string
+	switch v := value.(type) {
+	case string:
+		values = []string{v}
+	case []byte:
+		for _, n := range v {
+			values = append(values, strconv.Itoa(int(n)))
+		}
+	case []uint16:
+		for _, n := range v {
+			values = append(values, strconv.Itoa(int(n)))
+		}
+	case []uint32:
+		for _, n := range v {
+			values = append(values, strconv.FormatUint(uint64(n), 10))
+		}
+	case []int32:
+		for _, n := range v {
+			values = append(values, strconv.Itoa(int(n)))
+		}
+	case []exifcommon.Rational:
+		for _, r := range v {
+			values = append(values, fmt.Sprintf("%d/%d", r.Numerator, r.Denominator))
+		}
+	case []exifcommon.SignedRational:
+		for _, r := range v {
+			values = append(values, fmt.Sprintf("%d/%d", r.Numerator, r.Denominator))
+		}
+	default:
+		// その他の型は FormatFirst と同じ表現にする
+		first, err := item.FormatFirst()
+		if err != nil {
+			return nil, err
+		}
+		values = []string{first}
+	}
 
-	if config.frameColorBlack {
-		config.frameColor = image.Black
-		config.textColor = image.White
-	} else {
-		config.frameColor = image.White
-		config.textColor = image.Black
+	if len(values) == 0 {
+		return nil, errors.New("no values")
 	}
+	return values, nil
+}
 
-	draw.Draw(dst, dst.Bounds(), config.frameColor, image.Point{}, draw.Src)
+// GPS時刻 (UTC) を "2006/01/02 15:04" の形式で返す
+// GPSDateStampが無い場合は時刻のみを返す
+func readGpsDateTime(gpsIfd *exif.Ifd, timeStamp *exif.IfdTagEntry) (string, error) {
+	value, err := timeStamp.Value()
+	if err != nil {
+		return "", fmt.Errorf("GPSTimeStamp: %w", err)
+	}
 
-	// 画像と背景フレームの描画
-	draw.Draw(dst, dst.Bounds(), src, image.Point{-framePixel, -framePixel}, draw.Src)
+	hms, ok := value.([]exifcommon.Rational)
+	if !ok || len(hms) != 3 {
+		return "", fmt.Errorf("GPSTimeStamp: unexpected value %v", value)
+	}
+
+	var parts [3]float64
+	for i, r := range hms {
+		if r.Denominator == 0 {
+			return "", fmt.Errorf("GPSTimeStamp: zero denominator")
+		}
+		parts[i] = float64(r.Numerator) / float64(r.Denominator)
+	}
 
-	// exiframe-*.jpg として保存
-	fDst, err := os.Create(FILE_NAME_PREFIX + config.fileName)
+	gpsTime := fmt.Sprintf("%02d:%02d", int(parts[0]), int(parts[1]))
+
+	results, err := gpsIfd.FindTagWithId(GPS_DATE_STAMP_TAG_ID)
+	if err != nil || len(results) == 0 {
+		return gpsTime, nil
+	}
+
+	date, err := results[0].FormatFirst()
 	if err != nil {
-		fmt.Println("Error creating file:", err)
-		os.Exit(1)
+		return gpsTime, nil
 	}
-	defer fDst.Close()
 
-	// Exif情報をJPEGに埋め込む
-	var camData, lensData string
-	if !config.noModelData {
-		camData = exifData.Make + " " + exifData.Model
-		lensData = exifData.LensMake + " " + exifData.LensModel
+	t, err := time.Parse("2006:01:02", strings.TrimRight(date, " \x00"))
+	if err != nil {
+		return "", fmt.Errorf("GPSDateStamp: %w", err)
 	}
 
-	expoData := exifData.FocalLengthIn35mmFilm + "mm  " + "f/" + exifData.FNumber + "  " + exifData.ExposureTime + "s  ISO" + exifData.PhotographicSensitivity
+	return t.Format("2006/01/02") + " " + gpsTime, nil
+}
 
-	boldfnt, err := truetype.Parse(gomonobold.TTF)
+// ShutterSpeedValue (APEX) を露出時間 t = 2^(-apex) に変換する
+// タグが無い場合は空文字列を返す
+func readShutterSpeedValue(rootIfd *exif.Ifd) (string, error) {
+	ifd, err := exif.FindIfdFromRootIfd(rootIfd, EXIF_IFD_PATH)
 	if err != nil {
-		fmt.Println("Error parsing font:", err)
-		os.Exit(1)
+		return "", nil
+	}
+
+	results, err := ifd.FindTagWithId(SHUTTER_SPEED_TAG_ID)
+	if err != nil || len(results) == 0 {
+		return "", nil
 	}
 
-	regularfnt, err := truetype.Parse(gomono.TTF)
+	value, err := results[0].Value()
 	if err != nil {
-		fmt.Println("Error parsing font:", err)
-		os.Exit(1)
+		return "", err
 	}
 
-	boldFace := truetype.NewFace(boldfnt, &truetype.Options{
-		Size: LARGE_FONT_SIZE,
-	})
+	rationals, ok := value.([]exifcommon.SignedRational)
+	if !ok || len(rationals) == 0 || rationals[0].Denominator == 0 {
+		return "", fmt.Errorf("unexpected value %v", value)
+	}
 
-	boldFace2 := truetype.NewFace(boldfnt, &truetype.Options{
-		Size: FONT_SIZE,
-	})
+	return apexToExposureTime(float64(rationals[0].Numerator) / float64(rationals[0].Denominator)), nil
+}
 
-	regularFace := truetype.NewFace(regularfnt, &truetype.Options{
-		Size: FONT_SIZE,
-	})
+// APEXの値を formatExposureTime の表記にする ("8" -> "1/256", "-1" -> "2")
+func apexToExposureTime(apex float64) string {
+	t := math.Pow(2, -apex)
+	if t < 1 {
+		return formatExposureTime("1/" + strconv.FormatFloat(math.Round(1/t), 'f', 0, 64))
+	}
 
-	boldMetrics, regularMetrics := boldFace.Metrics(), regularFace.Metrics()
-	boldHeight, _ := boldMetrics.Height.Ceil(), regularMetrics.Height.Ceil()
+	return formatExposureTime(strconv.FormatFloat(t, 'f', -1, 64))
+}
 
-	dBold := &font.Drawer{
-		Dst:  dst,
-		Src:  config.textColor,
-		Face: boldFace,
-		Dot:  fixed.Point26_6{},
+// UserCommentを文字コードに従って文字列に変換する
+// 未定義の文字コードや空のコメントは空文字列を返す
+func decodeUserComment(raw []byte, byteOrder binary.ByteOrder) (string, error) {
+	if len(raw) < 8 {
+		return "", nil
 	}
 
-	dBold2 := &font.Drawer{
-		Dst:  dst,
-		Src:  config.textColor,
-		Face: boldFace2,
-		Dot:  fixed.Point26_6{},
+	prefix, body := raw[:8], raw[8:]
+
+	var comment string
+	switch {
+	case bytes.EqualFold(prefix, USER_COMMENT_ASCII):
+		comment = strings.ToValidUTF8(string(body), "")
+	case bytes.EqualFold(prefix, USER_COMMENT_UNICODE):
+		// BOMがあればそれに従い、無ければExifのバイトオーダーで読む
+		if len(body) >= 2 {
+			switch {
+			case body[0] == 0xfe && body[1] == 0xff:
+				byteOrder, body = binary.BigEndian, body[2:]
+			case body[0] == 0xff && body[1] == 0xfe:
+				byteOrder, body = binary.LittleEndian, body[2:]
+			}
+		}
+
+		units := make([]uint16, len(body)/2)
+		for i := range units {
+			units[i] = byteOrder.Uint16(body[i*2:])
+		}
+		comment = string(utf16.Decode(units))
+	case bytes.EqualFold(prefix, USER_COMMENT_JIS):
+		return "", fmt.Errorf("JIS encoded comment is not supported")
+	default:
+		return "", nil
 	}
 
-	dRegular := &font.Drawer{
-		Dst:  dst,
-		Src:  config.textColor,
-		Face: regularFace,
-		Dot:  fixed.Point26_6{},
+	return strings.TrimRight(comment, " \x00"), nil
+}
+
+// "#rrggbb" (または "rrggbb") 形式の色を読み込む
+func parseHexColor(s string) (*image.Uniform, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 {
+		return nil, fmt.Errorf("invalid color: %q", s)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid color: %q", s)
+	}
+
+	return image.NewUniform(color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}), nil
+}
+
+// 画像が透過ピクセルを持つか判定する
+func hasAlpha(img image.Image) bool {
+	switch img := img.(type) {
+	case *image.YCbCr, *image.Gray, *image.Gray16, *image.CMYK:
+		return false
+	case interface{ Opaque() bool }:
+		return !img.Opaque()
+	default:
+		return true
 	}
+}
 
-	// カメラデータ
-	dBold.Dot.X = fixed.I(framePixel + noFramePixel)
-	dBold.Dot.Y = fixed.I(srcHeight + framePixel*2 + boldHeight + noFramePixel)
-	dBold.DrawString(camData)
+// 画像の左下の画素を枠の色とし、文字色は枠の明るさに応じて黒か白にする
+func borderColors(img image.Image) (frameColor, textColor *image.Uniform) {
+	b := img.Bounds()
+	c := color.NRGBAModel.Convert(img.At(b.Min.X, b.Max.Y-1)).(color.NRGBA)
+	c.A = 0xff
 
-	// レンズデータ
-	dRegular.Dot.X = fixed.I(framePixel + noFramePixel)
-	dRegular.Dot.Y = fixed.I(srcHeight + framePixel*2 + boldHeight*2 + noFramePixel)
-	dRegular.DrawString(lensData)
+	// ITU-R BT.601 の輝度
+	y := 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+	if y < 128 {
+		return image.NewUniform(c), image.White
+	}
 
-	// 撮影データ
-	expoDataWidth := dBold2.MeasureString(expoData).Ceil()
-	dBold2.Dot.X = fixed.I(srcWidth + framePixel - expoDataWidth - noFramePixel)
-	dBold2.Dot.Y = fixed.I(srcHeight + framePixel*2 + boldHeight + noFramePixel)
-	dBold2.DrawString(expoData)
+	return image.NewUniform(c), image.Black
+}
 
-	// 撮影日時
-	timeDataWidth := dRegular.MeasureString(exifData.DateTimeOriginal).Ceil()
-	dRegular.Dot.X = fixed.I(srcWidth + framePixel - timeDataWidth - noFramePixel)
-	dRegular.Dot.Y = fixed.I(srcHeight + framePixel*2 + boldHeight*2 + noFramePixel)
-	dRegular.DrawString(exifData.DateTimeOriginal)
+// SubjectDistance (メートルの有理数) を "2.3m" の形式で表す
+// 分子が 0xFFFFFFFF の場合は無限遠 "∞"、0 の場合は不明として空文字列を返す
+func formatFocusDistance(value string) string {
+	numerator, denominator, ok := strings.Cut(value, "/")
+	if !ok {
+		return ""
+	}
 
-	// JPEGエンコード
-	err = jpeg.Encode(fDst, dst, &jpeg.Options{Quality: 100})
+	n, err := strconv.ParseUint(numerator, 10, 32)
+	if err != nil || n == 0 {
+		return ""
+	}
+	if n == 0xffffffff {
+		return "∞"
+	}
+
+	d, err := strconv.ParseUint(denominator, 10, 32)
+	if err != nil || d == 0 {
+		return ""
+	}
+
+	// 10m 以上は整数、それ未満は小数点以下1桁
+	meters := float64(n) / float64(d)
+	if meters >= 10 {
+		return strconv.FormatFloat(math.Round(meters), 'f', 0, 64) + "m"
+	}
+	return strconv.FormatFloat(meters, 'f', 1, 64) + "m"
+}
+
+// 有理数 ("28/10"), 小数 ("2.8"), 整数 ("4") のいずれかの値を数値にする
+// 文字列として記録された値の前後の空白やNULは無視する
+func parseExifNumber(value string) (float64, bool) {
+	value = strings.Trim(value, " \x00")
+	numerator, denominator, ok := strings.Cut(value, "/")
+	if !ok {
+		f, err := strconv.ParseFloat(value, 64)
+		return f, err == nil
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(numerator), 64)
 	if err != nil {
-		fmt.Println("Error encoding JPEG:", err)
-		os.Exit(1)
+		return 0, false
+	}
+	d, err := strconv.ParseFloat(strings.TrimSpace(denominator), 64)
+	if err != nil || d == 0 {
+		return 0, false
 	}
+	return n / d, true
 }
 
-func main() {
-	flag.StringVar(&filePath, "f", "", "Path to the image file (required)")
-	frameColorBlack := flag.Bool("black", false, "Use black color frame (default white)")
-	noFrame := flag.Bool("no-frame", false, "Do not draw frame (default draw frame)")
-	noModelData := flag.Bool("no-model", false, "Do not draw model data (default draw model data)")
-	flag.Parse()
+// FNumberを小数点以下1桁に丸めて表す (整数の場合は小数点を付けない: "4", "2.8")
+// "28/10" (有理数), "2.8" (小数), "4" (整数) のいずれも同じ形式にする
+func formatFNumber(value string) string {
+	f, ok := parseExifNumber(value)
+	if !ok {
+		return value
+	}
 
-	if filePath == "" {
-		fmt.Println("Please provide a file path using -f flag")
-		os.Exit(1)
+	return strconv.FormatFloat(math.Round(f*10)/10, 'f', -1, 64)
+}
+
+// ExposureTimeをシャッタースピードの表記にする
+// 1秒未満で分子が1に約分できる場合は分数 ("10/2500" -> "1/250")、
+// それ以外は小数 ("3/10" -> "0.3", "13/10" -> "1.3", "5/1" -> "5")
+// 小数や整数で記録された値も同じ形式にする ("0.004" -> "1/250", "4" -> "4")
+func formatExposureTime(value string) string {
+	trimmed := strings.Trim(value, " \x00")
+	if numerator, denominator, ok := strings.Cut(trimmed, "/"); ok {
+		n, err1 := strconv.ParseUint(strings.TrimSpace(numerator), 10, 64)
+		d, err2 := strconv.ParseUint(strings.TrimSpace(denominator), 10, 64)
+		if err1 == nil && err2 == nil && d != 0 {
+			if g := gcd(n, d); g > 1 {
+				n /= g
+				d /= g
+			}
+			if n == 1 && d > 1 {
+				return "1/" + strconv.FormatUint(d, 10)
+			}
+			return strconv.FormatFloat(math.Round(float64(n)/float64(d)*100)/100, 'f', -1, 64)
+		}
 	}
 
-	fileName := filepath.Base(filePath)
+	f, ok := parseExifNumber(trimmed)
+	if !ok || f < 0 {
+		return value
+	}
 
-	config := &Config{
-		filePath:        filePath,
-		frameColorBlack: *frameColorBlack,
-		noFrame:         *noFrame,
-		noModelData:     *noModelData,
+	// 1/N 秒を小数にした値 ("0.004", "0.0333") は分数に戻す
+	if f > 0 && f < 1 {
+		if d := math.Round(1 / f); d > 1 && math.Abs(1/f-d) <= d*EXPOSURE_FRACTION_TOLERANCE {
+			return "1/" + strconv.FormatFloat(d, 'f', 0, 64)
+		}
+	}
+
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+// 最大公約数
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// DateTimeOriginalを解析して表示用の文字列を返す
+// 末尾の空白やNULを取り除き、秒や時刻が欠けた値も受け付ける
+// CIPAの規格では不明な部分をコロンを残して空白にするため、末尾の空白の部分は区切りのコロンごと取り除く
+// ("2024:05:01 12:34:  " -> "2024:05:01 12:34", "2024:05:01   :  :  " -> "2024:05:01")
+func parseDateTimeOriginal(value string) (output string, layout string, err error) {
+	trimmed := strings.TrimRight(value, " :\x00")
+
+	// 日時不明 (コロン以外が空白、または全て空白)
+	if trimmed == "" {
+		return "", "", fmt.Errorf("unknown date and time: %q", value)
+	}
+
+	for _, l := range DATE_TIME_LAYOUTS {
+		t, err := time.Parse(l.layout, trimmed)
+		if err != nil {
+			continue
+		}
+
+		return t.Format(l.output), l.layout, nil
+	}
+
+	return "", "", fmt.Errorf("unsupported date and time format: %q", value)
+}
+
+// 出力用のExifデータを作成
+func buildExif(config *Config) ([]byte, error) {
+	im, err := exifcommon.NewIfdMappingWithStandard()
+	if err != nil {
+		return nil, err
+	}
+
+	ti := exif.NewTagIndex()
+
+	_, index, err := exif.Collect(im, ti, config.rawExif)
+	if err != nil {
+		return nil, err
+	}
+
+	rootIb := exif.NewIfdBuilderFromExistingChain(index.RootIfd)
+
+	// 画像は回転済みのため正位置に戻す
+	if _, err := rootIb.FindTag(ORIENTATION_TAG_ID); err == nil {
+		err = rootIb.SetStandard(ORIENTATION_TAG_ID, []uint16{1})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// GPS IFDへのポインタを削除
+	if config.stripGps {
+		_, err = rootIb.DeleteAll(exifcommon.IfdGpsInfoStandardIfdIdentity.TagId())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return exif.NewIfdByteEncoder().EncodeToExif(rootIb)
+}
+
+// SOIの直後にExifのAPP1セグメントを挿入して書き込む
+func writeJpegWithExif(w io.Writer, jpegData []byte, exifData []byte) error {
+	payload := append([]byte(EXIF_HEADER), exifData...)
+	length := len(payload) + 2
+	if length > 0xffff {
+		return fmt.Errorf("exif data too large: %d bytes", len(payload))
+	}
+
+	segment := []byte{0xff, APP1_MARKER, byte(length >> 8), byte(length)}
+
+	for _, b := range [][]byte{jpegData[:2], segment, payload, jpegData[2:]} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-		fileName: fileName,
+// 画像からExif情報を読み込む
+// 読み込めないタグが1つでもあればエラーを返す
+func ReadExif(r io.Reader) (*ExifData, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading image: %w", err)
 	}
 
-	exifData := getExif(config)
+	return getExif(data, &Config{})
+}
+
+// 画像から読み込めた分だけのExif情報を返す
+// ReadExifと異なりエラーを返さず、読み込めないタグは無視し、Exifが無い場合は空のまま返す
+func ReadExifBestEffort(r io.Reader) (exifData ExifData) {
+	config := &Config{bestEffort: true}
 
-	drawFrame(config, exifData)
+	// 壊れたExifの解析中にpanicした場合も、それまでに読み込めた分を返す
+	defer func() {
+		if recover() != nil && config.exifData != nil {
+			exifData = *config.exifData
+		}
+	}()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return ExifData{}
+	}
+
+	result, err := getExif(data, config)
+	if err != nil {
+		return ExifData{}
+	}
+
+	return *result
+}
+
+// 画像データを読み込み、フレームを付けたJPEGをwに書き込む
+// 同じ設定で繰り返し描画する場合は NewRenderer を使う
+func FrameToWriter(w io.Writer, r io.Reader, config *Config) error {
+	renderer, err := NewRenderer(config)
+	if err != nil {
+		return err
+	}
+
+	return renderer.Render(w, r)
+}
+
+// 描画は行わず、計算したレイアウトをJSONでwに書き込む
+func LayoutToWriter(w io.Writer, r io.Reader, config *Config) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("reading image: %w", err)
+	}
+
+	exifData, err := getExif(data, config)
+	if err != nil {
+		return err
+	}
+
+	// -json でファイルを指定した場合は、そのファイルの .exiframe.json による上書きも反映する
+	if config.filePath != "" {
+		err = applyOverrideFile(exifData, config.filePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	src, err := loadImage(data, config)
+	if err != nil {
+		return err
+	}
+
+	faces, err := configFaces(config)
+	if err != nil {
+		return err
+	}
+
+	srcBounds := src.Bounds()
+	layout := computeLayout(srcBounds.Max.X, srcBounds.Max.Y, config, exifData, faces)
+	addFooter(layout, config, faces)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(layout)
+}
+
+// フレームのレイアウト (座標はキャンバス左上を原点とするピクセル単位)
+type Layout struct {
+	Canvas LayoutRect   `json:"canvas"`
+	Image  LayoutRect   `json:"image"`
+	Band   LayoutRect   `json:"band"`
+	Inset  *LayoutRect  `json:"inset,omitempty"`
+	Lines  []LayoutLine `json:"lines"`
+
+	ApertureIcon *LayoutRect `json:"aperture_icon,omitempty"`
+	FocalBar     *LayoutRect `json:"focal_bar,omitempty"`
+	Footer       *LayoutRect `json:"footer,omitempty"`
+	FooterLogo   *LayoutRect `json:"footer_logo,omitempty"`
+	DroppedLines []string    `json:"dropped_lines,omitempty"`
+
+	fNumber     float64
+	focalLength float64
+}
+
+type LayoutRect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// ラベルの1行分 (X, Yはベースラインの始点)
+type LayoutLine struct {
+	Name  string `json:"name"`
+	Text  string `json:"text"`
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+	Width int    `json:"width"`
+
+	Rotate string `json:"rotate,omitempty"` // 回転して描画する向き (cw, ccw)
+
+	face  font.Face
+	color *image.Uniform
+}
+
+// 画像をデコードして描画前の調整を行う
+func loadImage(data []byte, config *Config) (image.Image, error) {
+	// AVIFはデコーダーが無いため、"unknown format" ではなく対応していないことを示す
+	if imageContainer(data) == "avif" {
+		return nil, decodeError(ErrAvifNotSupported)
+	}
+
+	// デコードする前にヘッダーだけを読んで画素数を確認する
+	if config.maxMegapixels > 0 {
+		if c, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+			megapixels := float64(c.Width) * float64(c.Height) / 1e6
+			if megapixels > config.maxMegapixels {
+				return nil, fmt.Errorf("%w: %.1f megapixels (limit %g)", ErrImageTooLarge, megapixels, config.maxMegapixels)
+			}
+		}
+	}
+
+	src, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, decodeError(fmt.Errorf("Decode: %w", err))
+	}
+	src = orientImage(src, data)
+
+	// -tonemap の場合はHDRの画像をSDRにする
+	if config.tonemap {
+		src = tonemapImage(src, data)
+	}
+
+	// 周囲の透明な余白や一様な色の余白を切り取る
+	if config.trim {
+		src = trimImage(src, config.trimTolerance)
+	}
+
+	// -aspect の場合は中央を指定した縦横比で切り抜く
+	if config.aspect > 0 {
+		src, err = cropToAspect(src, config.aspect)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 明るさとコントラストの調整
+	if config.brightness != 0 {
+		src = imaging.AdjustBrightness(src, config.brightness)
+	}
+	if config.contrast != 0 {
+		src = imaging.AdjustContrast(src, config.contrast)
+	}
+
+	return src, nil
+}
+
+// メーカー名とモデル名を結合する
+// dedupの場合、モデル名がメーカー名 (またはその最初の単語) で始まるときはメーカー名を省く
+// 例: "Canon" + "Canon EOS R5" -> "Canon EOS R5", "NIKON CORPORATION" + "NIKON Z 6" -> "NIKON Z 6"
+// 前後の空白は除き、連続する空白は1つにまとめる (どちらかが空の場合は区切りの空白を付けない)
+func joinMakeModel(maker, model string, dedup bool) string {
+	maker = strings.Join(strings.Fields(strings.TrimRight(maker, "\x00")), " ")
+	model = strings.Join(strings.Fields(strings.TrimRight(model, "\x00")), " ")
+	if maker == "" || model == "" {
+		return maker + model
+	}
+
+	if dedup {
+		lowerModel := strings.ToLower(model)
+		firstWord, _, _ := strings.Cut(maker, " ")
+		for _, prefix := range []string{maker, firstWord} {
+			if prefix != "" && strings.HasPrefix(lowerModel, strings.ToLower(prefix)) {
+				return model
+			}
+		}
+	}
+
+	return maker + " " + model
+}
+
+// キャンバス、画像、ラベルの配置を計算する
+func computeLayout(srcWidth, srcHeight int, config *Config, exifData *ExifData, faces *Faces) *Layout {
+	// -portrait-text horizontal の場合、縦長の画像はラベルを右側に横書きで置く
+	if config.portraitText == PORTRAIT_TEXT_HORIZONTAL && srcHeight > srcWidth {
+		return computePortraitLayout(srcWidth, srcHeight, config, exifData, faces)
+	}
+
+	// -vertical-text (-portrait-text vertical) の場合、縦長の画像はラベルを右側に置く
+	if config.verticalText != "" && srcHeight > srcWidth {
+		return computeSideLayout(srcWidth, srcHeight, config, exifData, faces)
+	}
+
+	// -table の場合はExif情報を2列の表として並べる
+	if config.table {
+		return computeTableLayout(srcWidth, srcHeight, config, exifData, faces)
+	}
+
+	// -compact の場合はExif情報を1行にまとめる
+	if config.compact {
+		return computeCompactLayout(srcWidth, srcHeight, config, exifData, faces)
+	}
+
+	// -columns の場合はExif情報を複数列に並べる
+	if config.columns > 0 {
+		return computeColumnsLayout(srcWidth, srcHeight, config, exifData, faces)
+	}
+
+	// -band-only は枠付きの画像にラベル領域だけを追加するため、枠と内側の余白を付けない
+	framePixel, noFramePixel := FRAME_PIXEL, 0
+	if config.noFrame || config.bandOnly {
+		framePixel = 0
+		noFramePixel = FRAME_PIXEL
+	}
+
+	// -frame-inset の場合は画像の周囲に内側の余白を付け、余白を含めて画像として扱う
+	inset := config.frameInset
+	if config.bandOnly {
+		inset = 0
+	}
+	imageWidth := srcWidth + inset*2
+	imageHeight := srcHeight + inset*2
+
+	spec := config.layoutSpec
+	if spec == nil {
+		spec = DEFAULT_LAYOUT_SPEC
+	}
+
+	// -font-size-pt の場合は文字の大きさに合わせて行の位置とラベル領域の高さを変える
+	if k := textScale(config); k != 1 {
+		spec = scaleLayoutSpec(spec, k)
+	}
+
+	labelHeight := EXIF_LABEL_HEIGHT
+	if spec.BandHeight > 0 {
+		labelHeight = spec.BandHeight
+	}
+
+	canvasWidth := imageWidth + framePixel*2
+	// -label-gap は画像の下端からラベル領域の文字の基準位置までの距離
+	canvasHeight := framePixel + imageHeight + config.labelGap + labelHeight
+
+	layout := &Layout{
+		Canvas: LayoutRect{0, 0, canvasWidth, canvasHeight},
+		Image:  LayoutRect{framePixel + inset, framePixel + inset, srcWidth, srcHeight},
+		Band:   LayoutRect{0, framePixel + imageHeight, canvasWidth, canvasHeight - framePixel - imageHeight},
+	}
+	if inset > 0 {
+		layout.Inset = &LayoutRect{framePixel, framePixel, imageWidth, imageHeight}
+	}
+
+	// ラベルに描画するExif情報
+	fields := layoutFields(exifData, config)
+
+	leftX := framePixel + noFramePixel
+	rightX := imageWidth + framePixel - noFramePixel
+	labelY := framePixel + imageHeight + config.labelGap
+
+	lineText := func(lineSpec LineSpec) string {
+		text := os.Expand(lineSpec.Text, func(name string) string {
+			return fields[name]
+		})
+		return applyRenames(text, config.renames, config.renameIgnoreCase)
+	}
+
+	// -max-label-lines を超える行は省く
+	lines, dropped := limitLines(selectLines(spec, config.fields), config.maxLabelLines, func(lineSpec LineSpec) bool {
+		return !lineSpec.Optional || lineText(lineSpec) != ""
+	})
+	layout.DroppedLines = dropped
+
+	// -fit-labels の場合は行がラベル領域に収まるよう文字の大きさと位置を縮める
+	if config.fitLabels {
+		lines = fitLines(lines, labelHeight, faces, lineText)
+	}
+
+	// layout.Lines と同じ順のレイアウト定義 (-font-baselines で使う)
+	var drawnSpecs []LineSpec
+	for _, lineSpec := range lines {
+		text := lineText(lineSpec)
+		if lineSpec.Optional && text == "" {
+			continue
+		}
+
+		face := faces.face(lineSpec.Font, lineSpec.Size)
+		width := font.MeasureString(face, text).Ceil()
+
+		x := leftX
+		switch lineSpec.Align {
+		case "center":
+			x = (leftX+rightX)/2 - width/2
+		case "right":
+			x = rightX - width
+		}
+
+		layout.Lines = append(layout.Lines, LayoutLine{
+			Name:  lineSpec.Name,
+			Text:  text,
+			X:     x,
+			Y:     labelY + lineSpec.Y,
+			Width: width,
+			face:  face,
+			color: lineSpec.color,
+		})
+		drawnSpecs = append(drawnSpecs, lineSpec)
+
+		if lineSpec.Extend {
+			height := face.Metrics().Height.Ceil()
+			layout.Canvas.Height += height
+			layout.Band.Height += height
+		}
+	}
+
+	// -font-baselines の場合は段ごとのベースラインを書体に合わせる (組み込みのフォントでは変わらない)
+	if config.fontBaselines && config.fonts != nil {
+		reference, err := loadFaces(&Config{})
+		if err == nil {
+			grow := alignBaselines(layout, drawnSpecs, reference)
+			layout.Canvas.Height += grow
+			layout.Band.Height += grow
+		}
+	}
+
+	// -keywords の場合はキーワードの行を最後に追加する
+	if config.keywords {
+		addKeywordsLine(layout, exifData.Keywords, leftX, rightX, faces)
+	}
+
+	// -exif-field で指定したタグを1行ずつ追加する
+	for _, line := range config.exifLines {
+		appendSmallLine(layout, "exif_field", line, leftX, rightX, faces)
+	}
+
+	// 絞りのアイコン (撮影データの左にベースラインを揃えて配置)
+	if f, err := strconv.ParseFloat(exifData.FNumber, 64); config.apertureIcon && err == nil && f > 0 {
+		for _, line := range layout.Lines {
+			if line.Name != "exposure" {
+				continue
+			}
+
+			size := line.face.Metrics().Ascent.Ceil()
+			layout.ApertureIcon = &LayoutRect{line.X - size*3/2, line.Y - size, size, size}
+			layout.fNumber = f
+			break
+		}
+	}
+
+	// 焦点距離の目盛り (左右の行の間に1行目のベースラインを揃えて配置し、下に焦点距離を添える)
+	// 空いている幅が目盛りの高さの4倍に満たない場合は描画しない
+	if f := focalLength35mm(exifData); config.focalBar && f > 0 {
+		baseline := labelY + LARGE_FONT_SIZE
+		if len(layout.Lines) > 0 {
+			baseline = layout.Lines[0].Y
+		}
+
+		// 左右の行の間の空いている幅に収める
+		gapLeft, gapRight := leftX, rightX
+		for _, line := range layout.Lines {
+			if line.X+line.Width/2 < layout.Canvas.Width/2 {
+				gapLeft = max(gapLeft, line.X+line.Width)
+			} else {
+				gapRight = min(gapRight, line.X)
+			}
+		}
+
+		width := min(layout.Canvas.Width/FOCAL_BAR_WIDTH, gapRight-gapLeft-2*FONT_SIZE)
+		height := faces.face("regular", FONT_SIZE).Metrics().Ascent.Ceil()
+		if width >= height*4 {
+			layout.FocalBar = &LayoutRect{(gapLeft + gapRight - width) / 2, baseline - height, width, height}
+			layout.focalLength = f
+
+			face := faces.face("regular", FOCAL_BAR_FONT_SIZE)
+			text := strconv.FormatFloat(f, 'f', -1, 64) + "mm"
+			textWidth := font.MeasureString(face, text).Ceil()
+			x := layout.FocalBar.X + int(focalBarPosition(f)*float64(width)) - textWidth/2
+			layout.Lines = append(layout.Lines, LayoutLine{
+				Name:  "focal_bar",
+				Text:  text,
+				X:     min(max(x, layout.FocalBar.X), layout.FocalBar.X+width-textWidth),
+				Y:     baseline + height/2 + face.Metrics().Ascent.Ceil(),
+				Width: textWidth,
+				face:  face,
+			})
+		}
+	}
+
+	return layout
+}
+
+// レイアウトに従ってフレーム、画像、ラベルを描画する
+func renderFrame(src image.Image, layout *Layout, config *Config) *image.RGBA {
+	// 背景フレームの作成
+	dst := image.NewRGBA(image.Rect(0, 0, layout.Canvas.Width, layout.Canvas.Height))
+
+	if config.frameColorBlack {
+		config.frameColor = image.Black
+		config.textColor = image.White
+	} else {
+		config.frameColor = image.White
+		config.textColor = image.Black
+	}
+
+	// -theme の色
+	if config.themeFrameColor != nil {
+		config.frameColor = config.themeFrameColor
+	}
+	if config.themeTextColor != nil {
+		config.textColor = config.themeTextColor
+	}
+
+	// -frame-color palette の場合は写真の代表的な色をフレームの色にし、文字色はその明るさに合わせる
+	if config.frameColorMode == FRAME_COLOR_PALETTE {
+		config.frameColor = image.NewUniform(paletteColor(src, config.seed))
+		config.textColor = contrastTextColor(config.frameColor, image.Rect(0, 0, 1, 1))
+	}
+
+	// -band-only の場合はラベル領域を画像の既存の枠の色 (左下の画素) に合わせる
+	if config.bandOnly {
+		config.frameColor, config.textColor = borderColors(src)
+	}
+
+	draw.Draw(dst, dst.Bounds(), config.frameColor, image.Point{}, draw.Src)
+
+	// -background の場合はフレームの色の代わりに画像を敷き、文字色はラベル領域の明るさに合わせる
+	// -theme で文字色が決まっている場合はそれを使う
+	if config.background != nil && !config.bandOnly {
+		drawBackground(dst, config.background, config.backgroundMode)
+		if config.themeTextColor == nil {
+			band := image.Rect(layout.Band.X, layout.Band.Y, layout.Band.X+layout.Band.Width, layout.Band.Y+layout.Band.Height)
+			config.textColor = contrastTextColor(dst, band)
+		}
+	}
+
+	// -border-image の場合は9つに分けた画像をフレームの色の代わりに敷き、文字色はラベル領域の明るさに合わせる
+	// -theme で文字色が決まっている場合はそれを使う
+	if config.border != nil && !config.bandOnly {
+		drawBorderImage(dst, config.border, config.borderSlice, config.borderRepeat)
+		if config.themeTextColor == nil {
+			band := image.Rect(layout.Band.X, layout.Band.Y, layout.Band.X+layout.Band.Width, layout.Band.Y+layout.Band.Height)
+			config.textColor = contrastTextColor(dst, band)
+		}
+	}
+
+	// -band-blur の場合はラベル領域に写真の下端をぼかして敷き、文字は白にする
+	// -theme で文字色が決まっている場合はそれを使う
+	if config.bandBlur && !config.bandOnly {
+		// -round-frame の場合はラベル領域の四隅も丸める
+		var bandCorners *corners
+		if config.roundFrame {
+			band := image.Rect(layout.Band.X, layout.Band.Y, layout.Band.X+layout.Band.Width, layout.Band.Y+layout.Band.Height)
+			bandCorners = saveCorners(dst, band, config.frameRadius)
+		}
+		drawBandBlur(dst, src, layout.Band)
+		bandCorners.restore(dst)
+		if config.themeTextColor == nil {
+			config.textColor = image.White
+		}
+	}
+
+	// 内側の余白
+	if layout.Inset != nil {
+		insetColor := config.insetColor
+		if insetColor == nil {
+			insetColor, _ = parseHexColor(INSET_COLOR)
+		}
+		r := image.Rect(layout.Inset.X, layout.Inset.Y, layout.Inset.X+layout.Inset.Width, layout.Inset.Y+layout.Inset.Height)
+		draw.Draw(dst, r, insetColor, image.Point{}, draw.Src)
+	}
+
+	// 画像と背景フレームの描画
+	// 透過のある画像はフレームの色と合成する
+	// -border-only の場合は画像を描画せず、画像の部分を透明にする
+	if config.borderOnly {
+		r := image.Rect(layout.Image.X, layout.Image.Y, layout.Image.X+layout.Image.Width, layout.Image.Y+layout.Image.Height)
+		draw.Draw(dst, r, image.Transparent, image.Point{}, draw.Src)
+	} else {
+		op := draw.Src
+		if hasAlpha(src) {
+			op = draw.Over
+		}
+
+		// -frame-radius の場合は写真の四隅を丸める
+		imageRect := image.Rect(layout.Image.X, layout.Image.Y, layout.Image.X+layout.Image.Width, layout.Image.Y+layout.Image.Height)
+		imageCorners := saveCorners(dst, imageRect, config.frameRadius)
+		draw.Draw(dst, dst.Bounds(), src, image.Point{-layout.Image.X, -layout.Image.Y}, op)
+
+		// -inner-shadow の場合はフレームが写真に落とす影を描画する
+		if config.innerShadow {
+			drawInnerShadow(dst, layout.Image, config.shadowWidth, config.shadowOpacity)
+		}
+		imageCorners.restore(dst)
+	}
+
+	// 文字の下の色が一様でない行は先に下地を塗る (-text-background)
+	for _, line := range layout.Lines {
+		drawTextBackground(dst, line, config)
+	}
+	for _, line := range layout.Lines {
+		drawLineSafely(dst, line, config)
+	}
+
+	if layout.ApertureIcon != nil {
+		drawApertureIcon(dst, *layout.ApertureIcon, layout.fNumber, config)
+	}
+
+	if layout.FocalBar != nil {
+		drawFocalBar(dst, *layout.FocalBar, layout.focalLength, config)
+	}
+
+	if layout.FooterLogo != nil {
+		drawFooterLogo(dst, *layout.FooterLogo, config.footerLogo)
+	}
+
+	// -round-frame の場合はキャンバスの四隅を写真の角と同心になるよう丸める
+	if config.roundFrame && config.frameRadius > 0 {
+		clearCorners(dst, dst.Bounds(), config.frameRadius+layout.Image.X)
+	}
+
+	return dst
+}
+
+// ラベルの1行を描画する
+// -text-outline の場合はフレームの色で周囲にずらして描画し、輪郭を付ける
+func drawLine(dst draw.Image, line LayoutLine, config *Config) {
+	if line.Rotate != "" {
+		drawRotatedLine(dst, line, config)
+		return
+	}
+
+	if config.textOutline {
+		w := config.textOutlineWidth
+		for dy := -w; dy <= w; dy++ {
+			for dx := -w; dx <= w; dx++ {
+				if (dx == 0 && dy == 0) || dx*dx+dy*dy > w*w+1 {
+					continue
+				}
+
+				d := &font.Drawer{
+					Dst:  dst,
+					Src:  config.frameColor,
+					Face: line.face,
+					Dot:  fixed.P(line.X+dx, line.Y+dy),
+				}
+				d.DrawString(line.Text)
+			}
+		}
+	}
+
+	textColor := config.textColor
+	if line.color != nil {
+		textColor = line.color
+	}
+
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  textColor,
+		Face: line.face,
+		Dot:  fixed.P(line.X, line.Y),
+	}
+	d.DrawString(line.Text)
+}
+
+// 1行の描画中にpanicした場合はその行だけを描画せず、残りの行の描画を続ける
+// 壊れた文字列や書体による想定外のpanicへの備えで、通常の処理の流れとしては使わない
+func drawLineSafely(dst draw.Image, line LayoutLine, config *Config) {
+	defer func() {
+		if r := recover(); r != nil {
+			logError(fmt.Sprintf("Error drawing line %s: %v", line.Name, r))
+		}
+	}()
+
+	drawLine(dst, line, config)
+}
+
+func drawFrame(w io.Writer, data []byte, config *Config, exifData *ExifData) error {
+	dst, _, err := composeFrame(data, config, exifData)
+	if err != nil {
+		return err
+	}
+
+	return encodeFrame(w, dst, config)
+}
+
+// フレームを付けた画像を作成する
+func composeFrame(data []byte, config *Config, exifData *ExifData) (*image.RGBA, *Layout, error) {
+	start := time.Now()
+	src, err := loadImage(data, config)
+	config.timing.record(STAGE_DECODE, start)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start = time.Now()
+	defer config.timing.record(STAGE_COMPOSE, start)
+
+	faces, err := configFaces(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	srcBounds := src.Bounds()
+	layout := computeLayout(srcBounds.Max.X, srcBounds.Max.Y, config, exifData, faces)
+	addFooter(layout, config, faces)
+	config.layout = layout
+	if len(layout.DroppedLines) > 0 {
+		logInfo(fmt.Sprintf("Dropped label lines over -max-label-lines: %s", strings.Join(layout.DroppedLines, ", ")))
+	}
+
+	dst := renderFrame(src, layout, config)
+	if config.PostProcess == nil {
+		return dst, layout, nil
+	}
+
+	processed, err := config.PostProcess(dst)
+	if err != nil {
+		return nil, nil, fmt.Errorf("PostProcess: %w", err)
+	}
+
+	// 加工後の画像がRGBAでなければ変換する
+	rgba, ok := processed.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(processed.Bounds())
+		draw.Draw(rgba, rgba.Bounds(), processed, processed.Bounds().Min, draw.Src)
+	}
+
+	return rgba, layout, nil
+}
+
+// JPEGにエンコードしてwに書き込む (-keep-exif の場合はExifを埋め込む)
+func encodeFrame(w io.Writer, dst image.Image, config *Config) error {
+	// -faststart の場合はExifやICCプロファイルを埋め込んだ後でセグメントを並べ替える
+	if config.faststart {
+		c := *config
+		c.faststart = false
+
+		var buf bytes.Buffer
+		err := encodeFrame(&buf, dst, &c)
+		if err != nil {
+			return err
+		}
+
+		jpegData, err := faststartJpeg(buf.Bytes())
+		if err != nil {
+			return fmt.Errorf("reordering JPEG segments: %w", err)
+		}
+
+		_, err = w.Write(jpegData)
+		if err != nil {
+			return fmt.Errorf("writing image: %w", err)
+		}
+		return nil
+	}
+
+	// JPEGエンコード
+	// -progressive の場合は独自のエンコーダーでプログレッシブJPEGにする
+	var buf bytes.Buffer
+	var err error
+	if config.progressive {
+		err = encodeProgressiveJpeg(&buf, dst, 100)
+	} else {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 100})
+	}
+	if err != nil {
+		return fmt.Errorf("encoding JPEG: %w", err)
+	}
+
+	// -mark の場合はgo-exiframeで作成したことを示す印を入れる
+	if config.mark {
+		jpegData, err := insertJpegMarker(buf.Bytes(), config)
+		if err != nil {
+			return fmt.Errorf("inserting marker: %w", err)
+		}
+		buf = *bytes.NewBuffer(jpegData)
+	}
+
+	// -icc-embed のICCプロファイル (Exifはこの前に挿入される)
+	if config.iccProfile != nil {
+		jpegData, err := insertJpegIcc(buf.Bytes(), config.iccProfile)
+		if err != nil {
+			return fmt.Errorf("embedding ICC profile: %w", err)
+		}
+		buf = *bytes.NewBuffer(jpegData)
+	}
+
+	// Exifの無い画像 (PNG や BMP など) は -keep-exif でも埋め込むExifが無い
+	if !config.keepExif || len(config.rawExif) == 0 {
+		_, err = buf.WriteTo(w)
+		if err != nil {
+			return fmt.Errorf("writing image: %w", err)
+		}
+		return nil
+	}
+
+	exifBytes, err := buildExif(config)
+	if err != nil {
+		return fmt.Errorf("building Exif: %w", err)
+	}
+
+	err = writeJpegWithExif(w, buf.Bytes(), exifBytes)
+	if err != nil {
+		return fmt.Errorf("writing image: %w", err)
+	}
+
+	return nil
+}
+
+// 入力の指定が -json, -validate, -report と組み合わせられるかを確認する
+// source はファイル以外の入力のフラグ名 (from-clipboard, zip, test-pattern、ファイルの場合は空文字列)
+// -json は1つのファイルのレイアウトを、-validate はファイルのExifを対象にするため、ファイル以外の入力は受け付けない
+// -report は読み込んだExifを表示するため、Exifを読まない -test-pattern とは組み合わせられない
+func checkInputFlags(paths []string, source string, validate bool, config *Config) error {
+	if config.layoutJson {
+		if source != "" {
+			return fmt.Errorf("Please do not provide -%s with -json", source)
+		}
+		if len(paths) != 1 {
+			return errors.New("Please provide a single file with -json")
+		}
+	}
+	if validate && source != "" {
+		return fmt.Errorf("Please do not provide -%s with -validate", source)
+	}
+	if config.report && source == "test-pattern" {
+		return errors.New("Please do not provide -test-pattern with -report")
+	}
+	return nil
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		serve(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "exif" {
+		exifCommand(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		checkCommand(os.Args[2:])
+		return
+	}
+
+	filePath := flag.String("f", "", "Path to the image file (or pass image files as arguments)")
+	frameColorBlack := flag.Bool("black", false, "Use black color frame (default white)")
+	noFrame := flag.Bool("no-frame", false, "Do not draw frame (default draw frame)")
+	noModelData := flag.Bool("no-model", false, "Do not draw model data (default draw model data)")
+	keepExif := flag.Bool("keep-exif", false, "Keep Exif data in output (default strip Exif data)")
+	stripGps := flag.Bool("strip-gps", false, "Remove GPS data from kept Exif data (use with -keep-exif)")
+	brightness := flag.Float64("brightness", 0, "Adjust brightness of the image in percent (-100 to 100)")
+	contrast := flag.Float64("contrast", 0, "Adjust contrast of the image in percent (-100 to 100)")
+	verbose := flag.Bool("verbose", false, "Print verbose messages (such as output file names)")
+	quiet := flag.Bool("quiet", false, "Print only errors")
+	debug := flag.Bool("debug", false, "Print tag-by-tag Exif extraction details (implies -verbose)")
+	drawComment := flag.Bool("comment", false, "Draw user comment (default do not draw user comment)")
+	dumpJson := flag.Bool("dump-json", false, "Write parsed Exif data to <output>.json")
+	exifSidecar := flag.Bool("exif-to-sidecar", false, "Write the original Exif data to <output>.exif.json (use when stripping Exif from the image)")
+	textOutline := flag.Bool("text-outline", false, "Draw outline around text in frame color")
+	textOutlineWidth := flag.Int("text-outline-width", TEXT_OUTLINE_WIDTH, "Width of text outline in pixels (1 to 3)")
+	lensDbPath := flag.String("lens-db", "", "Path to JSON file mapping lens IDs to lens names")
+	layoutJson := flag.Bool("json", false, "Print computed layout as JSON without writing image")
+	noDedup := flag.Bool("no-dedup", false, "Do not omit maker name already included in model name")
+	apertureIcon := flag.Bool("aperture-icon", false, "Draw aperture icon next to exposure data")
+	validate := flag.Bool("validate", false, "Check Exif data against the specification without writing image")
+	contact := flag.Bool("contact", false, "Draw other images as a strip of thumbnails below the first image")
+	format := flag.String("format", DEFAULT_OUTPUT_FORMAT, "Comma-separated output formats: jpeg, png, tiff, bmp (Exif is kept only in jpeg)")
+	frameInset := flag.Int("frame-inset", 0, "Width of inner padding between image and frame in pixels")
+	insetColor := flag.String("inset-color", INSET_COLOR, "Color of inner padding (use with -frame-inset)")
+	labelGap := flag.Int("label-gap", FRAME_PIXEL, "Space in pixels between the image and the label lines")
+	layoutSpecPath := flag.String("layout-spec", "", "Path to JSON file defining label lines (default layout.json)")
+	iccEmbed := flag.String("icc-embed", "", "Path to ICC profile to embed in output (jpeg and png only)")
+	verticalText := flag.String("vertical-text", "", "Place label on the right of portrait images with text rotated cw or ccw")
+	portraitText := flag.String("portrait-text", "", "Place label on the right of portrait images with text horizontal (wrapped to a narrow band) or vertical (rotated, direction from -vertical-text, default ccw)")
+	fromClipboard := flag.Bool("from-clipboard", false, "Read image from clipboard instead of file")
+	toClipboard := flag.Bool("to-clipboard", false, "Copy framed image to clipboard as PNG instead of writing file")
+	manualCamera := flag.String("manual-camera", "", "Camera name to use instead of Exif data (e.g. \"Nikon F3\")")
+	manualLens := flag.String("manual-lens", "", "Lens name to use instead of Exif data")
+	manualExposure := flag.String("manual-exposure", "", "Exposure to use instead of Exif data (e.g. \"50mm f/8 1/125s ISO400\")")
+	manualDate := flag.String("manual-date", "", "Date to use instead of Exif data (e.g. \"2024:05:01 12:34\")")
+	settingsPath := flag.String("settings", "", "Path to JSON file of label values for images without Exif such as video frames (keys: camera, lens, exposure, date)")
+	minRating := flag.Int("min-rating", 0, "Skip images rated below this (0 to 5, from the Exif Rating tag)")
+	maxMegapixels := flag.Float64("max-megapixels", 0, "Skip images larger than this many megapixels (default no limit)")
+	bandOnly := flag.Bool("band-only", false, "Append only the label band below an already-framed image in its border color")
+	borderOnly := flag.Bool("border-only", false, "Write only frame and label with transparent image area (png or tiff)")
+	gpsTime := flag.Bool("gps-time", false, "Show GPS time (UTC) next to capture date")
+	trim := flag.Bool("trim", false, "Trim transparent or uniform-color margins before framing")
+	trimTolerance := flag.Int("trim-tolerance", TRIM_TOLERANCE, "Color difference (0 to 255) treated as margin with -trim")
+	stats := flag.Bool("stats", false, "Print time spent in decode, Exif, compose and encode for each file")
+	progressive := flag.Bool("progressive", false, "Write progressive JPEG instead of baseline")
+	zipPath := flag.String("zip", "", "Frame images in a zip archive instead of files")
+	zipDir := flag.String("zip-dir", ".", "Directory to write framed images from -zip")
+	zipOut := flag.String("zip-out", "", "Write framed images from -zip into a new zip archive instead of -zip-dir")
+	manifest := flag.String("manifest", "", "Write a JSON list of every input with its outputs, Exif data and status (including failures) to this file")
+	noIsoGrouping := flag.Bool("no-iso-grouping", false, "Do not separate thousands in ISO values over 9999")
+	inPlace := flag.Bool("in-place", false, "Replace input files with framed images (requires -force)")
+	force := flag.Bool("force", false, "Allow -in-place to overwrite input files")
+	backup := flag.Bool("backup", false, "Keep original files as <file>.bak with -in-place")
+	inferTz := flag.Bool("infer-tz", false, "Show time zone of capture date (from OffsetTimeOriginal or inferred from GPS time)")
+	report := flag.Bool("report", false, "Print how many key Exif fields are present and which are missing")
+	table := flag.Bool("table", false, "Draw label as a two-column table of field names and values")
+	fontBaselines := flag.Bool("font-baselines", false, "Recompute label row baselines from the ascent/descent of the -font-name font so left and right lines stay aligned")
+	fitLabels := flag.Bool("fit-labels", false, "Shrink label text uniformly so all lines fit in the fixed band height of the layout (band_height)")
+	fontSizePt := flag.Float64("font-size-pt", 0, "Size of regular label text in points at -dpi (other lines scale with it; default sizes from the layout in pixels)")
+	dpi := flag.Float64("dpi", DEFAULT_DPI, "Print resolution used to convert -font-size-pt to pixels")
+	fontName := flag.String("font-name", "", "Family name of a system TrueType font to use instead of the embedded font")
+	fields := flag.String("fields", "", "Comma-separated label lines to draw in order: camera, lens, exposure, date, comment, gps (default all)")
+	theme := flag.String("theme", DEFAULT_THEME, "Style preset: "+themeNames()+" (other flags override the theme)")
+	background := flag.String("background", "", "Path to an image drawn behind the photo and label instead of the frame color")
+	backgroundMode := flag.String("background-mode", BACKGROUND_STRETCH, "How to fill the canvas with -background: stretch or tile")
+	borderImage := flag.String("border-image", "", "Path to a 9-slice image drawn as a decorative frame instead of the frame color")
+	borderSlice := flag.String("border-slice", "", "Slice insets of -border-image in pixels: N or top,right,bottom,left (default a third of the image)")
+	borderRepeat := flag.String("border-repeat", BACKGROUND_STRETCH, "How to fill the edges and center of -border-image: stretch or tile")
+	faststart := flag.Bool("faststart", false, "Move JPEG size and tables ahead of large metadata for faster rendering on the web")
+	keywords := flag.Bool("keywords", false, "Draw XMP keywords (dc:subject) as a small line below the label")
+	frameRadius := flag.Int("frame-radius", 0, "Round the corners of the image with this radius in pixels")
+	roundFrame := flag.Bool("round-frame", false, "Also round the -band-blur band and the outer canvas corners to match -frame-radius (transparent corners in png or tiff)")
+	innerShadow := flag.Bool("inner-shadow", false, "Draw a soft shadow cast by the frame along the inner edges of the image")
+	innerShadowWidth := flag.Int("inner-shadow-width", INNER_SHADOW_WIDTH, "Width of -inner-shadow in pixels")
+	innerShadowOpacity := flag.Float64("inner-shadow-opacity", INNER_SHADOW_OPACITY, "Opacity of -inner-shadow at the image edges (0 to 1)")
+	reveal := flag.Bool("reveal", false, "Also write an animated GIF in which the label band wipes in")
+	extractThumb := flag.Bool("extract-thumb", false, "Also write the embedded Exif thumbnail, upright, to exiframe-*-thumb.jpg")
+	compact := flag.Bool("compact", false, "Draw all label lines joined into a single centered line")
+	compactSeparator := flag.String("compact-separator", COMPACT_SEPARATOR, "Separator between label lines with -compact")
+	focus := flag.Bool("focus", false, "Show subject distance next to lens name")
+	inventory := flag.Bool("inventory", false, "Show camera firmware version (when recorded) next to camera name")
+	sensor := flag.Bool("sensor", false, "Show sensor format (Full Frame, APS-C, MFT...) estimated from focal plane resolution next to camera name")
+	textBackground := flag.String("text-background", TEXT_BACKGROUND_AUTO, "Fill a uniform backing under label lines drawn over mixed colors: auto or off")
+	sizesFlag := flag.String("sizes", "", "Comma-separated widths to also write downscaled copies as exiframe-<name>-<width> (e.g. 400,800,1600)")
+	frameColorMode := flag.String("frame-color", "", "Pick the frame color from the photo: palette (default white, or black with -black)")
+	seed := flag.Uint64("seed", DEFAULT_SEED, "Seed for randomized styling such as pixel sampling in -frame-color palette (the same seed gives the same output)")
+	focalBar := flag.Bool("focal-bar", false, "Draw a ruler marking the focal length (35mm equivalent) in the label band")
+	bandBlur := flag.Bool("band-blur", false, "Fill the label band with a blurred and darkened strip from the bottom of the photo")
+	mark := flag.Bool("mark", false, "Record in jpeg and png output that it was framed by exiframe (see the check subcommand)")
+	aspect := flag.String("aspect", "", "Center-crop the image to this aspect ratio before framing, following its orientation (e.g. 1:1, 3:2)")
+	footerLogo := flag.String("footer-logo", "", "Path to a logo image drawn in a footer strip below the label band")
+	footerText := flag.String("footer-text", "", "Text such as a URL drawn in a footer strip below the label band")
+	footerAlign := flag.String("footer-align", FOOTER_ALIGN_CENTER, "Alignment of the footer contents: center or right")
+	tonemap := flag.Bool("tonemap", false, "Tone-map HDR PNG (PQ or HLG) to SDR before framing (basic mapping)")
+	maxLabelLines := flag.Int("max-label-lines", 0, "Draw at most this many label lines, dropping lines with the lowest layout priority (default no limit)")
+	columns := flag.Int("columns", 0, "Arrange label lines into this many balanced columns (default left and right sides from the layout)")
+	keepTimestamps := flag.Bool("keep-timestamps", false, "Set the modification time of output files to that of the source file")
+	timestampFromExif := flag.Bool("timestamp-from-exif", false, "Use the capture date instead of the source file time with -keep-timestamps")
+	cpuProfile := flag.String("cpuprofile", "", "Write CPU profile to this file on exit")
+	memProfile := flag.String("memprofile", "", "Write memory profile to this file on exit")
+	var excludes excludeFlag
+	flag.Var(&excludes, "exclude", "Skip input files (or -zip entries) whose base name matches a glob (comma-separated or repeatable, e.g. \"*_edit.jpg\")")
+	var exifFields exifFieldFlag
+	flag.Var(&exifFields, "exif-field", "Append a label line with any Exif tag by IFD path and tag ID (IFD:0xID=Label, repeatable, e.g. \"IFD/Exif:0xa431=Serial\")")
+	var renames renameFlag
+	flag.Var(&renames, "rename", "Replace text in label lines (FROM=TO, repeatable, e.g. \"ILCE-7M4=α7 IV\")")
+	renameIgnoreCase := flag.Bool("rename-ignore-case", false, "Match -rename rules case-insensitively")
+	workers := flag.Int("workers", 0, "Number of files to process in parallel (default number of CPUs)")
+	testPattern := flag.String("test-pattern", "", "Frame a generated WxH gradient with fabricated Exif data (for layout debugging)")
+	flag.Usage = usageWithoutHidden
+	flag.Parse()
+
+	switch {
+	case *quiet && (*verbose || *debug):
+		exitUsage("-quiet cannot be used with -verbose or -debug")
+	case *quiet:
+		logLevel = LOG_ERROR
+	case *debug:
+		logLevel = LOG_DEBUG
+	case *verbose:
+		logLevel = LOG_INFO
+	}
+
+	// プロファイルは終了時に書き込む
+	if *cpuProfile != "" || *memProfile != "" {
+		err := startProfiles(*cpuProfile, *memProfile)
+		if err != nil {
+			logError("Error", err)
+			exit(EXIT_IO)
+		}
+		defer stopProfiles()
+	}
+
+	paths := flag.Args()
+	if *filePath != "" {
+		paths = append([]string{*filePath}, paths...)
+	}
+
+	if len(paths) == 0 && !*fromClipboard && *zipPath == "" && *testPattern == "" {
+		exitUsage("Please provide a file path using -f flag")
+	}
+
+	// -exclude に一致するファイルは処理しない
+	excludedCount := 0
+	if len(excludes) > 0 && len(paths) > 0 {
+		paths, excludedCount = excludePaths(paths, excludes)
+		if len(paths) == 0 {
+			logWarn(fmt.Sprintf("Excluded all %d files by -exclude", excludedCount))
+			return
+		}
+	}
+
+	if *textOutlineWidth < 1 || *textOutlineWidth > MAX_TEXT_OUTLINE_WIDTH {
+		exitUsage("Please provide -text-outline-width between 1 and", MAX_TEXT_OUTLINE_WIDTH)
+	}
+
+	if *testPattern != "" {
+		if _, _, err := parseTestPatternSize(*testPattern); err != nil {
+			exitUsage(err)
+		}
+	}
+
+	if *frameRadius < 0 {
+		exitUsage("Please provide -frame-radius of 0 or more")
+	}
+
+	if *innerShadowWidth < 1 {
+		exitUsage("Please provide -inner-shadow-width of 1 or more")
+	}
+
+	if *innerShadowOpacity < 0 || *innerShadowOpacity > 1 {
+		exitUsage("Please provide -inner-shadow-opacity between 0 and 1")
+	}
+
+	if *verticalText != "" && *verticalText != VERTICAL_TEXT_CW && *verticalText != VERTICAL_TEXT_CCW {
+		exitUsage("Please provide -vertical-text cw or ccw")
+	}
+
+	if *fontSizePt < 0 || *dpi <= 0 {
+		exitUsage("Please provide a positive -font-size-pt and -dpi")
+	}
+
+	switch *portraitText {
+	case "":
+	case PORTRAIT_TEXT_VERTICAL:
+		if *verticalText == "" {
+			*verticalText = VERTICAL_TEXT_CCW
+		}
+	case PORTRAIT_TEXT_HORIZONTAL:
+		if *verticalText != "" {
+			exitUsage("Please provide only one of -portrait-text horizontal and -vertical-text")
+		}
+	default:
+		exitUsage("Please provide -portrait-text horizontal or vertical")
+	}
+
+	if *frameInset < 0 {
+		exitUsage("Please provide -frame-inset of 0 or more")
+	}
+
+	if *trimTolerance < 0 || *trimTolerance > 255 {
+		exitUsage("Please provide -trim-tolerance between 0 and 255")
+	}
+
+	if *columns < 0 {
+		exitUsage("Please provide -columns of 0 or more")
+	}
+
+	if *maxLabelLines < 0 {
+		exitUsage("Please provide -max-label-lines of 0 or more")
+	}
+
+	if *labelGap < 0 {
+		exitUsage("Please provide -label-gap of 0 or more")
+	}
+
+	insetUniform, err := parseHexColor(*insetColor)
+	if err != nil {
+		logError("Error", err)
+		exit(EXIT_USAGE)
+	}
+
+	formats, err := parseFormats(*format)
+	if err != nil {
+		logError("Error", err)
+		exit(EXIT_USAGE)
+	}
+
+	// -border-only は透過が必要なため、-format の指定が無ければPNGで出力する
+	if *borderOnly {
+		formatSet := false
+		flag.Visit(func(f *flag.Flag) {
+			formatSet = formatSet || f.Name == "format"
+		})
+		if !formatSet {
+			formats = []string{"png"}
+		}
+		if slices.Contains(formats, "jpeg") {
+			exitUsage("Please provide -format png or tiff with -border-only")
+		}
+	}
+
+	// -in-place は元のファイルを失うため -force を必須とし、1ファイル1形式の通常の処理に限る
+	if *inPlace {
+		if !*force {
+			exitUsage("Please provide -force with -in-place (input files are overwritten)")
+		}
+		if len(formats) != 1 {
+			exitUsage("Please provide a single -format with -in-place")
+		}
+		if *contact || *fromClipboard || *toClipboard || *zipPath != "" || *sizesFlag != "" {
+			exitUsage("Please do not provide -contact, -zip, -sizes or clipboard flags with -in-place")
+		}
+	}
+
+	var lensDb map[string]string
+	if *lensDbPath != "" {
+		lensDb, err = loadLensDb(*lensDbPath)
+		if err != nil {
+			logError("Error loading lens DB:", err)
+			exit(EXIT_USAGE)
+		}
+	}
+
+	var layoutSpec *LayoutSpec
+	if *layoutSpecPath != "" {
+		layoutSpec, err = loadLayoutSpec(*layoutSpecPath)
+		if err != nil {
+			logError("Error loading layout spec:", err)
+			exit(EXIT_USAGE)
+		}
+	}
+
+	// -settings の値は -manual-* で指定されていない項目だけに使う
+	if *settingsPath != "" {
+		settings, err := loadManualSettings(*settingsPath)
+		if err != nil {
+			logError("Error loading settings:", err)
+			exit(EXIT_USAGE)
+		}
+		*manualCamera = cmp.Or(*manualCamera, settings.Camera)
+		*manualLens = cmp.Or(*manualLens, settings.Lens)
+		*manualExposure = cmp.Or(*manualExposure, settings.Exposure)
+		*manualDate = cmp.Or(*manualDate, settings.Date)
+	}
+
+	manual, err := parseManualExif(*manualCamera, *manualLens, *manualExposure, *manualDate)
+	if err != nil {
+		logError("Error", err)
+		exit(EXIT_USAGE)
+	}
+
+	var iccProfile []byte
+	if *iccEmbed != "" {
+		iccProfile, err = loadIccProfile(*iccEmbed)
+		if err != nil {
+			logError("Error loading ICC profile:", err)
+			exit(EXIT_USAGE)
+		}
+	}
+
+	var sizes []int
+	if *sizesFlag != "" {
+		sizes, err = parseSizes(*sizesFlag)
+		if err != nil {
+			logError("Error", err)
+			exit(EXIT_USAGE)
+		}
+	}
+
+	if *textBackground != TEXT_BACKGROUND_AUTO && *textBackground != TEXT_BACKGROUND_OFF {
+		exitUsage("Please provide auto or off with -text-background")
+	}
+
+	if *frameColorMode != "" && *frameColorMode != FRAME_COLOR_PALETTE {
+		exitUsage("Please provide palette with -frame-color")
+	}
+
+	if *backgroundMode != BACKGROUND_STRETCH && *backgroundMode != BACKGROUND_TILE {
+		exitUsage("Please provide stretch or tile with -background-mode")
+	}
+
+	if *borderRepeat != BACKGROUND_STRETCH && *borderRepeat != BACKGROUND_TILE {
+		exitUsage("Please provide stretch or tile with -border-repeat")
+	}
+
+	var borderSliceInsets [4]int
+	if *borderSlice != "" {
+		borderSliceInsets, err = parseBorderSlice(*borderSlice)
+		if err != nil {
+			exitUsage(err)
+		}
+	}
+
+	var aspectRatio float64
+	if *aspect != "" {
+		aspectRatio, err = parseAspect(*aspect)
+		if err != nil {
+			logError("Error", err)
+			exit(EXIT_USAGE)
+		}
+	}
+
+	if *footerAlign != FOOTER_ALIGN_CENTER && *footerAlign != FOOTER_ALIGN_RIGHT {
+		exitUsage("Please provide center or right with -footer-align")
+	}
+
+	var footerLogoImage image.Image
+	if *footerLogo != "" {
+		footerLogoImage, err = loadFooterLogo(*footerLogo)
+		if err != nil {
+			logError("Error", err)
+			exit(EXIT_USAGE)
+		}
+	}
+
+	var backgroundImage image.Image
+	if *background != "" {
+		backgroundImage, err = loadBackground(*background)
+		if err != nil {
+			logError("Error", err)
+			exit(EXIT_USAGE)
+		}
+	}
+
+	var borderImageData image.Image
+	if *borderImage != "" {
+		borderImageData, err = loadBorderImage(*borderImage)
+		if err != nil {
+			logError("Error", err)
+			exit(EXIT_USAGE)
+		}
+	}
+
+	config := &Config{
+		frameColorBlack:  *frameColorBlack,
+		noFrame:          *noFrame,
+		noModelData:      *noModelData,
+		keepExif:         *keepExif,
+		stripGps:         *stripGps,
+		verbose:          *verbose || *debug,
+		layoutJson:       *layoutJson,
+		drawComment:      *drawComment,
+		dumpJson:         *dumpJson,
+		exifSidecar:      *exifSidecar,
+		frameRadius:      *frameRadius,
+		roundFrame:       *roundFrame,
+		apertureIcon:     *apertureIcon,
+		borderOnly:       *borderOnly,
+		bandOnly:         *bandOnly,
+		noDedup:          *noDedup,
+		textOutline:      *textOutline,
+		textOutlineWidth: *textOutlineWidth,
+		frameInset:       *frameInset,
+		labelGap:         *labelGap,
+		gpsTime:          *gpsTime,
+		maxMegapixels:    *maxMegapixels,
+		minRating:        *minRating,
+		verticalText:     *verticalText,
+		portraitText:     *portraitText,
+		brightness:       *brightness,
+		contrast:         *contrast,
+		trim:             *trim,
+		trimTolerance:    *trimTolerance,
+		fontName:         *fontName,
+		table:            *table,
+		report:           *report,
+		inferTz:          *inferTz,
+		inPlace:          *inPlace,
+		backup:           *backup,
+		noIsoGrouping:    *noIsoGrouping,
+		progressive:      *progressive,
+		stats:            *stats,
+		renameIgnoreCase: *renameIgnoreCase,
+		backgroundMode:   *backgroundMode,
+		faststart:        *faststart,
+		reveal:           *reveal,
+		extractThumb:     *extractThumb,
+		innerShadow:      *innerShadow,
+		keywords:         *keywords,
+		sensor:           *sensor,
+		fontBaselines:    *fontBaselines,
+		fitLabels:        *fitLabels,
+		inventory:        *inventory,
+		fontSizePt:       *fontSizePt,
+		dpi:              *dpi,
+		borderSlice:      borderSliceInsets,
+		borderRepeat:     *borderRepeat,
+		shadowWidth:      *innerShadowWidth,
+		shadowOpacity:    *innerShadowOpacity,
+		compact:          *compact,
+		focus:            *focus,
+		textBackground:   *textBackground,
+		sizes:            sizes,
+		focalBar:         *focalBar,
+		frameColorMode:   *frameColorMode,
+		seed:             *seed,
+		keepTimestamps:   *keepTimestamps || *timestampFromExif,
+		columns:          *columns,
+		maxLabelLines:    *maxLabelLines,
+		tonemap:          *tonemap,
+		footerText:       *footerText,
+		footerAlign:      *footerAlign,
+		aspect:           aspectRatio,
+		mark:             *mark,
+		bandBlur:         *bandBlur,
+		exifTimestamp:    *timestampFromExif,
+		compactSeparator: *compactSeparator,
+
+		lensDb:  lensDb,
+		workers: *workers,
+		formats: formats,
+		renames: renames,
+
+		insetColor: insetUniform,
+		exifFields: exifFields,
+		excludes:   excludes,
+		layoutSpec: layoutSpec,
+		iccProfile: iccProfile,
+		background: backgroundImage,
+		footerLogo: footerLogoImage,
+		border:     borderImageData,
+		manual:     manual,
+	}
+	if config.workers < 1 {
+		config.workers = runtime.NumCPU()
+	}
+
+	setFlags := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+
+		// -mark の印には入力ファイル以外のオプションを記録する
+		if f.Name != "f" {
+			config.settings = append(config.settings, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+	err = applyTheme(config, *theme, func(name string) bool {
+		return setFlags[name]
+	})
+	if err != nil {
+		logError("Error", err)
+		exit(EXIT_USAGE)
+	}
+
+	// 見つからない場合は組み込みのフォントを使う
+	if config.fontName != "" {
+		config.fonts, err = findSystemFonts(config.fontName)
+		if err != nil {
+			logWarn("Warning:", err, "(using embedded font)")
+		}
+	}
+
+	// -fields の行の名前は -theme で変わるレイアウト定義から確認する
+	if *fields != "" {
+		spec := config.layoutSpec
+		if spec == nil {
+			spec = DEFAULT_LAYOUT_SPEC
+		}
+		config.fields, err = parseFields(*fields, spec, config)
+		if err != nil {
+			logError("Error", err)
+			exit(EXIT_USAGE)
+		}
+	}
+
+	source := ""
+	switch {
+	case *fromClipboard:
+		source = "from-clipboard"
+	case *zipPath != "":
+		source = "zip"
+	case *testPattern != "":
+		source = "test-pattern"
+	}
+	if err := checkInputFlags(paths, source, *validate, config); err != nil {
+		exitUsage(err)
+	}
+
+	// -test-pattern はレイアウトを調整するためのデバッグ用
+	if *testPattern != "" {
+		outputs, err := writeTestPattern(*testPattern, config)
+		for _, output := range outputs {
+			logInfo("Export file to", output)
+		}
+		if err != nil {
+			logError("Error", err)
+			exit(exitCode(err))
+		}
+		return
+	}
+
+	if config.layoutJson {
+		fSrc, err := os.Open(paths[0])
+		if err != nil {
+			logError("Error opening file:", err)
+			exit(EXIT_IO)
+		}
+		defer fSrc.Close()
+		config.filePath = paths[0]
+		config.fileName = filepath.Base(paths[0])
+
+		err = LayoutToWriter(os.Stdout, fSrc, config)
+		if err != nil {
+			logError("Error", err)
+			exit(exitCode(err))
+		}
+		return
+	}
+
+	if *validate {
+		if !validateFiles(paths, config) {
+			exit(EXIT_USAGE)
+		}
+		return
+	}
+
+	if *contact {
+		if len(paths) < 2 {
+			exitUsage("Please provide two or more files with -contact")
+		}
+
+		outputs, err := frameContact(paths, config)
+		for _, output := range outputs {
+			logInfo("Export file to", output)
+		}
+		if err != nil {
+			logError("Error", err)
+			exit(exitCode(err))
+		}
+		return
+	}
+
+	if *fromClipboard || *toClipboard {
+		if *fromClipboard && len(paths) > 0 {
+			exitUsage("Please do not provide files with -from-clipboard")
+		}
+		if !*fromClipboard && len(paths) > 1 {
+			exitUsage("Please provide a single file with -to-clipboard")
+		}
+
+		var path string
+		if len(paths) > 0 {
+			path = paths[0]
+		}
+
+		outputs, err := frameClipboard(path, *fromClipboard, *toClipboard, config)
+		for _, output := range outputs {
+			logInfo("Export file to", output)
+		}
+		if err != nil {
+			logError("Error", err)
+			exit(exitCode(err))
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var results []Result
+	if *zipPath != "" {
+		if len(paths) > 0 {
+			exitUsage("Please do not provide files with -zip")
+		}
+
+		results, err = frameZip(ctx, *zipPath, *zipDir, *zipOut, config)
+		if err != nil {
+			logError("Error", err)
+			if results == nil {
+				exit(exitCode(err))
+			}
+			results = append(results, Result{Path: *zipPath, Err: err})
+		}
+	} else {
+		// -in-place 以外で入力ファイルを上書きしないようにする
+		if !config.inPlace {
+			err = checkOutputPaths(paths, config)
+			if err != nil {
+				logError("Error", err)
+				exit(EXIT_USAGE)
+			}
+		}
+
+		results, _ = FrameBatch(ctx, paths, config)
+	}
+
+	var firstErr error
+	failed := 0
+	skipped := 0
+	for _, result := range results {
+		for _, output := range result.Outputs {
+			logInfo("Export file to", output)
+		}
+
+		// -max-megapixels を超える画像や -min-rating に満たない画像は失敗とせずスキップする
+		if errors.Is(result.Err, ErrImageTooLarge) || errors.Is(result.Err, ErrBelowMinRating) {
+			skipped++
+			logWarn(fmt.Sprintf("Skip %s: %v", result.Path, result.Err))
+			continue
+		}
+
+		if result.Stats != nil {
+			fmt.Printf("Stats %s: %v\n", result.Path, result.Stats)
+		}
+
+		if result.Err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = result.Err
+			}
+			if len(results) > 1 {
+				logError(fmt.Sprintf("Error %s: %v", result.Path, result.Err))
+			} else {
+				logError("Error", result.Err)
+			}
+		}
+	}
+
+	if config.stats && len(results) > 1 {
+		if summary := formatStatsSummary(results); summary != "" {
+			fmt.Println(summary)
+		}
+	}
+
+	// -manifest の場合は失敗したファイルも含めて結果の一覧を書き出す
+	if *manifest != "" {
+		err = writeManifest(*manifest, results)
+		if err != nil {
+			logError("Error writing manifest:", err)
+			if failed == 0 {
+				exit(EXIT_IO)
+			}
+		} else {
+			logInfo("Export manifest to", *manifest)
+		}
+	}
+
+	if skipped > 0 {
+		logWarn(fmt.Sprintf("Skipped %d of %d files", skipped, len(results)))
+	}
+	if excludedCount > 0 {
+		logInfo(fmt.Sprintf("Excluded %d of %d files by -exclude", excludedCount, excludedCount+len(results)))
+	}
+
+	// 一部だけ失敗した場合は EXIT_PARTIAL、すべて失敗した場合は最初のエラーの種類で終了する
+	if failed > 0 && failed < len(results) {
+		exit(EXIT_PARTIAL)
+	}
+	if failed > 0 {
+		exit(exitCode(firstErr))
+	}
 }
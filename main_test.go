@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	"golang.org/x/image/bmp"
+)
+
+func TestFrameWithoutGpsIfd(t *testing.T) {
+	data := testJpeg(t, 300, 200, testCameraTags()...)
+
+	var buf bytes.Buffer
+	if err := FrameToWriter(&buf, bytes.NewReader(data), testConfig(t)); err != nil {
+		t.Fatalf("FrameToWriter: %v", err)
+	}
+	if _, err := jpeg.Decode(&buf); err != nil {
+		t.Fatalf("output is not a jpeg: %v", err)
+	}
+}
+
+func TestReadExifGpsTime(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []testTag
+		want string
+	}{
+		{"no gps ifd", testCameraTags(), ""},
+		{"gps time", append(testCameraTags(),
+			testTag{GPS_IFD_PATH, "GPSDateStamp", "2024:05:01"},
+			testTag{GPS_IFD_PATH, "GPSTimeStamp", []exifcommon.Rational{{Numerator: 3, Denominator: 1}, {Numerator: 34, Denominator: 1}, {Numerator: 56, Denominator: 1}}},
+		), "2024/05/01 03:34"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exifData, err := ReadExif(bytes.NewReader(testJpeg(t, 60, 40, tt.tags...)))
+			if err != nil {
+				t.Fatalf("ReadExif: %v", err)
+			}
+			if exifData.Make != "SONY" {
+				t.Errorf("Make = %q, want SONY", exifData.Make)
+			}
+			if exifData.GPSDateTime != tt.want {
+				t.Errorf("GPSDateTime = %q, want %q", exifData.GPSDateTime, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatFNumber(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		// 有理数, 小数, 整数のいずれも同じ形式にする
+		{"28/10", "2.8"},
+		{"2.8", "2.8"},
+		{"56/10", "5.6"},
+		{"14/10", "1.4"},
+		{"2.83", "2.8"},
+		{"95/100", "1"},
+
+		// 整数で記録された値
+		{"4", "4"},
+		{"11", "11"},
+		{" 4\x00", "4"},
+		{"4.0", "4"},
+		{"40/10", "4"},
+		{"4/0", "4/0"},
+		{"f4", "f4"},
+	}
+
+	for _, tt := range tests {
+		if got := formatFNumber(tt.value); got != tt.want {
+			t.Errorf("formatFNumber(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFormatExposureTime(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		// 約分して 1/N にする値と小数にする値
+		{"10/2500", "1/250"},
+		{"1/60", "1/60"},
+		{"3/10", "0.3"},
+		{"13/10", "1.3"},
+		{"5/1", "5"},
+		{"1/1", "1"},
+		{"2/3", "0.67"},
+		{"1/0", "1/0"},
+
+		// 整数や小数で記録された値
+		{"4", "4"},
+		{"30", "30"},
+		{"4/1", "4"},
+		{"0.25", "1/4"},
+		{"0.004", "1/250"},
+		{"0.0333", "1/30"},
+		{" 1/60\x00", "1/60"},
+		{"x", "x"},
+	}
+
+	for _, tt := range tests {
+		if got := formatExposureTime(tt.value); got != tt.want {
+			t.Errorf("formatExposureTime(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+// FNumber と ExposureTime が整数 (SHORT) で記録されたExif
+func TestReadExifIntegerExposure(t *testing.T) {
+	data := testJpeg(t, 60, 40,
+		testTag{IFD_PATH, "Make", "TEST"},
+		testTag{EXIF_IFD_PATH, "FNumber", testShort{4}},
+		testTag{EXIF_IFD_PATH, "ExposureTime", testShort{4}},
+	)
+
+	exifData, err := ReadExif(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadExif: %v", err)
+	}
+	if exifData.FNumber != "4" || exifData.ExposureTime != "4" {
+		t.Errorf("FNumber, ExposureTime = %q, %q, want 4, 4", exifData.FNumber, exifData.ExposureTime)
+	}
+
+	var buf bytes.Buffer
+	if err := LayoutToWriter(&buf, bytes.NewReader(data), testConfig(t)); err != nil {
+		t.Fatalf("LayoutToWriter: %v", err)
+	}
+	var layout Layout
+	if err := json.Unmarshal(buf.Bytes(), &layout); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range layout.Lines {
+		if line.Name == "exposure" && !strings.Contains(line.Text, "f/4 ") {
+			t.Errorf("exposure line = %q, want f/4", line.Text)
+		}
+	}
+}
+
+func TestParseDateTimeOriginal(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		layout  string
+		wantErr bool
+	}{
+		{"2024:05:01 12:34:56", "2024/05/01 12:34", "2006:01:02 15:04:05", false},
+		{"2024:05:01 12:34:56\x00", "2024/05/01 12:34", "2006:01:02 15:04:05", false},
+		{"2024:05:01 12:34", "2024/05/01 12:34", "2006:01:02 15:04", false},
+		{"2024:05:01 12:34   ", "2024/05/01 12:34", "2006:01:02 15:04", false},
+
+		// 不明な部分を空白にした値 (CIPA DC-008)
+		{"2024:05:01 12:34:  ", "2024/05/01 12:34", "2006:01:02 15:04", false},
+		{"2024:05:01 12:  :  ", "2024/05/01 12:00", "2006:01:02 15", false},
+		{"2024:05:01   :  :  ", "2024/05/01", "2006:01:02", false},
+		{"2024:05:01", "2024/05/01", "2006:01:02", false},
+
+		// 日時不明
+		{"    :  :     :  :  ", "", "", true},
+		{"", "", "", true},
+		{"2024:05:  ", "", "", true},
+		{"2024-05-01 12:34:56", "", "", true},
+	}
+
+	for _, tt := range tests {
+		output, layout, err := parseDateTimeOriginal(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDateTimeOriginal(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if output != tt.want || layout != tt.layout {
+			t.Errorf("parseDateTimeOriginal(%q) = %q, %q, want %q, %q", tt.value, output, layout, tt.want, tt.layout)
+		}
+	}
+}
+
+// Exifの無い透過PNGは空のExif情報でフレームを付け、透明な部分はフレームの色と合成する
+func TestFrameTransparentPng(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 100, 60))
+	for y := 0; y < 60; y++ {
+		for x := 50; x < 100; x++ {
+			src.Set(x, y, color.NRGBA{0xff, 0, 0, 0x80})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	config := testConfig(t)
+	exifData, err := getExif(buf.Bytes(), config)
+	if err != nil {
+		t.Fatalf("getExif: %v", err)
+	}
+
+	dst, layout, err := composeFrame(buf.Bytes(), config, exifData)
+	if err != nil {
+		t.Fatalf("composeFrame: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		x, y int
+		want color.RGBA
+	}{
+		{"transparent", 10, 10, color.RGBA{0xff, 0xff, 0xff, 0xff}},
+		{"half transparent", 80, 10, color.RGBA{0xff, 0x7f, 0x7f, 0xff}},
+	}
+	for _, tt := range tests {
+		got := dst.RGBAAt(layout.Image.X+tt.x, layout.Image.Y+tt.y)
+		if diff := max(absDiff(got.R, tt.want.R), absDiff(got.G, tt.want.G), absDiff(got.B, tt.want.B)); diff > 1 || got.A != 0xff {
+			t.Errorf("%s pixel = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Exifの無いPNGやBMPは -keep-exif でもExifを埋め込まずに保存する
+func TestKeepExifWithoutExif(t *testing.T) {
+	src := testImage(60, 40, color.Gray{128})
+	var pngData, bmpData bytes.Buffer
+	if err := png.Encode(&pngData, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := bmp.Encode(&bmpData, src); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{"png": pngData.Bytes(), "bmp": bmpData.Bytes()} {
+		config := testConfig(t)
+		config.keepExif = true
+
+		var buf bytes.Buffer
+		if err := FrameToWriter(&buf, bytes.NewReader(data), config); err != nil {
+			t.Errorf("%s: FrameToWriter: %v", name, err)
+			continue
+		}
+		if _, err := jpeg.Decode(bytes.NewReader(buf.Bytes())); err != nil {
+			t.Errorf("%s: decoding the output: %v", name, err)
+		}
+		if _, err := extractRawExif(buf.Bytes()); err == nil {
+			t.Errorf("%s: output has Exif, want none", name)
+		}
+	}
+}
+
+// JPEGはExifが無ければエラーにする
+func TestFrameJpegWithoutExif(t *testing.T) {
+	err := FrameToWriter(&bytes.Buffer{}, bytes.NewReader(testJpeg(t, 60, 40)), testConfig(t))
+	if err == nil {
+		t.Fatal("FrameToWriter succeeded, want error")
+	}
+	if got := exitCode(err); got != EXIT_DECODE {
+		t.Errorf("exitCode = %d, want %d", got, EXIT_DECODE)
+	}
+}
+
+// -strip-gps はGPS IFDだけを除き、他のタグは残す
+func TestStripGps(t *testing.T) {
+	data := testJpeg(t, 120, 80, append(testCameraTags(),
+		testTag{GPS_IFD_PATH, "GPSDateStamp", "2024:05:01"},
+		testTag{GPS_IFD_PATH, "GPSTimeStamp", []exifcommon.Rational{{Numerator: 3, Denominator: 1}, {Numerator: 34, Denominator: 1}, {Numerator: 56, Denominator: 1}}},
+	)...)
+
+	for _, stripGps := range []bool{false, true} {
+		config := testConfig(t)
+		config.keepExif = true
+		config.stripGps = stripGps
+
+		var buf bytes.Buffer
+		if err := FrameToWriter(&buf, bytes.NewReader(data), config); err != nil {
+			t.Fatalf("FrameToWriter: %v", err)
+		}
+
+		exifData, err := ReadExif(&buf)
+		if err != nil {
+			t.Fatalf("ReadExif: %v", err)
+		}
+		if exifData.Make != "SONY" || exifData.Model != "ILCE-7M4" || exifData.DateTimeOriginal != "2024/05/01 12:34" {
+			t.Errorf("stripGps %v: Make, Model, DateTimeOriginal = %q, %q, %q", stripGps, exifData.Make, exifData.Model, exifData.DateTimeOriginal)
+		}
+		if hasGps := exifData.GPSDateTime != ""; hasGps == stripGps {
+			t.Errorf("stripGps %v: GPSDateTime = %q", stripGps, exifData.GPSDateTime)
+		}
+	}
+}
+
+func TestJoinMakeModelDedup(t *testing.T) {
+	tests := []struct {
+		maker, model string
+		dedup        bool
+		want         string
+	}{
+		{"SONY", "ILCE-7M4", true, "SONY ILCE-7M4"},
+		{"SONY", "SONY ILCE-7M4", true, "SONY ILCE-7M4"},
+		{"Canon", "Canon EOS R5", true, "Canon EOS R5"},
+		{"CANON", "Canon EOS R5", true, "Canon EOS R5"},
+		{"NIKON CORPORATION", "NIKON Z 6", true, "NIKON Z 6"},
+		{"FUJIFILM", "X-T5", true, "FUJIFILM X-T5"},
+
+		// -no-dedup
+		{"SONY", "SONY ILCE-7M4", false, "SONY SONY ILCE-7M4"},
+		{"NIKON CORPORATION", "NIKON Z 6", false, "NIKON CORPORATION NIKON Z 6"},
+	}
+
+	for _, tt := range tests {
+		if got := joinMakeModel(tt.maker, tt.model, tt.dedup); got != tt.want {
+			t.Errorf("joinMakeModel(%q, %q, %v) = %q, want %q", tt.maker, tt.model, tt.dedup, got, tt.want)
+		}
+	}
+}
+
+// メーカー名とモデル名の一方が空の場合や余分な空白がある場合
+func TestJoinMakeModelSpaces(t *testing.T) {
+	tests := []struct {
+		maker, model string
+		want         string
+	}{
+		{"", "Canon EOS R5", "Canon EOS R5"},
+		{"Canon", "", "Canon"},
+		{"", "", ""},
+		{"  ", "X100V  ", "X100V"},
+		{"SONY", "SONY  ILCE-7M4", "SONY ILCE-7M4"},
+		{"FUJIFILM ", " X-T5\x00", "FUJIFILM X-T5"},
+		{"Google", "Pixel  8   Pro", "Google Pixel 8 Pro"},
+	}
+
+	for _, tt := range tests {
+		if got := joinMakeModel(tt.maker, tt.model, true); got != tt.want {
+			t.Errorf("joinMakeModel(%q, %q) = %q, want %q", tt.maker, tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestApexToExposureTime(t *testing.T) {
+	tests := []struct {
+		apex float64
+		want string
+	}{
+		{8, "1/256"},
+		{6.906891, "1/120"},
+		{5, "1/32"},
+		{1, "1/2"},
+		{0, "1"},
+		{-1, "2"},
+		{-5, "32"},
+	}
+
+	for _, tt := range tests {
+		if got := apexToExposureTime(tt.apex); got != tt.want {
+			t.Errorf("apexToExposureTime(%v) = %q, want %q", tt.apex, got, tt.want)
+		}
+	}
+}
+
+// ExposureTimeが無い場合はShutterSpeedValue (SRATIONAL) から求める
+func TestShutterSpeedValueFallback(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []testTag
+		want string
+	}{
+		{"apex 8", []testTag{{EXIF_IFD_PATH, "ShutterSpeedValue", []exifcommon.SignedRational{{Numerator: 8, Denominator: 1}}}}, "1/256"},
+		{"negative apex", []testTag{{EXIF_IFD_PATH, "ShutterSpeedValue", []exifcommon.SignedRational{{Numerator: -2, Denominator: 1}}}}, "4"},
+		{"exposure time first", []testTag{
+			{EXIF_IFD_PATH, "ExposureTime", []exifcommon.Rational{{Numerator: 1, Denominator: 250}}},
+			{EXIF_IFD_PATH, "ShutterSpeedValue", []exifcommon.SignedRational{{Numerator: 8, Denominator: 1}}},
+		}, "1/250"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags := append([]testTag{{IFD_PATH, "Make", "TEST"}}, tt.tags...)
+			exifData, err := ReadExif(bytes.NewReader(testJpeg(t, 60, 40, tags...)))
+			if err != nil {
+				t.Fatalf("ReadExif: %v", err)
+			}
+			if exifData.ExposureTime != tt.want {
+				t.Errorf("ExposureTime = %q, want %q", exifData.ExposureTime, tt.want)
+			}
+		})
+	}
+}
+
+// 複数の値を持つタグはすべての値を読む
+func TestReadExifMultipleValues(t *testing.T) {
+	rational := func(n, d uint32) exifcommon.Rational { return exifcommon.Rational{Numerator: n, Denominator: d} }
+
+	data := testJpeg(t, 60, 40,
+		testTag{IFD_PATH, "Make", "TEST"},
+		testTag{EXIF_IFD_PATH, "ISOSpeedRatings", []uint16{0, 800}},
+		testTag{EXIF_IFD_PATH, "LensSpecification", []exifcommon.Rational{rational(24, 1), rational(70, 1), rational(28, 10), rational(28, 10)}},
+		testTag{GPS_IFD_PATH, "GPSDateStamp", "2024:05:01"},
+		testTag{GPS_IFD_PATH, "GPSTimeStamp", []exifcommon.Rational{rational(3, 1), rational(34, 1), rational(5650, 100)}},
+	)
+
+	config := testConfig(t)
+	config.exifFields = []ExifField{{EXIF_IFD_PATH, 0xa432, "Spec"}}
+	exifData, err := getExif(data, config)
+	if err != nil {
+		t.Fatalf("getExif: %v", err)
+	}
+
+	// 先頭の0は使わない
+	if exifData.PhotographicSensitivity != "800" {
+		t.Errorf("PhotographicSensitivity = %q, want 800", exifData.PhotographicSensitivity)
+	}
+	// 時, 分, 秒の3つの値
+	if exifData.GPSDateTime != "2024/05/01 03:34" {
+		t.Errorf("GPSDateTime = %q, want 2024/05/01 03:34", exifData.GPSDateTime)
+	}
+	if want := []string{"Spec: 24/1 70/1 28/10 28/10"}; len(config.exifLines) != 1 || config.exifLines[0] != want[0] {
+		t.Errorf("exifLines = %q, want %q", config.exifLines, want)
+	}
+}
+
+// -json は1つのファイル、-validate はファイルだけを受け付ける
+func TestCheckInputFlags(t *testing.T) {
+	tests := []struct {
+		name       string
+		paths      []string
+		source     string
+		validate   bool
+		layoutJson bool
+		report     bool
+		wantErr    bool
+	}{
+		{"json with a file", []string{"a.jpg"}, "", false, true, false, false},
+		{"json from clipboard", nil, "from-clipboard", false, true, false, true},
+		{"json with zip", nil, "zip", false, true, false, true},
+		{"json with test pattern", nil, "test-pattern", false, true, false, true},
+		{"json without files", nil, "", false, true, false, true},
+		{"json with files", []string{"a.jpg", "b.jpg"}, "", false, true, false, true},
+		{"validate files", []string{"a.jpg", "b.jpg"}, "", true, false, false, false},
+		{"validate zip", nil, "zip", true, false, false, true},
+		{"validate clipboard", nil, "from-clipboard", true, false, false, true},
+		{"report zip", nil, "zip", false, false, true, false},
+		{"report test pattern", nil, "test-pattern", false, false, true, true},
+		{"clipboard", nil, "from-clipboard", false, false, false, false},
+		{"zip", nil, "zip", false, false, false, false},
+		{"files", []string{"a.jpg", "b.jpg"}, "", false, false, false, false},
+	}
+	for _, tt := range tests {
+		config := testConfig(t)
+		config.layoutJson = tt.layoutJson
+		config.report = tt.report
+		if err := checkInputFlags(tt.paths, tt.source, tt.validate, config); (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkInputFlags = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
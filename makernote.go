@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dsoprea/go-exif/v3"
+)
+
+/*
+# MakerNote
+
+メーカー独自のタグ領域。多くのメーカーはExifと同じIFD形式で記録するが、ヘッダーの有無や
+オフセットの基準位置がメーカーごとに異なる。
+
+- Canon: ヘッダー無し。オフセットはExifのTIFFヘッダーが基準
+- Nikon (Type 3): "Nikon\0" + バージョン(4Byte) の後に独自のTIFFヘッダーを持つ。
+  オフセットはこのTIFFヘッダーが基準
+- Olympus / OM System: "OLYMPUS\0II\3\0" または "OM SYSTEM\0\0\0II\4\0" の後にIFDが続く。
+  オフセットはMakerNoteの先頭が基準。テレコンバーターは Equipment IFD の ExtenderModel に記録される
+*/
+
+const (
+	MAKER_NOTE_TAG_ID = 0x927c
+
+	CANON_CAMERA_SETTINGS_TAG_ID = 0x0001
+	CANON_LENS_TYPE_INDEX        = 22
+
+	NIKON_MAKER_NOTE_HEADER  = "Nikon\x00"
+	NIKON_TIFF_HEADER_OFFSET = 10
+	NIKON_LENS_TYPE_TAG_ID   = 0x0083
+	NIKON_LENS_TAG_ID        = 0x0084
+	NIKON_LENS_DATA_TAG_ID   = 0x0098
+
+	OLYMPUS_MAKER_NOTE_HEADER   = "OLYMPUS\x00"
+	OM_SYSTEM_MAKER_NOTE_HEADER = "OM SYSTEM\x00\x00\x00"
+	OLYMPUS_EQUIPMENT_TAG_ID    = 0x2010
+	OLYMPUS_EXTENDER_MODEL_ID   = 0x0303
+)
+
+// よく使われるレンズIDとレンズ名の対応 (-lens-db で追加・上書きできる)
+// Canon: "canon:<LensType>"
+// Nikon: "nikon:<LensIDNumber> <LensFStops> <MinFocalLength> <MaxFocalLength> <MaxApertureAtMinFocal> <MaxApertureAtMaxFocal> <MCUVersion> <LensType>"
+var LENS_ID_MAP = map[string]string{
+	"canon:1":    "Canon EF 50mm f/1.8",
+	"canon:2":    "Canon EF 28mm f/2.8",
+	"canon:124":  "Canon MP-E 65mm f/2.8 1-5x Macro Photo",
+	"canon:125":  "Canon TS-E 24mm f/3.5L",
+	"canon:126":  "Canon TS-E 45mm f/2.8",
+	"canon:127":  "Canon TS-E 90mm f/2.8",
+	"canon:129":  "Canon EF 300mm f/2.8L USM",
+	"canon:254":  "Canon EF 100mm f/2.8L Macro IS USM",
+	"canon:4142": "Canon EF-S 18-135mm f/3.5-5.6 IS STM",
+
+	"nikon:01 58 50 50 14 14 02 00": "AF Nikkor 50mm f/1.8",
+	"nikon:01 58 50 50 14 14 05 00": "AF Nikkor 50mm f/1.8",
+	"nikon:02 42 44 5C 2A 34 02 00": "AF Zoom-Nikkor 35-70mm f/3.3-4.5",
+	"nikon:06 54 53 53 24 24 06 00": "AF Micro-Nikkor 55mm f/2.8",
+}
+
+// MakerNoteのIFDエントリ
+type makerNoteEntry struct {
+	tagType uint16
+	count   uint32
+	value   []byte
+}
+
+// タグの型ごとの1要素のバイト数
+var TAG_TYPE_SIZE = map[uint16]uint32{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 13: 4,
+}
+
+// dataのifdOffsetにあるIFDのエントリを読み込む (値のオフセットはdataの先頭が基準)
+func readMakerNoteIfd(data []byte, ifdOffset uint32, byteOrder binary.ByteOrder) (map[uint16]makerNoteEntry, error) {
+	if uint64(ifdOffset)+2 > uint64(len(data)) {
+		return nil, fmt.Errorf("IFD offset out of range: %d", ifdOffset)
+	}
+
+	count := uint32(byteOrder.Uint16(data[ifdOffset:]))
+	if uint64(ifdOffset)+2+uint64(count)*12 > uint64(len(data)) {
+		return nil, fmt.Errorf("IFD entries out of range: %d entries", count)
+	}
+
+	entries := make(map[uint16]makerNoteEntry, count)
+	for i := uint32(0); i < count; i++ {
+		e := data[ifdOffset+2+i*12:]
+		tagId := byteOrder.Uint16(e[0:])
+		tagType := byteOrder.Uint16(e[2:])
+		n := byteOrder.Uint32(e[4:])
+
+		size, ok := TAG_TYPE_SIZE[tagType]
+		if !ok {
+			continue
+		}
+
+		length := uint64(size) * uint64(n)
+		var value []byte
+		if length <= 4 {
+			value = e[8 : 8+length]
+		} else {
+			offset := uint64(byteOrder.Uint32(e[8:]))
+			if offset+length > uint64(len(data)) {
+				continue
+			}
+			value = data[offset : offset+length]
+		}
+
+		entries[tagId] = makerNoteEntry{tagType, n, value}
+	}
+
+	return entries, nil
+}
+
+// MakerNoteに記録されたレンズIDからレンズ名を求める
+// 対応表に無い場合はレンズIDをそのまま返し、レンズIDが得られない場合は空文字列を返す
+func resolveLensModel(rawExif []byte, exifIfd *exif.Ifd, cameraMake string, lensDb map[string]string) (string, error) {
+	results, err := exifIfd.FindTagWithId(MAKER_NOTE_TAG_ID)
+	if err != nil || len(results) == 0 {
+		return "", nil
+	}
+
+	makerNote, err := results[0].GetRawBytes()
+	if err != nil {
+		return "", fmt.Errorf("GetRawBytes MakerNote: %w", err)
+	}
+
+	var key, fallback string
+	switch {
+	case strings.HasPrefix(strings.ToUpper(cameraMake), "CANON"):
+		key, err = canonLensId(rawExif, makerNote, exifIfd.ByteOrder())
+	case strings.HasPrefix(strings.ToUpper(cameraMake), "NIKON"):
+		key, fallback, err = nikonLensId(makerNote)
+	default:
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if key != "" {
+		if name, ok := lensDb[key]; ok {
+			return name, nil
+		}
+		if name, ok := LENS_ID_MAP[key]; ok {
+			return name, nil
+		}
+	}
+
+	if fallback != "" {
+		return fallback, nil
+	}
+
+	if key != "" {
+		_, id, _ := strings.Cut(key, ":")
+		return "LensID " + id, nil
+	}
+
+	return "", nil
+}
+
+// CanonのCameraSettingsからLensTypeを取得する
+func canonLensId(rawExif []byte, makerNote []byte, byteOrder binary.ByteOrder) (string, error) {
+	// オフセットはTIFFヘッダー基準のため、Exif内でのMakerNoteの位置を求める
+	pos := bytes.Index(rawExif, makerNote)
+	if pos < 0 {
+		return "", fmt.Errorf("MakerNote not found in Exif data")
+	}
+
+	entries, err := readMakerNoteIfd(rawExif, uint32(pos), byteOrder)
+	if err != nil {
+		return "", fmt.Errorf("reading Canon MakerNote: %w", err)
+	}
+
+	settings, ok := entries[CANON_CAMERA_SETTINGS_TAG_ID]
+	if !ok || settings.tagType != 3 || settings.count <= CANON_LENS_TYPE_INDEX {
+		return "", nil
+	}
+
+	lensType := byteOrder.Uint16(settings.value[CANON_LENS_TYPE_INDEX*2:])
+	if lensType == 0 || lensType == 0xffff {
+		return "", nil
+	}
+
+	return "canon:" + strconv.Itoa(int(lensType)), nil
+}
+
+// NikonのLensDataから複合レンズIDを取得する
+// LensDataが暗号化されている場合はLensタグの焦点距離と開放F値から表記を作成する
+func nikonLensId(makerNote []byte) (key string, fallback string, err error) {
+	if !bytes.HasPrefix(makerNote, []byte(NIKON_MAKER_NOTE_HEADER)) || len(makerNote) < NIKON_TIFF_HEADER_OFFSET+8 {
+		return "", "", nil
+	}
+
+	data := makerNote[NIKON_TIFF_HEADER_OFFSET:]
+
+	var byteOrder binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		byteOrder = binary.LittleEndian
+	case "MM":
+		byteOrder = binary.BigEndian
+	default:
+		return "", "", fmt.Errorf("invalid Nikon MakerNote byte order")
+	}
+
+	entries, err := readMakerNoteIfd(data, byteOrder.Uint32(data[4:]), byteOrder)
+	if err != nil {
+		return "", "", fmt.Errorf("reading Nikon MakerNote: %w", err)
+	}
+
+	// Lens: 最短焦点距離, 最長焦点距離, 最短焦点距離での開放F値, 最長焦点距離での開放F値
+	if lens, ok := entries[NIKON_LENS_TAG_ID]; ok && lens.tagType == 5 && lens.count == 4 {
+		var v [4]float64
+		for i := range v {
+			n := byteOrder.Uint32(lens.value[i*8:])
+			d := byteOrder.Uint32(lens.value[i*8+4:])
+			if d != 0 {
+				v[i] = float64(n) / float64(d)
+			}
+		}
+		if v[0] > 0 {
+			fallback = formatLensSpec(v[0], v[1], v[2], v[3])
+		}
+	}
+
+	lensData, ok := entries[NIKON_LENS_DATA_TAG_ID]
+	if !ok || len(lensData.value) < 4 {
+		return "", fallback, nil
+	}
+
+	// 0100, 0101 以外のバージョンは暗号化されている
+	var start int
+	switch string(lensData.value[:4]) {
+	case "0100":
+		start = 0x06
+	case "0101":
+		start = 0x0b
+	default:
+		return "", fallback, nil
+	}
+
+	lensType, ok := entries[NIKON_LENS_TYPE_TAG_ID]
+	if !ok || len(lensData.value) < start+7 || len(lensType.value) < 1 {
+		return "", fallback, nil
+	}
+
+	id := append(append([]byte{}, lensData.value[start:start+7]...), lensType.value[0])
+	parts := make([]string, len(id))
+	for i, b := range id {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+
+	return "nikon:" + strings.Join(parts, " "), fallback, nil
+}
+
+// MakerNoteに記録されたテレコンバーターの名前を求める (Olympus / OM System のみ、記録が無い場合は空文字列)
+func resolveTeleconverter(exifIfd *exif.Ifd) (string, error) {
+	results, err := exifIfd.FindTagWithId(MAKER_NOTE_TAG_ID)
+	if err != nil || len(results) == 0 {
+		return "", nil
+	}
+
+	makerNote, err := results[0].GetRawBytes()
+	if err != nil {
+		return "", fmt.Errorf("GetRawBytes MakerNote: %w", err)
+	}
+
+	return olympusExtenderModel(makerNote)
+}
+
+// Olympus / OM System の Equipment IFD から ExtenderModel を取得する
+func olympusExtenderModel(makerNote []byte) (string, error) {
+	// IFDの位置 (バイトオーダーの直後の2Byteはバージョン)
+	var ifdOffset int
+	switch {
+	case bytes.HasPrefix(makerNote, []byte(OLYMPUS_MAKER_NOTE_HEADER)):
+		ifdOffset = len(OLYMPUS_MAKER_NOTE_HEADER) + 4
+	case bytes.HasPrefix(makerNote, []byte(OM_SYSTEM_MAKER_NOTE_HEADER)):
+		ifdOffset = len(OM_SYSTEM_MAKER_NOTE_HEADER) + 4
+	default:
+		return "", nil
+	}
+	if len(makerNote) < ifdOffset {
+		return "", nil
+	}
+
+	var byteOrder binary.ByteOrder
+	switch string(makerNote[ifdOffset-4 : ifdOffset-2]) {
+	case "II":
+		byteOrder = binary.LittleEndian
+	case "MM":
+		byteOrder = binary.BigEndian
+	default:
+		return "", fmt.Errorf("invalid Olympus MakerNote byte order")
+	}
+
+	entries, err := readMakerNoteIfd(makerNote, uint32(ifdOffset), byteOrder)
+	if err != nil {
+		return "", fmt.Errorf("reading Olympus MakerNote: %w", err)
+	}
+
+	equipment, ok := entries[OLYMPUS_EQUIPMENT_TAG_ID]
+	if !ok || len(equipment.value) < 4 {
+		return "", nil
+	}
+
+	equipmentEntries, err := readMakerNoteIfd(makerNote, byteOrder.Uint32(equipment.value), byteOrder)
+	if err != nil {
+		return "", fmt.Errorf("reading Olympus Equipment: %w", err)
+	}
+
+	extender, ok := equipmentEntries[OLYMPUS_EXTENDER_MODEL_ID]
+	if !ok || extender.tagType != 2 {
+		return "", nil
+	}
+
+	return strings.TrimSpace(strings.TrimRight(string(extender.value), "\x00")), nil
+}
+
+// 焦点距離と開放F値からレンズの表記を作成する (例: 18-55mm f/3.5-5.6)
+func formatLensSpec(minFocal, maxFocal, minFNumber, maxFNumber float64) string {
+	focal := strconv.FormatFloat(minFocal, 'f', -1, 64)
+	if maxFocal > minFocal {
+		focal += "-" + strconv.FormatFloat(maxFocal, 'f', -1, 64)
+	}
+
+	fNumber := strconv.FormatFloat(minFNumber, 'f', -1, 64)
+	if maxFNumber > minFNumber {
+		fNumber += "-" + strconv.FormatFloat(maxFNumber, 'f', -1, 64)
+	}
+
+	return focal + "mm f/" + fNumber
+}
+
+// -lens-db で指定されたJSONファイル ({"canon:1": "Canon EF 50mm f/1.8", ...}) を読み込む
+func loadLensDb(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lensDb := map[string]string{}
+	err = json.Unmarshal(data, &lensDb)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	return lensDb, nil
+}
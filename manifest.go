@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+/*
+# 処理結果の一覧 (-manifest)
+
+複数のファイルを処理した後、ギャラリーの生成などで使えるよう、すべての入力ファイルの結果を1つのJSONファイルに書き出す。
+失敗したファイルやスキップしたファイルもエラーとともに記録する。
+
+	{
+	  "files": [
+	    {
+	      "input": "a.jpg",
+	      "outputs": ["exiframe-a.jpg"],
+	      "status": "ok",
+	      "exif": {"Make": "FUJIFILM", "Model": "X-T5", ...}
+	    },
+	    {
+	      "input": "b.jpg",
+	      "status": "failed",
+	      "error": "opening file: ..."
+	    }
+	  ]
+	}
+
+- status: ok (成功)、skipped (-max-megapixels や -min-rating でスキップ)、failed (失敗、一部の形式だけ書き出せた場合は outputs もある)
+- exif: 読み込んだExif情報 (-dump-json の exif と同じ形式、読み込む前に失敗した場合や -zip の場合は無い)
+*/
+
+const (
+	MANIFEST_OK      = "ok"
+	MANIFEST_SKIPPED = "skipped"
+	MANIFEST_FAILED  = "failed"
+)
+
+type ManifestEntry struct {
+	Input   string    `json:"input"`
+	Outputs []string  `json:"outputs,omitempty"`
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Exif    *ExifData `json:"exif,omitempty"`
+}
+
+// 処理結果をJSONファイルに書き出す
+func writeManifest(path string, results []Result) error {
+	entries := make([]ManifestEntry, 0, len(results))
+	for _, result := range results {
+		entry := ManifestEntry{
+			Input:   result.Path,
+			Outputs: result.Outputs,
+			Status:  MANIFEST_OK,
+			Exif:    result.Exif,
+		}
+
+		switch {
+		case errors.Is(result.Err, ErrImageTooLarge) || errors.Is(result.Err, ErrBelowMinRating):
+			entry.Status = MANIFEST_SKIPPED
+		case result.Err != nil:
+			entry.Status = MANIFEST_FAILED
+		}
+		if result.Err != nil {
+			entry.Error = result.Err.Error()
+		}
+
+		entries = append(entries, entry)
+	}
+
+	data, err := json.MarshalIndent(struct {
+		Files []ManifestEntry `json:"files"`
+	}{entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// -settings のJSON
+// 動画から切り出した静止画などExifの無い画像のラベルの値で、各項目は -manual-* と同じ形式
+type manualSettings struct {
+	Camera   string `json:"camera"`
+	Lens     string `json:"lens"`
+	Exposure string `json:"exposure"`
+	Date     string `json:"date"`
+}
+
+func loadManualSettings(path string) (*manualSettings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &manualSettings{}
+	err = json.Unmarshal(data, settings)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	return settings, nil
+}
+
+// -manual-* で指定された値からExif情報を作成する (指定が無い場合はnil)
+// exposure は "50mm f/2.8 1/125s ISO400" のように空白区切りで、一部だけでもよい
+func parseManualExif(camera, lens, exposure, date string) (*ExifData, error) {
+	if camera == "" && lens == "" && exposure == "" && date == "" {
+		return nil, nil
+	}
+
+	manual := &ExifData{
+		Model:     camera,
+		LensModel: lens,
+	}
+
+	for _, field := range strings.Fields(exposure) {
+		switch {
+		case strings.HasSuffix(field, "mm"):
+			manual.FocalLengthIn35mmFilm = strings.TrimSuffix(field, "mm")
+		case strings.HasPrefix(field, "f/"):
+			manual.FNumber = formatFNumber(strings.TrimPrefix(field, "f/"))
+		case strings.HasPrefix(strings.ToUpper(field), "ISO"):
+			manual.PhotographicSensitivity = field[len("ISO"):]
+		case strings.HasSuffix(field, "s"):
+			manual.ExposureTime = formatExposureTime(strings.TrimSuffix(field, "s"))
+		default:
+			return nil, fmt.Errorf("unknown exposure value: %q", field)
+		}
+	}
+
+	if date != "" {
+		// "2006-01-02" や "2006/01/02" もExifの形式として読む
+		value := strings.NewReplacer("-", ":", "/", ":").Replace(date)
+		output, _, err := parseDateTimeOriginal(value)
+		if err != nil {
+			return nil, err
+		}
+		manual.DateTimeOriginal = output
+	}
+
+	return manual, nil
+}
+
+// 入力ファイルごとにExif情報を上書きするJSONファイルの接尾辞 (photo.jpg に対して photo.jpg.exiframe.json)
+const OVERRIDE_SUFFIX = ".exiframe.json"
+
+// <入力ファイル>.exiframe.json があれば、書かれている項目だけExif情報を上書きする (無い場合は何もしない)
+// キーは ExifData の項目名で、値はラベルに表示する形式 (例: {"FNumber": "2.8", "ExposureTime": "1/250"})
+func applyOverrideFile(exifData *ExifData, path string) error {
+	data, err := os.ReadFile(path + OVERRIDE_SUFFIX)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return ioError(fmt.Errorf("reading override: %w", err))
+	}
+
+	err = json.Unmarshal(data, exifData)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", path+OVERRIDE_SUFFIX, err)
+	}
+	return nil
+}
+
+// 手入力の値でExif情報を上書きする (指定されていない項目はそのまま)
+// カメラとレンズはメーカー名を含めて入力するため、メーカー名は消す
+func applyManualExif(exifData *ExifData, manual *ExifData) {
+	if manual.Model != "" {
+		exifData.Make = ""
+		exifData.Model = manual.Model
+	}
+	if manual.LensModel != "" {
+		exifData.LensMake = ""
+		exifData.LensModel = manual.LensModel
+	}
+
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&exifData.FocalLengthIn35mmFilm, manual.FocalLengthIn35mmFilm},
+		{&exifData.FNumber, manual.FNumber},
+		{&exifData.ExposureTime, manual.ExposureTime},
+		{&exifData.PhotographicSensitivity, manual.PhotographicSensitivity},
+		{&exifData.DateTimeOriginal, manual.DateTimeOriginal},
+	} {
+		if f.src != "" {
+			*f.dst = f.src
+		}
+	}
+}
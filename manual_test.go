@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseManualExif(t *testing.T) {
+	manual, err := parseManualExif("Nikon F3", "Nikkor 50mm f/1.4", "50mm f/8 1/125s ISO400", "2024-05-01 12:34")
+	if err != nil {
+		t.Fatalf("parseManualExif: %v", err)
+	}
+
+	want := ExifData{
+		Model:                   "Nikon F3",
+		LensModel:               "Nikkor 50mm f/1.4",
+		FocalLengthIn35mmFilm:   "50",
+		FNumber:                 "8",
+		ExposureTime:            "1/125",
+		PhotographicSensitivity: "400",
+		DateTimeOriginal:        "2024/05/01 12:34",
+	}
+	if *manual != want {
+		t.Errorf("parseManualExif = %+v, want %+v", *manual, want)
+	}
+
+	if manual, err := parseManualExif("", "", "", ""); manual != nil || err != nil {
+		t.Errorf("parseManualExif with no values = %v, %v, want nil", manual, err)
+	}
+	if _, err := parseManualExif("", "", "50mm x8", ""); err == nil {
+		t.Error("parseManualExif with an unknown exposure value succeeded, want error")
+	}
+}
+
+// 手入力の値はExifの値より優先し、指定しない項目はExifの値のまま
+func TestManualExifOverridesExif(t *testing.T) {
+	manual, err := parseManualExif("Nikon F3", "", "f/8 ISO100", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := testConfig(t)
+	config.manual = manual
+
+	data := testJpeg(t, 60, 40, testCameraTags()...)
+	exifData, err := getExif(data, config)
+	if err != nil {
+		t.Fatalf("getExif: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		got, want string
+	}{
+		{"Make", exifData.Make, ""},
+		{"Model", exifData.Model, "Nikon F3"},
+		{"FNumber", exifData.FNumber, "8"},
+		{"PhotographicSensitivity", exifData.PhotographicSensitivity, "100"},
+		{"LensModel", exifData.LensModel, "FE 50mm F1.4 GM"},
+		{"ExposureTime", exifData.ExposureTime, "1/250"},
+		{"DateTimeOriginal", exifData.DateTimeOriginal, "2024/05/01 12:34"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+// Exifの無い画像 (フィルムのスキャン) は手入力の値だけでラベルを作る
+func TestManualExifWithoutExif(t *testing.T) {
+	manual, err := parseManualExif("Nikon F3", "Nikkor 50mm f/1.4", "", "1985:06:01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := testConfig(t)
+	config.manual = manual
+
+	exifData, err := getExif(testJpeg(t, 60, 40), config)
+	if err != nil {
+		t.Fatalf("getExif: %v", err)
+	}
+	if exifData.Model != "Nikon F3" || exifData.LensModel != "Nikkor 50mm f/1.4" || exifData.DateTimeOriginal != "1985/06/01" {
+		t.Errorf("exifData = %+v", *exifData)
+	}
+	if exifData.FNumber != "" || exifData.ExposureTime != "" {
+		t.Errorf("FNumber, ExposureTime = %q, %q, want blank", exifData.FNumber, exifData.ExposureTime)
+	}
+
+	var buf bytes.Buffer
+	if err := FrameToWriter(&buf, bytes.NewReader(testJpeg(t, 60, 40)), config); err != nil {
+		t.Errorf("FrameToWriter: %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"runtime/debug"
+	"strings"
+)
+
+/*
+# 作成の印 (-mark) と確認 (check サブコマンド)
+
+-mark の場合は、go-exiframe で作成したことを示す印を出力に入れる。
+印は "exiframe\0" に続くJSON (バージョン、キャンバスと画像の位置、指定したオプション) で、
+JPEG はコメント (COM) セグメント、PNG は tEXt チャンク (キーワード "exiframe") に入れる。TIFF には入れない。
+
+check サブコマンドはメタデータだけを読んで印を探し、フレームを付けた画像かどうかを表示する。
+パイプラインで二重にフレームを付けないよう、印の無い画像が1つでもあれば終了コード1で終了する。
+*/
+
+const (
+	COM_MARKER   = 0xfe
+	MARK_KEYWORD = "exiframe"
+)
+
+// 出力に入れる印
+type Marker struct {
+	Version  string      `json:"version"`
+	Canvas   *LayoutRect `json:"canvas,omitempty"`
+	Image    *LayoutRect `json:"image,omitempty"`
+	Settings []string    `json:"settings,omitempty"`
+}
+
+// 実行中のgo-exiframeのバージョン (go install したもの以外は "(devel)")
+func toolVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// "exiframe\0" + JSON
+func markerPayload(config *Config) []byte {
+	marker := Marker{Version: toolVersion(), Settings: config.settings}
+	if config.layout != nil {
+		marker.Canvas = &config.layout.Canvas
+		marker.Image = &config.layout.Image
+	}
+
+	data, _ := json.Marshal(marker)
+	return append([]byte(MARK_KEYWORD+"\x00"), data...)
+}
+
+// JPEGのSOIの直後にCOMセグメントとして印を入れる
+func insertJpegMarker(jpegData []byte, config *Config) ([]byte, error) {
+	if len(jpegData) < 2 || jpegData[0] != 0xff || jpegData[1] != SOI_MARKER {
+		return nil, fmt.Errorf("invalid JPEG data")
+	}
+
+	payload := markerPayload(config)
+	length := len(payload) + 2
+	if length > 0xffff {
+		return nil, fmt.Errorf("marker too large")
+	}
+
+	var buf bytes.Buffer
+	buf.Write(jpegData[:2])
+	buf.Write([]byte{0xff, COM_MARKER, byte(length >> 8), byte(length)})
+	buf.Write(payload)
+	buf.Write(jpegData[2:])
+
+	return buf.Bytes(), nil
+}
+
+// PNGのIHDRの直後に tEXt チャンクとして印を入れる
+func insertPngMarker(pngData []byte, config *Config) ([]byte, error) {
+	if len(pngData) < PNG_IHDR_END {
+		return nil, fmt.Errorf("invalid PNG data")
+	}
+
+	chunk := append([]byte("tEXt"), markerPayload(config)...)
+
+	var buf bytes.Buffer
+	buf.Write(pngData[:PNG_IHDR_END])
+	binary.Write(&buf, binary.BigEndian, uint32(len(chunk)-4))
+	buf.Write(chunk)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	buf.Write(pngData[PNG_IHDR_END:])
+
+	return buf.Bytes(), nil
+}
+
+// 画像に入っている印を探す (無い場合はnil)
+func findMarker(data []byte) (*Marker, error) {
+	prefix := []byte(MARK_KEYWORD + "\x00")
+
+	var payload []byte
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, SOI_MARKER}):
+		segments, _, err := splitJpegSegments(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range segments {
+			if s.marker == COM_MARKER && bytes.HasPrefix(s.data[4:], prefix) {
+				payload = s.data[4+len(prefix):]
+				break
+			}
+		}
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		for pos := 8; pos+8 <= len(data); {
+			length := int(binary.BigEndian.Uint32(data[pos:]))
+			chunkType := string(data[pos+4 : pos+8])
+			if length < 0 || pos+12+length > len(data) || chunkType == "IDAT" {
+				break
+			}
+			chunk := data[pos+8 : pos+8+length]
+			if chunkType == "tEXt" && bytes.HasPrefix(chunk, prefix) {
+				payload = chunk[len(prefix):]
+				break
+			}
+			pos += 12 + length
+		}
+	}
+	if payload == nil {
+		return nil, nil
+	}
+
+	marker := &Marker{}
+	err := json.Unmarshal(payload, marker)
+	if err != nil {
+		return nil, fmt.Errorf("parsing marker: %w", err)
+	}
+	return marker, nil
+}
+
+// check サブコマンド: フレームを付けた画像かどうかを表示する
+func checkCommand(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		exitUsage("Please provide image files")
+	}
+
+	code := 0
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logError(fmt.Sprintf("Error %s: %v", path, err))
+			code = max(code, exitCode(ioError(err)))
+			continue
+		}
+
+		marker, err := findMarker(data)
+		if err != nil {
+			logError(fmt.Sprintf("Error %s: %v", path, err))
+			code = max(code, exitCode(decodeError(err)))
+			continue
+		}
+
+		if marker == nil {
+			fmt.Printf("%s: no\n", path)
+			code = max(code, EXIT_FAILURE)
+			continue
+		}
+
+		details := []string{"exiframe " + marker.Version}
+		if marker.Canvas != nil && marker.Image != nil {
+			details = append(details,
+				fmt.Sprintf("canvas %dx%d", marker.Canvas.Width, marker.Canvas.Height),
+				fmt.Sprintf("image %dx%d at %d,%d", marker.Image.Width, marker.Image.Height, marker.Image.X, marker.Image.Y))
+		}
+		if len(marker.Settings) > 0 {
+			details = append(details, "settings "+strings.Join(marker.Settings, " "))
+		}
+		fmt.Printf("%s: yes (%s)\n", path, strings.Join(details, ", "))
+	}
+
+	os.Exit(code)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+)
+
+/*
+# ラベルの行数の上限 (-max-label-lines)
+
+多くの項目を描画する設定でもラベル領域が広がり続けないよう、描画する行の数を制限する。
+上限を超える場合はレイアウト定義の priority が小さい行から省く (同じ場合は後に定義した行から省く)。
+残した行は元の位置に描画し、省いた行の名前はレイアウトの dropped_lines に入れる (-verbose の場合は表示する)。
+描画しない行 (optional で空の行など) は数えない。
+*/
+
+// 上限を超える行を priority の小さい順に省き、残す行と省いた行の名前を返す
+// drawn は行を描画する (数に入れる) かどうか
+func limitLines(lines []LineSpec, maxLines int, drawn func(LineSpec) bool) ([]LineSpec, []string) {
+	if maxLines <= 0 {
+		return lines, nil
+	}
+
+	var candidates []int
+	for i, line := range lines {
+		if drawn(line) {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) <= maxLines {
+		return lines, nil
+	}
+
+	// priority の大きい順 (同じ場合は定義の順) に上限まで残す
+	slices.SortStableFunc(candidates, func(a, b int) int {
+		return cmp.Compare(lines[b].Priority, lines[a].Priority)
+	})
+	dropped := map[int]bool{}
+	for _, i := range candidates[maxLines:] {
+		dropped[i] = true
+	}
+
+	var kept []LineSpec
+	var names []string
+	for i, line := range lines {
+		if dropped[i] {
+			names = append(names, line.Name)
+			continue
+		}
+		kept = append(kept, line)
+	}
+
+	return kept, names
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLimitLines(t *testing.T) {
+	lines := []LineSpec{
+		{Name: "a", Priority: 1},
+		{Name: "b", Priority: 3},
+		{Name: "c"},
+		{Name: "d", Priority: 3},
+		{Name: "e", Priority: 2},
+	}
+	drawnAll := func(LineSpec) bool { return true }
+
+	tests := []struct {
+		name        string
+		maxLines    int
+		drawn       func(LineSpec) bool
+		wantKept    []string
+		wantDropped []string
+	}{
+		{"no limit", 0, drawnAll, []string{"a", "b", "c", "d", "e"}, nil},
+		{"under limit", 5, drawnAll, []string{"a", "b", "c", "d", "e"}, nil},
+		{"two", 2, drawnAll, []string{"b", "d"}, []string{"a", "c", "e"}},
+		// 同じ priority の場合は後に定義した行から省く
+		{"one", 1, drawnAll, []string{"b"}, []string{"a", "c", "d", "e"}},
+		// 描画しない行は数えず、省かない
+		{"skip blank", 3, func(line LineSpec) bool { return line.Name != "b" }, []string{"a", "b", "d", "e"}, []string{"c"}},
+	}
+	for _, tt := range tests {
+		kept, dropped := limitLines(lines, tt.maxLines, tt.drawn)
+
+		var names []string
+		for _, line := range kept {
+			names = append(names, line.Name)
+		}
+		if !slices.Equal(names, tt.wantKept) || !slices.Equal(dropped, tt.wantDropped) {
+			t.Errorf("%s: limitLines = %v, %v, want %v, %v", tt.name, names, dropped, tt.wantKept, tt.wantDropped)
+		}
+	}
+}
+
+// 全ての項目を描画する設定でも、-max-label-lines 2 では優先度の高い2行だけを描画する
+func TestMaxLabelLinesAllFields(t *testing.T) {
+	exifData := testExifData()
+	exifData.UserComment = "Morning walk"
+	exifData.GPSDateTime = "2024/05/01 03:34"
+
+	for _, table := range []bool{false, true} {
+		config := testConfig(t)
+		config.table = table
+		fields, err := parseFields("camera,lens,exposure,date,comment,gps", DEFAULT_LAYOUT_SPEC, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.fields = fields
+		unlimited := testLayout(t, 4000, 3000, config, exifData)
+		if len(unlimited.Lines) < 5 {
+			t.Fatalf("table %v: %d lines without a limit, want all 5 fields", table, len(unlimited.Lines))
+		}
+
+		config.maxLabelLines = 2
+		layout := testLayout(t, 4000, 3000, config, exifData)
+
+		// -table の見出しの行 (camera_key など) は値の行と同じ項目として数えない
+		var names []string
+		for _, line := range layout.Lines {
+			if !strings.HasSuffix(line.Name, "_key") {
+				names = append(names, line.Name)
+			}
+		}
+		if want := []string{"camera", "exposure"}; !slices.Equal(names, want) {
+			t.Errorf("table %v: lines = %v, want %v", table, names, want)
+		}
+		if want := []string{"lens", "date", "comment"}; !slices.Equal(layout.DroppedLines, want) {
+			t.Errorf("table %v: dropped lines = %v, want %v", table, layout.DroppedLines, want)
+		}
+		if layout.Band.Height > unlimited.Band.Height {
+			t.Errorf("table %v: band height = %d, want at most %d", table, layout.Band.Height, unlimited.Band.Height)
+		}
+	}
+}
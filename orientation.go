@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"image"
+
+	"github.com/disintegration/imaging"
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+// imaging.AutoOrientation はJPEGのExifしか見ないため、WebP や TIFF などは Orientation に従ってここで回転する
+// 出力ではExifを削除する (-keep-exif の場合も Orientation は1にする) ため、画素を正位置にしないと横倒しのまま保存される
+func orientImage(img image.Image, data []byte) image.Image {
+	if bytes.HasPrefix(data, []byte{0xff, 0xd8}) {
+		return img
+	}
+
+	return applyOrientation(img, exifOrientation(data))
+}
+
+// Orientation に従って画像を正位置にする
+func applyOrientation(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	}
+	return img
+}
+
+// Exifの Orientation (読み込めない場合は0)
+func exifOrientation(data []byte) int {
+	rawExif, err := extractRawExif(data)
+	if err != nil {
+		return 0
+	}
+
+	im, err := exifcommon.NewIfdMappingWithStandard()
+	if err != nil {
+		return 0
+	}
+
+	_, index, err := exif.Collect(im, exif.NewTagIndex(), rawExif)
+	if err != nil {
+		return 0
+	}
+
+	return ifdOrientation(index.RootIfd)
+}
+
+// IFDの Orientation (無い場合は0)
+func ifdOrientation(ifd *exif.Ifd) int {
+	info := IFD_PATH_MAP["Orientation"]
+	results, err := ifd.FindTagWithId(info.tagId)
+	if err != nil || len(results) == 0 {
+		return 0
+	}
+
+	value, err := results[0].Value()
+	orientation, ok := value.([]uint16)
+	if err != nil || !ok || len(orientation) == 0 {
+		return 0
+	}
+	return int(orientation[0])
+}
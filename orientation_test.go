@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// Exifを eXIf チャンクに入れたPNG (imaging.AutoOrientation が見ない形式)
+func testPngWithExif(t testing.TB, width, height int, tags ...testTag) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(width, height, color.Gray{128})); err != nil {
+		t.Fatal(err)
+	}
+	return testPngChunk(buf.Bytes(), "eXIf", testExif(t, tags...))
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// 3x2 の画像の左上の画素がどこに移るか
+	tests := []struct {
+		orientation   int
+		width, height int
+		x, y          int
+	}{
+		{0, 3, 2, 0, 0},
+		{1, 3, 2, 0, 0},
+		{2, 3, 2, 2, 0},
+		{3, 3, 2, 2, 1},
+		{4, 3, 2, 0, 1},
+		{5, 2, 3, 0, 0},
+		{6, 2, 3, 1, 0},
+		{7, 2, 3, 1, 2},
+		{8, 2, 3, 0, 2},
+	}
+	for _, tt := range tests {
+		src := testImage(3, 2, color.Black)
+		src.Set(0, 0, color.White)
+
+		dst := applyOrientation(src, tt.orientation)
+		if b := dst.Bounds(); b.Dx() != tt.width || b.Dy() != tt.height {
+			t.Errorf("orientation %d: size = %dx%d, want %dx%d", tt.orientation, b.Dx(), b.Dy(), tt.width, tt.height)
+			continue
+		}
+		if r, _, _, _ := dst.At(dst.Bounds().Min.X+tt.x, dst.Bounds().Min.Y+tt.y).RGBA(); r != 0xffff {
+			t.Errorf("orientation %d: top-left pixel is not at (%d, %d)", tt.orientation, tt.x, tt.y)
+		}
+	}
+}
+
+// Exifを削除して保存しても (Orientation が無くても) 横倒しにならない
+func TestFrameRotatedSourceUpright(t *testing.T) {
+	tags := func(orientation uint16) []testTag {
+		return append(testCameraTags(), testTag{IFD_PATH, "Orientation", []uint16{orientation}})
+	}
+
+	// 正位置で記録した縦長の画像と同じ大きさになる
+	var want bytes.Buffer
+	if err := FrameToWriter(&want, bytes.NewReader(testJpeg(t, 40, 120, tags(1)...)), testConfig(t)); err != nil {
+		t.Fatalf("FrameToWriter: %v", err)
+	}
+	wantConfig, _, err := image.DecodeConfig(&want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		keepExif bool
+	}{
+		{"jpeg", testJpeg(t, 120, 40, tags(6)...), false},
+		{"jpeg keep-exif", testJpeg(t, 120, 40, tags(6)...), true},
+		{"png", testPngWithExif(t, 120, 40, tags(6)...), false},
+		{"png keep-exif", testPngWithExif(t, 120, 40, tags(6)...), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig(t)
+			config.keepExif = tt.keepExif
+
+			var buf bytes.Buffer
+			if err := FrameToWriter(&buf, bytes.NewReader(tt.data), config); err != nil {
+				t.Fatalf("FrameToWriter: %v", err)
+			}
+
+			got, _, err := image.DecodeConfig(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Width != wantConfig.Width || got.Height != wantConfig.Height {
+				t.Errorf("size = %dx%d, want %dx%d", got.Width, got.Height, wantConfig.Width, wantConfig.Height)
+			}
+
+			// 画素を回転したため、残したExifの Orientation は1
+			if orientation := exifOrientation(buf.Bytes()); orientation > 1 {
+				t.Errorf("Orientation = %d, want none or 1", orientation)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// 出力形式と拡張子
+var OUTPUT_FORMATS = map[string]string{
+	"jpeg": ".jpg",
+	"png":  ".png",
+	"tiff": ".tif",
+	"bmp":  ".bmp",
+}
+
+// 出力形式の別名
+var OUTPUT_FORMAT_ALIASES = map[string]string{
+	"jpg": "jpeg",
+	"tif": "tiff",
+}
+
+const DEFAULT_OUTPUT_FORMAT = "jpeg"
+
+// -format の値 (例: "jpeg,tiff") を出力形式の一覧にする
+func parseFormats(value string) ([]string, error) {
+	var formats []string
+	seen := map[string]bool{}
+	for _, f := range strings.Split(value, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if alias, ok := OUTPUT_FORMAT_ALIASES[f]; ok {
+			f = alias
+		}
+		if _, ok := OUTPUT_FORMATS[f]; !ok {
+			return nil, fmt.Errorf("unsupported format: %q", f)
+		}
+		if seen[f] {
+			continue
+		}
+		seen[f] = true
+		formats = append(formats, f)
+	}
+
+	return formats, nil
+}
+
+// 出力ファイル名 (exiframe-*)
+// JPEGの入力をJPEGで出力する場合は元の拡張子をそのまま使う
+func outputPath(fileName string, format string) string {
+	ext := filepath.Ext(fileName)
+	if format == DEFAULT_OUTPUT_FORMAT && (strings.EqualFold(ext, ".jpg") || strings.EqualFold(ext, ".jpeg")) {
+		return FILE_NAME_PREFIX + fileName
+	}
+
+	return FILE_NAME_PREFIX + strings.TrimSuffix(fileName, ext) + OUTPUT_FORMATS[format]
+}
+
+// 指定の形式でエンコードしてwに書き込む
+// Exifの埋め込みはJPEGのみ、ICCプロファイルの埋め込みはJPEGとPNGのみ対応 (BMPにはどちらも入れない)
+func encodeFormat(w io.Writer, dst image.Image, format string, config *Config) error {
+	switch format {
+	case "jpeg":
+		return encodeFrame(w, dst, config)
+	case "png":
+		var buf bytes.Buffer
+		err := png.Encode(&buf, dst)
+		if err != nil {
+			return fmt.Errorf("encoding PNG: %w", err)
+		}
+
+		data := buf.Bytes()
+		if config.mark {
+			data, err = insertPngMarker(data, config)
+			if err != nil {
+				return fmt.Errorf("inserting marker: %w", err)
+			}
+		}
+		if config.iccProfile != nil {
+			data, err = insertPngIcc(data, config.iccProfile)
+			if err != nil {
+				return fmt.Errorf("embedding ICC profile: %w", err)
+			}
+		}
+
+		_, err = w.Write(data)
+		if err != nil {
+			return fmt.Errorf("writing image: %w", err)
+		}
+	case "tiff":
+		if config.iccProfile != nil {
+			return fmt.Errorf("embedding ICC profile is not supported")
+		}
+
+		err := tiff.Encode(w, dst, &tiff.Options{Compression: tiff.Deflate})
+		if err != nil {
+			return fmt.Errorf("encoding TIFF: %w", err)
+		}
+	case "bmp":
+		if config.iccProfile != nil {
+			return fmt.Errorf("embedding ICC profile is not supported")
+		}
+
+		err := bmp.Encode(w, dst)
+		if err != nil {
+			return fmt.Errorf("encoding BMP: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported format: %q", format)
+	}
+
+	return nil
+}
+
+// フレームを付けた画像を config.formats の各形式で保存する
+// 一部の形式で失敗しても残りの形式は保存し、保存できたファイルと形式ごとのエラーを返す
+func writeOutputs(dst image.Image, config *Config) ([]string, error) {
+	formats := config.formats
+	if len(formats) == 0 {
+		formats = []string{DEFAULT_OUTPUT_FORMAT}
+	}
+
+	var outputs []string
+	var errs []error
+	for _, format := range formats {
+		var buf bytes.Buffer
+		err := encodeFormat(&buf, dst, format, config)
+		if err != nil {
+			errs = append(errs, ioError(fmt.Errorf("%s: %w", format, err)))
+			continue
+		}
+
+		// -in-place の場合は元のファイルを置き換える
+		if config.inPlace {
+			err = replaceFile(config.filePath, format, buf.Bytes(), config.backup)
+			if err != nil {
+				errs = append(errs, ioError(fmt.Errorf("%s: %w", format, err)))
+				continue
+			}
+			outputs = append(outputs, config.filePath)
+			continue
+		}
+
+		dstPath := outputPath(config.fileName, format)
+		err = os.WriteFile(dstPath, buf.Bytes(), 0666)
+		if err != nil {
+			errs = append(errs, ioError(fmt.Errorf("%s: creating file: %w", format, err)))
+			continue
+		}
+
+		outputs = append(outputs, dstPath)
+	}
+
+	// -sizes の場合は縮小した画像も保存する (フレームの作成は元の大きさで1回だけ行う)
+	if len(config.sizes) > 0 {
+		sized, err := writeSizedOutputs(dst, formats, config)
+		outputs = append(outputs, sized...)
+		errs = append(errs, err)
+	}
+
+	return outputs, errors.Join(errs...)
+}
+
+// 出力先が入力ファイルのいずれかと同じ場合はエラーを返す
+// 例えば a.jpg と exiframe-a.jpg をまとめて処理すると、a.jpg の出力が読み込み中の exiframe-a.jpg を上書きしてしまう
+func checkOutputPaths(paths []string, config *Config) error {
+	inputs := map[string]string{}
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		inputs[abs] = path
+	}
+
+	formats := config.formats
+	if len(formats) == 0 {
+		formats = []string{DEFAULT_OUTPUT_FORMAT}
+	}
+
+	for _, path := range paths {
+		fileName := filepath.Base(path)
+
+		var outputs []string
+		for _, format := range formats {
+			outputs = append(outputs, outputPath(fileName, format))
+		}
+		for _, width := range config.sizes {
+			for _, format := range formats {
+				outputs = append(outputs, sizedOutputPath(fileName, format, width))
+			}
+		}
+		if config.dumpJson {
+			outputs = append(outputs, outputs[0]+".json")
+		}
+		if config.exifSidecar {
+			outputs = append(outputs, outputs[0]+EXIF_SIDECAR_SUFFIX)
+		}
+		if config.reveal {
+			outputs = append(outputs, revealPath(fileName))
+		}
+		if config.extractThumb {
+			outputs = append(outputs, thumbPath(fileName))
+		}
+
+		for _, output := range outputs {
+			abs, err := filepath.Abs(output)
+			if err != nil {
+				return err
+			}
+			if input, ok := inputs[abs]; ok {
+				return fmt.Errorf("output of %s would overwrite input file %s", path, input)
+			}
+		}
+	}
+
+	return nil
+}
+
+// 元のファイルを置き換える (-in-place)
+// 同じディレクトリの一時ファイルに書き込んでから os.Rename で置き換えるため、途中で失敗しても元のファイルは残る
+// backup の場合は置き換える前に元のファイルを <path>.bak に複製する
+func replaceFile(path string, format string, data []byte, backup bool) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".jpeg" {
+		ext = ".jpg"
+	}
+	if ext == ".tiff" {
+		ext = ".tif"
+	}
+	if ext != OUTPUT_FORMATS[format] {
+		return fmt.Errorf("cannot replace %s with %s", filepath.Base(path), format)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".exiframe-*")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("writing temp file: %w", err)
+	}
+
+	err = os.Chmod(tmp.Name(), info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if backup {
+		original, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(path+".bak", original, info.Mode().Perm())
+		if err != nil {
+			return fmt.Errorf("writing backup: %w", err)
+		}
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// 出力先が入力ファイルと同じになる組み合わせはエラーにする
+func TestCheckOutputPaths(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	tests := []struct {
+		name    string
+		paths   []string
+		config  Config
+		wantErr bool
+	}{
+		{"distinct", []string{"a.jpg", "b.jpg"}, Config{}, false},
+		{"framed input", []string{"a.jpg", "exiframe-a.jpg"}, Config{}, true},
+		{"input in other directory", []string{filepath.Join("photos", "a.jpg"), "exiframe-a.jpg"}, Config{}, true},
+		{"framed input elsewhere", []string{"a.jpg", filepath.Join("photos", "exiframe-a.jpg")}, Config{}, false},
+		{"png output", []string{"a.jpg", "exiframe-a.png"}, Config{formats: []string{"png"}}, true},
+		{"png output not written", []string{"a.jpg", "exiframe-a.png"}, Config{}, false},
+		{"json sidecar", []string{"a.jpg", "exiframe-a.jpg.json"}, Config{dumpJson: true}, true},
+		{"sized output", []string{"a.jpg", "exiframe-a-400.jpg"}, Config{sizes: []int{400}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOutputPaths(tt.paths, &tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkOutputPaths(%q) error = %v, wantErr %v", tt.paths, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseFormats(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{"jpeg", []string{"jpeg"}, false},
+		{"JPG, tif,png", []string{"jpeg", "tiff", "png"}, false},
+		{"jpeg,jpg", []string{"jpeg"}, false},
+		{"gif", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseFormats(tt.value)
+		if (err != nil) != tt.wantErr || !slices.Equal(got, tt.want) {
+			t.Errorf("parseFormats(%q) = %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+/*
+# 出力する画像の大きさ (OutputSize)
+
+ライブラリとして使う場合に、描画せずにフレームを付けた画像の幅と高さを求める。
+グリッドなどの配置を先に決めるためのもので、FrameToWriter と同じレイアウトの計算 (枠、ラベル領域、フッター) を行う。
+
+- srcWidth, srcHeight は向きを補正した後の画像の大きさ
+- -aspect の切り抜きは反映する。-trim は画像の内容で変わるため反映しない
+- ラベル領域の高さや -vertical-text の幅はExif情報で変わるため、描画する画像のExif情報を渡す (nil の場合はExifが無い画像として扱う)
+- PostProcess で大きさを変える場合はその結果を反映しない
+*/
+
+// フレームを付けた画像の幅と高さを求める
+func OutputSize(srcWidth, srcHeight int, exifData *ExifData, config *Config) (width, height int, err error) {
+	if config.aspect > 0 {
+		srcWidth, srcHeight, err = aspectSize(srcWidth, srcHeight, config.aspect)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if exifData == nil {
+		exifData = &ExifData{}
+		if config.manual != nil {
+			applyManualExif(exifData, config.manual)
+		}
+	}
+
+	faces, err := configFaces(config)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	layout := computeLayout(srcWidth, srcHeight, config, exifData, faces)
+	addFooter(layout, config, faces)
+
+	return layout.Canvas.Width, layout.Canvas.Height, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+// OutputSize は実際に描画した画像と同じ大きさになる
+func TestOutputSizeMatchesRender(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		configure     func(*Config)
+	}{
+		{"default", 300, 200, func(*Config) {}},
+		{"portrait", 200, 300, func(*Config) {}},
+		{"no-frame", 300, 200, func(c *Config) { c.noFrame = true }},
+		{"band-only", 300, 200, func(c *Config) { c.bandOnly = true }},
+		{"frame-inset", 300, 200, func(c *Config) { c.frameInset = 24 }},
+		{"compact", 300, 200, func(c *Config) { c.compact = true }},
+		{"table", 300, 200, func(c *Config) { c.table = true }},
+		{"columns", 300, 200, func(c *Config) { c.columns = 3 }},
+		{"max-label-lines", 300, 200, func(c *Config) { c.maxLabelLines = 1 }},
+		{"aspect", 300, 200, func(c *Config) { c.aspect = 1 }},
+		{"aspect portrait", 200, 300, func(c *Config) { c.aspect = 16.0 / 9 }},
+		{"vertical-text", 200, 300, func(c *Config) { c.verticalText = "cw" }},
+		{"footer-text", 300, 200, func(c *Config) { c.footerText = "example.com" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testJpeg(t, tt.width, tt.height, testCameraTags()...)
+
+			config := testConfig(t)
+			tt.configure(config)
+			exifData, err := getExif(data, config)
+			if err != nil {
+				t.Fatalf("getExif: %v", err)
+			}
+			width, height, err := OutputSize(tt.width, tt.height, exifData, config)
+			if err != nil {
+				t.Fatalf("OutputSize: %v", err)
+			}
+
+			// 描画は設定を書き換えるため、別の設定で行う
+			config = testConfig(t)
+			tt.configure(config)
+			var buf bytes.Buffer
+			if err := FrameToWriter(&buf, bytes.NewReader(data), config); err != nil {
+				t.Fatalf("FrameToWriter: %v", err)
+			}
+			got, _, err := image.DecodeConfig(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if width != got.Width || height != got.Height {
+				t.Errorf("OutputSize = %dx%d, want %dx%d", width, height, got.Width, got.Height)
+			}
+		})
+	}
+}
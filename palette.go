@@ -0,0 +1,76 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math/rand/v2"
+)
+
+/*
+# 写真の色からフレームの色を選ぶ (-frame-color palette)
+
+画像からランダムに画素を選び、色を RGB 各3ビットの箱に分けて数える。
+画素数が多く鮮やかな箱ほど高い点とし、最も点の高い箱の平均の色をフレームの色にする。
+文字色はフレームの明るさに応じて黒か白にする。
+
+画素の選び方は -seed で決まるため、同じ画像とシードからは常に同じ色になる。
+-seed を指定しない場合も時刻などではなく固定の DEFAULT_SEED を使い、実行ごとに色が変わらないようにする。
+*/
+
+// -frame-color の値
+const FRAME_COLOR_PALETTE = "palette"
+
+// -seed を指定しない場合のシード
+const DEFAULT_SEED = 1
+
+const (
+	PALETTE_SAMPLES = 4096 // 調べる画素の数
+	PALETTE_BITS    = 3    // 色を分ける箱の細かさ (各チャンネルのビット数)
+	PALETTE_GRAY    = 0.2  // 鮮やかさが0の色の重み (灰色の写真でも色が選ばれるようにする)
+)
+
+// 写真の代表的な色
+func paletteColor(img image.Image, seed uint64) color.NRGBA {
+	b := img.Bounds()
+	if b.Empty() {
+		return color.NRGBA{0xff, 0xff, 0xff, 0xff}
+	}
+
+	type bucket struct {
+		n       int
+		r, g, b int
+	}
+	var buckets [1 << (3 * PALETTE_BITS)]bucket
+
+	rng := rand.New(rand.NewPCG(seed, seed))
+	shift := 8 - PALETTE_BITS
+	for range PALETTE_SAMPLES {
+		x := b.Min.X + rng.IntN(b.Dx())
+		y := b.Min.Y + rng.IntN(b.Dy())
+		c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+
+		key := int(c.R>>shift)<<(2*PALETTE_BITS) | int(c.G>>shift)<<PALETTE_BITS | int(c.B>>shift)
+		buckets[key].n++
+		buckets[key].r += int(c.R)
+		buckets[key].g += int(c.G)
+		buckets[key].b += int(c.B)
+	}
+
+	var best color.NRGBA
+	bestScore := -1.0
+	for _, bucket := range buckets {
+		if bucket.n == 0 {
+			continue
+		}
+
+		c := color.NRGBA{uint8(bucket.r / bucket.n), uint8(bucket.g / bucket.n), uint8(bucket.b / bucket.n), 0xff}
+		// 鮮やかさ (最大と最小のチャンネルの差)
+		chroma := float64(max(c.R, c.G, c.B)-min(c.R, c.G, c.B)) / 0xff
+		score := float64(bucket.n) * (PALETTE_GRAY + chroma)
+		if score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+
+	return best
+}
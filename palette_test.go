@@ -0,0 +1,81 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math/rand/v2"
+	"net/url"
+	"testing"
+)
+
+// 色がまだらな画像 (シードによって選ばれる画素が変わる)
+func testNoiseImage(width, height int) *image.RGBA {
+	rng := rand.New(rand.NewPCG(7, 7))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rng.IntN(256))
+		if i%4 == 3 {
+			img.Pix[i] = 0xff
+		}
+	}
+	return img
+}
+
+// 同じシードからは常に同じ色になる
+func TestPaletteColorSeed(t *testing.T) {
+	img := testNoiseImage(200, 150)
+
+	for _, seed := range []uint64{DEFAULT_SEED, 2, 12345} {
+		want := paletteColor(img, seed)
+		for range 3 {
+			if got := paletteColor(img, seed); got != want {
+				t.Errorf("seed %d: paletteColor = %v, then %v", seed, want, got)
+			}
+		}
+	}
+
+	// 異なるシードでは選ぶ画素が変わる
+	colors := map[color.NRGBA]bool{}
+	for seed := range uint64(20) {
+		colors[paletteColor(img, seed)] = true
+	}
+	if len(colors) < 2 {
+		t.Errorf("20 seeds gave %d palette colors, want the seed to change the sampling", len(colors))
+	}
+}
+
+func TestPaletteColor(t *testing.T) {
+	tests := []struct {
+		name string
+		img  image.Image
+		want color.NRGBA
+	}{
+		{"uniform", testImage(50, 50, color.RGBA{0x20, 0x80, 0xc0, 0xff}), color.NRGBA{0x20, 0x80, 0xc0, 0xff}},
+		{"empty", image.NewRGBA(image.Rect(0, 0, 0, 0)), color.NRGBA{0xff, 0xff, 0xff, 0xff}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := paletteColor(tt.img, DEFAULT_SEED); got != tt.want {
+				t.Errorf("paletteColor = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigSeed(t *testing.T) {
+	if config := testConfig(t); config.seed != DEFAULT_SEED {
+		t.Errorf("default seed = %d, want %d", config.seed, DEFAULT_SEED)
+	}
+
+	config, err := configFromQuery(url.Values{"seed": {"42"}})
+	if err != nil {
+		t.Fatalf("configFromQuery(seed=42): %v", err)
+	}
+	if config.seed != 42 {
+		t.Errorf("seed = %d, want 42", config.seed)
+	}
+	if _, err := configFromQuery(url.Values{"seed": {"-1"}}); err == nil {
+		t.Error("configFromQuery(seed=-1) succeeded, want error")
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+/*
+# ポイントで指定する文字の大きさ (-font-size-pt, -dpi)
+
+印刷したときの文字の大きさを決められるよう、ラベルの文字の大きさをポイントで指定する。
+-font-size-pt は通常の文字 (レイアウト定義の size が FONT_SIZE の行) の大きさで、他の行は同じ比率で大きさを変える。
+フォントは truetype.Options の Size (ポイント) と DPI で描画し、ピクセルでは pt * dpi / 72 の大きさになる。
+レイアウト定義の y とラベル領域の高さも同じ比率で変え、行の間隔を保つ。
+
+-font-size-pt を指定しない場合はこれまでどおりレイアウト定義の size をピクセルとして使う。
+*/
+
+const (
+	POINTS_PER_INCH = 72
+	DEFAULT_DPI     = 300
+)
+
+// ポイントをピクセルにする
+func pointsToPixels(pt, dpi float64) float64 {
+	return pt * dpi / POINTS_PER_INCH
+}
+
+// レイアウト定義のピクセルの大きさに掛ける比率 (-font-size-pt が無い場合は1)
+func textScale(config *Config) float64 {
+	if config.fontSizePt <= 0 {
+		return 1
+	}
+	return pointsToPixels(config.fontSizePt, config.dpi) / FONT_SIZE
+}
+
+// y とラベル領域の高さを k 倍にしたレイアウト定義 (文字の大きさは Faces で変える)
+func scaleLayoutSpec(spec *LayoutSpec, k float64) *LayoutSpec {
+	bandHeight := spec.BandHeight
+	if bandHeight == 0 {
+		bandHeight = EXIF_LABEL_HEIGHT
+	}
+
+	scaled := &LayoutSpec{
+		BandHeight: int(float64(bandHeight) * k),
+		Lines:      make([]LineSpec, len(spec.Lines)),
+	}
+	for i, line := range spec.Lines {
+		line.Y = int(float64(line.Y) * k)
+		scaled.Lines[i] = line
+	}
+	return scaled
+}
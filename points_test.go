@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"golang.org/x/image/font"
+)
+
+func TestPointsToPixels(t *testing.T) {
+	tests := []struct {
+		pt, dpi float64
+		want    float64
+	}{
+		{12, 72, 12},
+		{12, 96, 16},
+		{12, 300, 50},
+		{10, 350, 48.611},
+		{36, 600, 300},
+	}
+	for _, tt := range tests {
+		if got := pointsToPixels(tt.pt, tt.dpi); math.Abs(got-tt.want) > 0.001 {
+			t.Errorf("pointsToPixels(%g, %g) = %g, want %g", tt.pt, tt.dpi, got, tt.want)
+		}
+	}
+}
+
+func TestTextScale(t *testing.T) {
+	tests := []struct {
+		fontSizePt, dpi float64
+		want            float64
+	}{
+		{0, DEFAULT_DPI, 1},
+		{36, 300, 1},
+		{12, 300, 50.0 / FONT_SIZE},
+		{18, 600, 150.0 / FONT_SIZE},
+	}
+	for _, tt := range tests {
+		config := &Config{fontSizePt: tt.fontSizePt, dpi: tt.dpi}
+		if got := textScale(config); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("textScale(%g pt, %g dpi) = %g, want %g", tt.fontSizePt, tt.dpi, got, tt.want)
+		}
+	}
+
+	spec := scaleLayoutSpec(DEFAULT_LAYOUT_SPEC, 0.5)
+	if spec.BandHeight != EXIF_LABEL_HEIGHT/2 || spec.Lines[1].Y != DEFAULT_LAYOUT_SPEC.Lines[1].Y/2 {
+		t.Errorf("scaled band height, lens y = %d, %d", spec.BandHeight, spec.Lines[1].Y)
+	}
+}
+
+// ポイントとDPIで描画した文字は pt * dpi / 72 ピクセルの文字と同じ大きさになる
+func TestFacePoints(t *testing.T) {
+	pixelFaces, err := loadFaces(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dpi := range []float64{72, 96, 300, 600} {
+		faces, err := loadFaces(&Config{fontSizePt: 12, dpi: dpi})
+		if err != nil {
+			t.Fatal(err)
+		}
+		px := pointsToPixels(12, dpi)
+
+		// 通常の文字 (FONT_SIZE) が -font-size-pt になり、大きい文字は同じ比率で大きくなる
+		for _, size := range []float64{FONT_SIZE, LARGE_FONT_SIZE} {
+			got := font.MeasureString(faces.face("bold", size), "ILCE-7M4 1/250s").Ceil()
+			want := font.MeasureString(pixelFaces.face("bold", px*size/FONT_SIZE), "ILCE-7M4 1/250s").Ceil()
+			if diff := got - want; diff < -1 || diff > 1 {
+				t.Errorf("%g dpi, size %g: width = %d, want %d", dpi, size, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/image/font"
+)
+
+/*
+# 縦長の画像のラベル (-portrait-text)
+
+縦長の画像ではラベルを画像の右側に置き、文字の向きを選べるようにする。
+
+- vertical: 文字を回転して縦に読む (-vertical-text と同じ描画、向きは -vertical-text で指定し、省略した場合は ccw)
+- horizontal: 文字は横書きのまま、画像の幅の PORTRAIT_BAND_RATIO 倍の幅のラベル領域に上から順に並べる。
+  ラベル領域の幅に収まらない行は空白で折り返し、折り返せない長い語は末尾を "…" で省略する。
+  小さな画像で最も長い語も収まらない場合は、全ての行の文字を同じ比率で縮め、左右の余白も狭くする。
+  省略して何も残らない行は描画せず、キャンバスも伸ばさない
+
+横長の画像は通常どおり下にラベルを置く。
+*/
+
+const (
+	PORTRAIT_TEXT_HORIZONTAL = "horizontal"
+	PORTRAIT_TEXT_VERTICAL   = "vertical"
+
+	PORTRAIT_BAND_RATIO     = 0.4 // -portrait-text horizontal のラベル領域の幅 (画像の幅に対する比)
+	PORTRAIT_MARGIN_DIVISOR = 10  // ラベル領域の左右の余白の上限 (ラベル領域の幅に対する比の逆数)
+)
+
+// ラベルを右側に横書きで並べるレイアウトを計算する
+func computePortraitLayout(srcWidth, srcHeight int, config *Config, exifData *ExifData, faces *Faces) *Layout {
+	// 枠と画像の位置、各行の文字列と書体は通常のレイアウトと同じ
+	// 焦点距離の目盛りは左右の行の間に置くため、右側に並べる場合は描画しない
+	c := *config
+	c.portraitText = ""
+	c.focalBar = false
+	v := computeLayout(srcWidth, srcHeight, &c, exifData, faces)
+
+	framePixel := v.Image.X - config.frameInset
+	imageWidth := srcWidth + config.frameInset*2
+	imageHeight := srcHeight + config.frameInset*2
+	bandWidth := int(float64(imageWidth) * PORTRAIT_BAND_RATIO)
+
+	// ラベル領域の左右の余白 (幅の狭いラベル領域では FRAME_PIXEL より狭くする)
+	margin := min(FRAME_PIXEL, bandWidth/PORTRAIT_MARGIN_DIVISOR)
+	maxWidth := max(bandWidth-margin*2, 1)
+
+	// 最も長い語が幅に収まるよう、全ての行の文字を同じ比率で縮める (語の間で折り返せば省略せずに済む)
+	if k := portraitTextScale(v, maxWidth); k < 1 {
+		spec := config.layoutSpec
+		if spec == nil {
+			spec = DEFAULT_LAYOUT_SPEC
+		}
+		c.layoutSpec = scaleLineSizes(spec, k)
+		v = computeLayout(srcWidth, srcHeight, &c, exifData, faces)
+	}
+
+	layout := &Layout{
+		Canvas:       LayoutRect{0, 0, framePixel + imageWidth + bandWidth, framePixel*2 + imageHeight},
+		Image:        v.Image,
+		Band:         LayoutRect{framePixel + imageWidth, 0, bandWidth, framePixel*2 + imageHeight},
+		Inset:        v.Inset,
+		DroppedLines: v.DroppedLines,
+	}
+
+	left := layout.Band.X + margin
+	y := v.Image.Y
+
+	for _, line := range v.Lines {
+		metrics := line.face.Metrics()
+
+		// 絞りのアイコンの分だけ撮影データの行を右にずらす
+		indent := 0
+		if v.ApertureIcon != nil && line.Name == "exposure" {
+			indent = metrics.Ascent.Ceil() * 3 / 2
+		}
+
+		drawn := 0
+		for _, text := range wrapText(line.face, line.Text, maxWidth-indent) {
+			// 省略して何も残らない行は描画せず、キャンバスも伸ばさない
+			text, width := truncateText(line.face, text, maxWidth-indent)
+			if text == "" || text == "…" {
+				continue
+			}
+
+			// 折り返した行の間は書体の高さの1/4、元の行の間は1/2だけ空ける (1行目の上端は画像の上端に揃える)
+			if len(layout.Lines) > 0 && drawn > 0 {
+				y += metrics.Height.Ceil() / 4
+			} else if len(layout.Lines) > 0 {
+				y += metrics.Height.Ceil() / 2
+			}
+			y += metrics.Ascent.Ceil()
+
+			wrapped := line
+			wrapped.Text, wrapped.Width = text, width
+			wrapped.X = left + indent
+			wrapped.Y = y
+			layout.Lines = append(layout.Lines, wrapped)
+
+			if indent > 0 && drawn == 0 {
+				size := metrics.Ascent.Ceil()
+				layout.ApertureIcon = &LayoutRect{left, y - size, size, size}
+				layout.fNumber = v.fNumber
+			}
+			y += metrics.Descent.Ceil()
+			drawn++
+		}
+	}
+
+	// 行が画像より下に続く場合はキャンバスを伸ばす
+	if bottom := y + margin; bottom > layout.Canvas.Height {
+		layout.Canvas.Height = bottom
+		layout.Band.Height = bottom
+	}
+
+	return layout
+}
+
+// 各行の最も長い語 (撮影データの行は絞りのアイコンの分を含む) が maxWidth に収まる文字の大きさの比率 (1より大きくはしない)
+func portraitTextScale(v *Layout, maxWidth int) float64 {
+	k := 1.0
+	for _, line := range v.Lines {
+		indent := 0
+		if v.ApertureIcon != nil && line.Name == "exposure" {
+			indent = line.face.Metrics().Ascent.Ceil() * 3 / 2
+		}
+		for _, word := range strings.Fields(line.Text) {
+			if width := indent + font.MeasureString(line.face, word).Ceil(); width > maxWidth {
+				k = min(k, float64(maxWidth)/float64(width))
+			}
+		}
+	}
+	return k
+}
+
+// 文字の大きさを k 倍にしたレイアウト定義 (行の位置は computePortraitLayout で決める)
+func scaleLineSizes(spec *LayoutSpec, k float64) *LayoutSpec {
+	scaled := *spec
+	scaled.Lines = make([]LineSpec, len(spec.Lines))
+	for i, line := range spec.Lines {
+		line.Size *= k
+		scaled.Lines[i] = line
+	}
+	return &scaled
+}
+
+// maxWidth に収まるよう空白で折り返す (語と語の間の空白はそのまま残す)
+func wrapText(face font.Face, text string, maxWidth int) []string {
+	var lines []string
+	current := ""
+	for text != "" {
+		// 次の語とその前の空白
+		trimmed := strings.TrimLeft(text, " ")
+		space := text[:len(text)-len(trimmed)]
+		word, rest, found := strings.Cut(trimmed, " ")
+		if word == "" {
+			break
+		}
+		if found {
+			rest = " " + rest
+		}
+		text = rest
+
+		switch {
+		case current == "":
+			current = word
+		case font.MeasureString(face, current+space+word).Ceil() > maxWidth:
+			lines = append(lines, current)
+			current = word
+		default:
+			current += space + word
+		}
+	}
+
+	return append(lines, current)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font"
+)
+
+func TestWrapText(t *testing.T) {
+	faces, err := loadFaces(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	face := faces.face("regular", 100)
+	// 等幅のフォントのため、文字数で幅が決まる
+	char := font.MeasureString(face, "a").Ceil()
+
+	tests := []struct {
+		text     string
+		maxChars int
+		want     []string
+	}{
+		{"FE 50mm F1.4 GM", 20, []string{"FE 50mm F1.4 GM"}},
+		{"FE 50mm F1.4 GM", 10, []string{"FE 50mm", "F1.4 GM"}},
+		{"FE 50mm F1.4 GM", 4, []string{"FE", "50mm", "F1.4", "GM"}},
+		// 語と語の間の空白はそのまま残す
+		{"50mm  f/2.8  1/250s", 12, []string{"50mm  f/2.8", "1/250s"}},
+		// 折り返せない長い語はそのまま (truncateText で省略する)
+		{"ILCE-7M4", 4, []string{"ILCE-7M4"}},
+	}
+	for _, tt := range tests {
+		if got := wrapText(face, tt.text, tt.maxChars*char); !slices.Equal(got, tt.want) {
+			t.Errorf("wrapText(%q, %d chars) = %q, want %q", tt.text, tt.maxChars, got, tt.want)
+		}
+	}
+}
+
+// 縦長の画像は横書きでも縦書きでもラベルを右側に置く
+func TestPortraitText(t *testing.T) {
+	exifData := testExifData()
+	exifData.LensModel = "FE 24-70mm F2.8 GM II with a long lens name"
+
+	tests := []struct {
+		name          string
+		width, height int
+		portraitText  string
+		verticalText  string // main で -portrait-text vertical から決まる値
+		rotate        string
+	}{
+		{"horizontal", 2000, 6000, PORTRAIT_TEXT_HORIZONTAL, "", ""},
+		{"vertical", 2000, 6000, PORTRAIT_TEXT_VERTICAL, VERTICAL_TEXT_CCW, VERTICAL_TEXT_CCW},
+		// ラベル領域が FRAME_PIXEL*2 より狭い小さな画像
+		{"horizontal small", 800, 1200, PORTRAIT_TEXT_HORIZONTAL, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig(t)
+			config.portraitText = tt.portraitText
+			config.verticalText = tt.verticalText
+
+			layout := testLayout(t, tt.width, tt.height, config, exifData)
+			band := layout.Band
+			if band.X < layout.Image.X+layout.Image.Width || band.X+band.Width > layout.Canvas.Width {
+				t.Fatalf("band %+v is not on the right of image %+v", band, layout.Image)
+			}
+
+			bottom := 0
+			for _, line := range layout.Lines {
+				if line.Rotate != tt.rotate {
+					t.Errorf("line %q rotate = %q, want %q", line.Text, line.Rotate, tt.rotate)
+				}
+				if tt.rotate != "" {
+					continue
+				}
+
+				// 横書きの行は省略せずラベル領域の幅に収め、上から順に重ならないよう並べる
+				if strings.HasSuffix(line.Text, "…") {
+					t.Errorf("line %q is truncated", line.Text)
+				}
+				if line.X <= band.X || line.X+line.Width >= band.X+band.Width {
+					t.Errorf("line %q at x %d..%d is outside band %d..%d", line.Text, line.X, line.X+line.Width, band.X, band.X+band.Width)
+				}
+				if top := line.Y - line.face.Metrics().Ascent.Ceil(); top < bottom {
+					t.Errorf("line %q top %d overlaps the line above ending at %d", line.Text, top, bottom)
+				}
+				bottom = line.Y + line.face.Metrics().Descent.Ceil()
+			}
+			if tt.rotate == "" && len(layout.Lines) <= 4 {
+				t.Errorf("%d lines, want the long lens name wrapped", len(layout.Lines))
+			}
+			// 行が画像の高さに収まる場合はキャンバスを伸ばさない
+			if tt.rotate == "" && bottom < layout.Image.Y+layout.Image.Height && layout.Canvas.Height != layout.Image.Y*2+layout.Image.Height {
+				t.Errorf("canvas height = %d, want %d", layout.Canvas.Height, layout.Image.Y*2+layout.Image.Height)
+			}
+
+			// 描画した画像もレイアウトの大きさになる
+			config = testConfig(t)
+			config.portraitText = tt.portraitText
+			config.verticalText = tt.verticalText
+			var buf bytes.Buffer
+			if err := FrameToWriter(&buf, bytes.NewReader(testJpeg(t, tt.width, tt.height, testCameraTags()...)), config); err != nil {
+				t.Fatalf("FrameToWriter: %v", err)
+			}
+			got, _, err := image.DecodeConfig(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Width < tt.width+band.Width {
+				t.Errorf("output width = %d, want the image and a band on the right", got.Width)
+			}
+		})
+	}
+}
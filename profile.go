@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// -cpuprofile / -memprofile のプロファイルを書き込んで終了処理を行う (プロファイルを取らない場合は何もしない)
+var stopProfiles = func() {}
+
+// プロファイルを書き込んでから終了する
+// main では os.Exit の代わりにこれを使う
+func exit(code int) {
+	stopProfiles()
+	os.Exit(code)
+}
+
+// CPUプロファイルを開始し、終了時にCPUプロファイルとメモリプロファイルを書き込むよう stopProfiles を設定する
+// 結果は go tool pprof で確認する
+func startProfiles(cpuPath, memPath string) error {
+	var cpuFile *os.File
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+		if err != nil {
+			return fmt.Errorf("creating CPU profile: %w", err)
+		}
+
+		err = pprof.StartCPUProfile(f)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("starting CPU profile: %w", err)
+		}
+		cpuFile = f
+	}
+
+	stopProfiles = func() {
+		// 2回目以降は何もしない
+		stopProfiles = func() {}
+
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			cpuFile.Close()
+		}
+
+		if memPath != "" {
+			err := writeMemProfile(memPath)
+			if err != nil {
+				logError("Error", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func writeMemProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating memory profile: %w", err)
+	}
+	defer f.Close()
+
+	// 確保済みのメモリの統計を最新にする
+	runtime.GC()
+
+	err = pprof.Lookup("allocs").WriteTo(f, 0)
+	if err != nil {
+		return fmt.Errorf("writing memory profile: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bufio"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+)
+
+/*
+# プログレッシブJPEG (-progressive)
+
+標準ライブラリの image/jpeg はベースラインのみのため、プログレッシブJPEGは独自に書き出す。
+
+- 色差の間引きはしない (4:4:4)
+- 量子化テーブルは image/jpeg と同じ計算 (品質100では全て1)
+- スキャンは DC (全成分) の後、成分ごとに AC 1-5, AC 6-63 (スペクトル選択のみ、逐次近似は使わない)
+- ハフマンテーブルは JPEG 規格 (ITU-T T.81 Annex K) の標準テーブル
+
+色差を間引かないため、ファイルサイズはベースラインより大きくなる。
+*/
+
+// ジグザグ順の位置から8x8ブロック内の位置への対応
+var JPEG_UNZIG = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// 量子化テーブル (ジグザグ順、輝度と色差)
+var JPEG_UNSCALED_QUANT = [2][64]byte{
+	{
+		16, 11, 12, 14, 12, 10, 16, 14,
+		13, 14, 18, 17, 16, 19, 24, 40,
+		26, 24, 22, 22, 24, 49, 35, 37,
+		29, 40, 58, 51, 61, 60, 57, 51,
+		56, 55, 64, 72, 92, 78, 64, 68,
+		87, 69, 55, 56, 80, 109, 81, 87,
+		95, 98, 103, 104, 103, 62, 77, 113,
+		121, 112, 100, 120, 92, 101, 103, 99,
+	},
+	{
+		17, 18, 18, 24, 21, 24, 47, 26,
+		26, 47, 99, 66, 56, 66, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+	},
+}
+
+// ハフマンテーブルの定義 (符号長ごとの個数と値)
+type huffmanSpec struct {
+	count [16]byte
+	value []byte
+}
+
+// DC輝度、AC輝度、DC色差、AC色差
+var JPEG_HUFFMAN_SPECS = [4]huffmanSpec{
+	{
+		[16]byte{0, 1, 5, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	{
+		[16]byte{0, 2, 1, 3, 3, 2, 4, 3, 5, 5, 4, 4, 0, 0, 1, 125},
+		[]byte{
+			0x01, 0x02, 0x03, 0x00, 0x04, 0x11, 0x05, 0x12,
+			0x21, 0x31, 0x41, 0x06, 0x13, 0x51, 0x61, 0x07,
+			0x22, 0x71, 0x14, 0x32, 0x81, 0x91, 0xa1, 0x08,
+			0x23, 0x42, 0xb1, 0xc1, 0x15, 0x52, 0xd1, 0xf0,
+			0x24, 0x33, 0x62, 0x72, 0x82, 0x09, 0x0a, 0x16,
+			0x17, 0x18, 0x19, 0x1a, 0x25, 0x26, 0x27, 0x28,
+			0x29, 0x2a, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39,
+			0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49,
+			0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59,
+			0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69,
+			0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79,
+			0x7a, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89,
+			0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98,
+			0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7,
+			0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6,
+			0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5,
+			0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2, 0xd3, 0xd4,
+			0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda, 0xe1, 0xe2,
+			0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea,
+			0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+	{
+		[16]byte{0, 3, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	{
+		[16]byte{0, 2, 1, 2, 4, 4, 3, 4, 7, 5, 4, 4, 0, 1, 2, 119},
+		[]byte{
+			0x00, 0x01, 0x02, 0x03, 0x11, 0x04, 0x05, 0x21,
+			0x31, 0x06, 0x12, 0x41, 0x51, 0x07, 0x61, 0x71,
+			0x13, 0x22, 0x32, 0x81, 0x08, 0x14, 0x42, 0x91,
+			0xa1, 0xb1, 0xc1, 0x09, 0x23, 0x33, 0x52, 0xf0,
+			0x15, 0x62, 0x72, 0xd1, 0x0a, 0x16, 0x24, 0x34,
+			0xe1, 0x25, 0xf1, 0x17, 0x18, 0x19, 0x1a, 0x26,
+			0x27, 0x28, 0x29, 0x2a, 0x35, 0x36, 0x37, 0x38,
+			0x39, 0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48,
+			0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58,
+			0x59, 0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68,
+			0x69, 0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78,
+			0x79, 0x7a, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+			0x88, 0x89, 0x8a, 0x92, 0x93, 0x94, 0x95, 0x96,
+			0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5,
+			0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4,
+			0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3,
+			0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2,
+			0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda,
+			0xe2, 0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9,
+			0xea, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+}
+
+// AC成分のスペクトル選択の範囲 (DCの後に成分ごとに書き出す)
+var JPEG_AC_BANDS = [][2]int{{1, 5}, {6, 63}}
+
+// 値からハフマン符号 (上位8ビットが符号長、下位24ビットが符号) への対応
+type huffmanLUT [256]uint32
+
+func newHuffmanLUT(spec huffmanSpec) huffmanLUT {
+	var lut huffmanLUT
+	code, k := uint32(0), 0
+	for i := 0; i < 16; i++ {
+		length := uint32(i+1) << 24
+		for j := 0; j < int(spec.count[i]); j++ {
+			lut[spec.value[k]] = length | code
+			code++
+			k++
+		}
+		code <<= 1
+	}
+	return lut
+}
+
+// 1成分の量子化済みDCT係数 (ブロックごとにジグザグ順)
+type jpegComponent struct {
+	blocks [][64]int32
+	table  int // 0: 輝度, 1: 色差
+}
+
+// プログレッシブJPEGにエンコードしてwに書き込む
+func encodeProgressiveJpeg(w io.Writer, img image.Image, quality int) error {
+	// 品質から量子化テーブルを求める (image/jpeg と同じ計算)
+	scale := 200 - quality*2
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+	var quant [2][64]byte
+	for i := range quant {
+		for j := range quant[i] {
+			q := (int(JPEG_UNSCALED_QUANT[i][j])*scale + 50) / 100
+			quant[i][j] = byte(min(max(q, 1), 255))
+		}
+	}
+
+	b := img.Bounds()
+	components := jpegComponents(img, &quant)
+
+	bw := &jpegBitWriter{w: bufio.NewWriter(w)}
+
+	// SOI, DQT
+	bw.write([]byte{0xff, 0xd8})
+	bw.marker(0xdb, append(append([]byte{0x00}, quant[0][:]...), append([]byte{0x01}, quant[1][:]...)...))
+
+	// SOF2 (プログレッシブ、8ビット、3成分、間引き無し)
+	bw.marker(0xc2, []byte{
+		8, byte(b.Dy() >> 8), byte(b.Dy()), byte(b.Dx() >> 8), byte(b.Dx()), 3,
+		1, 0x11, 0,
+		2, 0x11, 1,
+		3, 0x11, 1,
+	})
+
+	// DHT (DC輝度 0, AC輝度 0, DC色差 1, AC色差 1)
+	var dht []byte
+	for i, spec := range JPEG_HUFFMAN_SPECS {
+		dht = append(dht, byte((i%2)<<4|i/2))
+		dht = append(dht, spec.count[:]...)
+		dht = append(dht, spec.value...)
+	}
+	bw.marker(0xc4, dht)
+
+	var luts [4]huffmanLUT
+	for i, spec := range JPEG_HUFFMAN_SPECS {
+		luts[i] = newHuffmanLUT(spec)
+	}
+
+	// DCの走査 (全成分をブロックごとに交互に並べる)
+	bw.marker(0xda, []byte{3, 1, 0x00, 2, 0x11, 3, 0x11, 0, 0, 0})
+	var prev [3]int32
+	for i := range components[0].blocks {
+		for c, comp := range components {
+			dc := comp.blocks[i][0]
+			bw.emitValue(&luts[comp.table*2], 0, dc-prev[c])
+			prev[c] = dc
+		}
+	}
+	bw.flush()
+
+	// ACの走査 (成分ごと、範囲ごと)
+	for c, comp := range components {
+		lut := &luts[comp.table*2+1]
+		for _, band := range JPEG_AC_BANDS {
+			bw.marker(0xda, []byte{1, byte(c + 1), byte(comp.table<<4 | comp.table), byte(band[0]), byte(band[1]), 0})
+			for _, block := range comp.blocks {
+				run := 0
+				for k := band[0]; k <= band[1]; k++ {
+					if block[k] == 0 {
+						run++
+						continue
+					}
+					for run > 15 {
+						bw.emitHuffman(lut, 0xf0)
+						run -= 16
+					}
+					bw.emitValue(lut, run, block[k])
+					run = 0
+				}
+				// 残りが0の場合はEOB (EOBRUN=1)
+				if run > 0 {
+					bw.emitHuffman(lut, 0x00)
+				}
+			}
+			bw.flush()
+		}
+	}
+
+	// EOI
+	bw.write([]byte{0xff, 0xd9})
+	if bw.err != nil {
+		return bw.err
+	}
+
+	return bw.w.Flush()
+}
+
+// 画像をYCbCrの3成分に分け、8x8ブロックごとにDCTと量子化を行う
+// 端のブロックは最後の画素を繰り返して埋める
+func jpegComponents(img image.Image, quant *[2][64]byte) []jpegComponent {
+	b := img.Bounds()
+	rgba, ok := img.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(b)
+		draw.Draw(rgba, b, img, b.Min, draw.Src)
+	}
+
+	bx, by := (b.Dx()+7)/8, (b.Dy()+7)/8
+	components := []jpegComponent{{table: 0}, {table: 1}, {table: 1}}
+	for i := range components {
+		components[i].blocks = make([][64]int32, bx*by)
+	}
+
+	var samples [3][64]float64
+	for y := 0; y < by; y++ {
+		for x := 0; x < bx; x++ {
+			for j := 0; j < 8; j++ {
+				py := b.Min.Y + min(y*8+j, b.Dy()-1)
+				for i := 0; i < 8; i++ {
+					px := b.Min.X + min(x*8+i, b.Dx()-1)
+					o := rgba.PixOffset(px, py)
+					yy, cb, cr := color.RGBToYCbCr(rgba.Pix[o], rgba.Pix[o+1], rgba.Pix[o+2])
+					samples[0][j*8+i] = float64(yy) - 128
+					samples[1][j*8+i] = float64(cb) - 128
+					samples[2][j*8+i] = float64(cr) - 128
+				}
+			}
+
+			for c := range components {
+				coef := fdct(&samples[c])
+				q := &quant[components[c].table]
+				block := &components[c].blocks[y*bx+x]
+				for k := 0; k < 64; k++ {
+					// 標準のハフマンテーブルで表せる範囲 (DCは11ビット、ACは10ビット) に収める
+					v := math.Round(coef[JPEG_UNZIG[k]] / float64(q[k]))
+					if k == 0 {
+						block[k] = int32(min(max(v, -1024), 1023))
+					} else {
+						block[k] = int32(min(max(v, -1023), 1023))
+					}
+				}
+			}
+		}
+	}
+
+	return components
+}
+
+// DCTの係数 cos((2x+1)uπ/16) * C(u) / 2
+var JPEG_DCT_COS = func() (t [8][8]float64) {
+	for u := 0; u < 8; u++ {
+		c := 0.5
+		if u == 0 {
+			c = 0.5 / math.Sqrt2
+		}
+		for x := 0; x < 8; x++ {
+			t[u][x] = c * math.Cos(float64(2*x+1)*float64(u)*math.Pi/16)
+		}
+	}
+	return t
+}()
+
+// 8x8ブロックの2次元DCT (行と列に分けて計算する)
+func fdct(s *[64]float64) (out [64]float64) {
+	var tmp [64]float64
+	for y := 0; y < 8; y++ {
+		for u := 0; u < 8; u++ {
+			var sum float64
+			for x := 0; x < 8; x++ {
+				sum += JPEG_DCT_COS[u][x] * s[y*8+x]
+			}
+			tmp[y*8+u] = sum
+		}
+	}
+	for u := 0; u < 8; u++ {
+		for v := 0; v < 8; v++ {
+			var sum float64
+			for y := 0; y < 8; y++ {
+				sum += JPEG_DCT_COS[v][y] * tmp[y*8+u]
+			}
+			out[v*8+u] = sum
+		}
+	}
+	return out
+}
+
+// エントロピー符号化したデータを書き込む (0xffの後には0x00を挿入する)
+type jpegBitWriter struct {
+	w     *bufio.Writer
+	bits  uint32
+	nBits uint32
+	err   error
+}
+
+func (bw *jpegBitWriter) write(p []byte) {
+	if bw.err == nil {
+		_, bw.err = bw.w.Write(p)
+	}
+}
+
+func (bw *jpegBitWriter) marker(m byte, data []byte) {
+	n := len(data) + 2
+	bw.write([]byte{0xff, m, byte(n >> 8), byte(n)})
+	bw.write(data)
+}
+
+func (bw *jpegBitWriter) emit(bits, nBits uint32) {
+	nBits += bw.nBits
+	bits <<= 32 - nBits
+	bits |= bw.bits
+	for nBits >= 8 {
+		b := byte(bits >> 24)
+		bw.write([]byte{b})
+		if b == 0xff {
+			bw.write([]byte{0x00})
+		}
+		bits <<= 8
+		nBits -= 8
+	}
+	bw.bits, bw.nBits = bits, nBits
+}
+
+func (bw *jpegBitWriter) emitHuffman(lut *huffmanLUT, value byte) {
+	x := lut[value]
+	bw.emit(x&(1<<24-1), x>>24)
+}
+
+// (連続する0の数, 値の桁数) のハフマン符号と値のビットを書き込む
+func (bw *jpegBitWriter) emitValue(lut *huffmanLUT, run int, value int32) {
+	a, b := value, value
+	if a < 0 {
+		a, b = -value, value-1
+	}
+	var size uint32
+	for a > 0 {
+		size++
+		a >>= 1
+	}
+	bw.emitHuffman(lut, byte(uint32(run)<<4|size))
+	if size > 0 {
+		bw.emit(uint32(b)&(1<<size-1), size)
+	}
+}
+
+// 走査の終わりに残りのビットを1で埋めて書き出す
+func (bw *jpegBitWriter) flush() {
+	bw.emit(0x7f, 7)
+	bw.bits, bw.nBits = 0, 0
+}
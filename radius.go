@@ -0,0 +1,106 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+/*
+# 角丸 (-frame-radius, -round-frame)
+
+-frame-radius の場合は写真の四隅を丸め、角の外側にはフレームの色 (-background などの場合はその画像) を残す。
+-round-frame の場合はさらに、-band-blur で描いたラベル領域の四隅を同じ半径で、キャンバスの四隅を外側の枠の幅だけ大きい半径で丸め、
+写真と枠の角が同心になるようにする。キャンバスの角の外側は透明になる (png または tiff の場合)。
+
+写真などを描画する前に四隅の正方形を保存しておき、描画した後に円の外側だけを元に戻す。
+*/
+
+// 四隅を丸める前の正方形 (左上、右上、左下、右下)
+type corners struct {
+	rect   image.Rectangle
+	radius int
+	saved  [4]*image.RGBA
+}
+
+// 四隅の正方形の範囲
+func cornerRects(r image.Rectangle, radius int) [4]image.Rectangle {
+	return [4]image.Rectangle{
+		image.Rect(r.Min.X, r.Min.Y, r.Min.X+radius, r.Min.Y+radius),
+		image.Rect(r.Max.X-radius, r.Min.Y, r.Max.X, r.Min.Y+radius),
+		image.Rect(r.Min.X, r.Max.Y-radius, r.Min.X+radius, r.Max.Y),
+		image.Rect(r.Max.X-radius, r.Max.Y-radius, r.Max.X, r.Max.Y),
+	}
+}
+
+// r の四隅を描画する前に保存する
+func saveCorners(dst *image.RGBA, r image.Rectangle, radius int) *corners {
+	radius = min(radius, r.Dx()/2, r.Dy()/2)
+	if radius <= 0 {
+		return nil
+	}
+
+	c := &corners{rect: r, radius: radius}
+	for i, cr := range cornerRects(r, radius) {
+		c.saved[i] = image.NewRGBA(cr)
+		draw.Draw(c.saved[i], cr, dst, cr.Min, draw.Src)
+	}
+	return c
+}
+
+// 保存した四隅のうち円の外側だけを元に戻す
+func (c *corners) restore(dst *image.RGBA) {
+	if c == nil {
+		return
+	}
+
+	for i, cr := range cornerRects(c.rect, c.radius) {
+		draw.DrawMask(dst, cr, c.saved[i], cr.Min, cornerMask(cr, c.radius, i), cr.Min, draw.Over)
+	}
+}
+
+// r の四隅の円の外側を透明にする
+func clearCorners(dst *image.RGBA, r image.Rectangle, radius int) {
+	radius = min(radius, r.Dx()/2, r.Dy()/2)
+	if radius <= 0 {
+		return
+	}
+
+	for i, cr := range cornerRects(r, radius) {
+		mask := cornerMask(cr, radius, i)
+		for y := cr.Min.Y; y < cr.Max.Y; y++ {
+			for x := cr.Min.X; x < cr.Max.X; x++ {
+				// RGBAは乗算済みのため、全ての成分に円の内側の割合を掛ける
+				keep := 255 - uint32(mask.Pix[mask.PixOffset(x, y)])
+				p := dst.Pix[dst.PixOffset(x, y):][:4]
+				for j := range p {
+					p[j] = uint8(uint32(p[j]) * keep / 255)
+				}
+			}
+		}
+	}
+}
+
+// 角の正方形のうち円の外側を不透明にしたマスク (境界はなめらかにする)
+// i は cornerRects の順 (0: 左上、1: 右上、2: 左下、3: 右下)
+func cornerMask(cr image.Rectangle, radius int, i int) *image.Alpha {
+	mask := image.NewAlpha(cr)
+
+	// 円の中心 (正方形の内側の頂点)
+	cx, cy := float64(cr.Max.X), float64(cr.Max.Y)
+	if i == 1 || i == 3 {
+		cx = float64(cr.Min.X)
+	}
+	if i == 2 || i == 3 {
+		cy = float64(cr.Min.Y)
+	}
+
+	for y := cr.Min.Y; y < cr.Max.Y; y++ {
+		for x := cr.Min.X; x < cr.Max.X; x++ {
+			d := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy)
+			outside := min(max(d-float64(radius)+0.5, 0), 1)
+			mask.Pix[mask.PixOffset(x, y)] = uint8(outside*255 + 0.5)
+		}
+	}
+	return mask
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestCornerMask(t *testing.T) {
+	cr := image.Rect(0, 0, 20, 20)
+	tests := []struct {
+		i    int
+		x, y int // 外側の頂点
+	}{
+		{0, 0, 0},
+		{1, 19, 0},
+		{2, 0, 19},
+		{3, 19, 19},
+	}
+	for _, tt := range tests {
+		mask := cornerMask(cr, 20, tt.i)
+		// 頂点は円の外側、反対の頂点は円の内側
+		if got := mask.AlphaAt(tt.x, tt.y).A; got != 0xff {
+			t.Errorf("corner %d: outer vertex alpha = %d, want 255", tt.i, got)
+		}
+		if got := mask.AlphaAt(19-tt.x, 19-tt.y).A; got != 0 {
+			t.Errorf("corner %d: inner vertex alpha = %d, want 0", tt.i, got)
+		}
+	}
+}
+
+// 写真、-band-blur のラベル領域、キャンバスの四隅がそれぞれ丸くなる
+func TestFrameRadiusCorners(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+
+	tests := []struct {
+		name        string
+		radius      int
+		roundFrame  bool
+		photo       bool // 写真の角にフレームの色が残る
+		band        bool // ラベル領域の角にフレームの色が残る
+		transparent bool // キャンバスの角が透明
+	}{
+		{"no radius", 0, true, false, false, false},
+		{"frame-radius", 40, false, true, false, false},
+		{"round-frame", 40, true, true, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig(t)
+			config.frameRadius = tt.radius
+			config.roundFrame = tt.roundFrame
+			config.bandBlur = true
+
+			src := testImage(400, 300, red)
+			layout := testLayout(t, 400, 300, config, testExifData())
+			dst := renderFrame(src, layout, config)
+
+			photo, band := layout.Image, layout.Band
+			checks := []struct {
+				name    string
+				corner  image.Point // 角の画素
+				inside  image.Point // 角から離れた画素
+				rounded bool
+			}{
+				{"photo", image.Pt(photo.X, photo.Y), image.Pt(photo.X+photo.Width/2, photo.Y), tt.photo},
+				{"band", image.Pt(band.X, band.Y), image.Pt(band.X+band.Width/2, band.Y), tt.band},
+			}
+			for _, c := range checks {
+				corner, inside := dst.RGBAAt(c.corner.X, c.corner.Y), dst.RGBAAt(c.inside.X, c.inside.Y)
+				if rounded := corner == white && inside != white; rounded != c.rounded {
+					t.Errorf("%s corner = %v (inside %v), rounded %v, want %v", c.name, corner, inside, rounded, c.rounded)
+				}
+			}
+
+			// キャンバスの角は写真の角と同心になる半径で透明にする
+			if transparent := dst.RGBAAt(0, 0).A == 0; transparent != tt.transparent {
+				t.Errorf("canvas corner = %v, transparent %v, want %v", dst.RGBAAt(0, 0), transparent, tt.transparent)
+			}
+			if tt.transparent {
+				edge := photo.X + tt.radius
+				if dst.RGBAAt(edge, 0).A != 0xff || dst.RGBAAt(edge/4, edge/4).A != 0 {
+					t.Errorf("canvas corner radius is not %d", edge)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ラベルの文字列の置き換え (-rename "ILCE-7M4=α7 IV")
+type RenameRule struct {
+	From string
+	To   string
+}
+
+// 繰り返し指定できる -rename の値
+type renameFlag []RenameRule
+
+func (f *renameFlag) String() string {
+	var rules []string
+	for _, rule := range *f {
+		rules = append(rules, rule.From+"="+rule.To)
+	}
+	return strings.Join(rules, ", ")
+}
+
+func (f *renameFlag) Set(value string) error {
+	rule, err := parseRenameRule(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, rule)
+	return nil
+}
+
+// "置き換え前=置き換え後" (置き換え後は空でもよい)
+func parseRenameRule(value string) (RenameRule, error) {
+	from, to, ok := strings.Cut(value, "=")
+	if !ok || from == "" {
+		return RenameRule{}, fmt.Errorf("invalid rename rule %q (want FROM=TO)", value)
+	}
+	return RenameRule{from, to}, nil
+}
+
+// 置き換えを指定した順に適用する (ignoreCase の場合は大文字と小文字を区別しない)
+func applyRenames(text string, rules []RenameRule, ignoreCase bool) string {
+	for _, rule := range rules {
+		if !ignoreCase {
+			text = strings.ReplaceAll(text, rule.From, rule.To)
+			continue
+		}
+
+		re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(rule.From))
+		text = re.ReplaceAllLiteralString(text, rule.To)
+	}
+	return text
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"slices"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// グリフを描画しようとするとpanicするFace (壊れた書体や文字列を再現する)
+type panicFace struct {
+	font.Face
+}
+
+func (f panicFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	panic("broken glyph")
+}
+
+// 1行の描画がpanicしても、その行を除いた画像を描画して保存できる
+func TestRenderFrameRecoversLinePanic(t *testing.T) {
+	config := testConfig(t)
+	src := testImage(400, 300, color.Gray{128})
+	layout := testLayout(t, 400, 300, config, testExifData())
+	if len(layout.Lines) < 2 {
+		t.Fatalf("layout has %d lines, want at least 2", len(layout.Lines))
+	}
+
+	// panicする行を除いたレイアウトで描画した結果と同じになる
+	rest := *layout
+	rest.Lines = slices.Clone(layout.Lines[1:])
+	want := renderFrame(src, &rest, config)
+
+	broken := *layout
+	broken.Lines = slices.Clone(layout.Lines)
+	broken.Lines[0].face = panicFace{broken.Lines[0].face}
+	got := renderFrame(src, &broken, config)
+
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Error("frame differs from the one drawn without the broken line")
+	}
+	if bytes.Equal(got.Pix, renderFrame(src, &Layout{Canvas: layout.Canvas, Image: layout.Image, Band: layout.Band}, config).Pix) {
+		t.Error("remaining lines were not drawn")
+	}
+
+	var buf bytes.Buffer
+	if err := encodeFrame(&buf, got, config); err != nil {
+		t.Fatalf("encodeFrame: %v", err)
+	}
+	if _, _, err := image.Decode(&buf); err != nil {
+		t.Errorf("decoding the output: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+/*
+# 使い回せる描画の設定 (Renderer)
+
+フォントの解析やFaceの作成は設定ごとに1回で済むため、同じ設定で多くの画像にフレームを付ける場合は
+NewRenderer で一度だけ準備し、Render を繰り返し呼ぶ。グリフのマスクは GLYPH_CACHE で全体で共有する。
+
+- Renderer は同時に複数のゴルーチンから使えない (Faceが描画中の状態を持つため)。並列に処理する場合はゴルーチンごとに作る
+- FrameBatch はワーカーごとに1つ作り、そのワーカーが処理するすべてのファイルで使う
+- FrameToWriter はその場で作る Renderer で1回だけ描画する
+*/
+
+type Renderer struct {
+	config Config // 準備済みのFaceを持つ設定 (ファイルごとに複製して使う)
+}
+
+// config で描画する Renderer を作る (フォントはここで解析する)
+func NewRenderer(config *Config) (*Renderer, error) {
+	c := *config
+	faces, err := loadFaces(&c)
+	if err != nil {
+		return nil, err
+	}
+	c.faces = faces
+
+	return &Renderer{config: c}, nil
+}
+
+// 画像データを読み込み、フレームを付けた画像をwに書き込む
+func (r *Renderer) Render(w io.Writer, src io.Reader) error {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return fmt.Errorf("reading image: %w", err)
+	}
+
+	// 読み込んだExif情報などを書き込むため、呼び出しごとに複製する
+	c := r.config
+	exifData, err := getExif(data, &c)
+	if err != nil {
+		return err
+	}
+
+	return drawFrame(w, data, &c, exifData)
+}
+
+// 設定に準備済みのFaceがあればそれを、無ければフォントを解析して返す
+func configFaces(config *Config) (*Faces, error) {
+	if config.faces != nil {
+		return config.faces, nil
+	}
+	return loadFaces(config)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// 使い回した Renderer でも、その場で作る場合と同じ画像になる (前の画像の状態が残らない)
+func TestRendererReuse(t *testing.T) {
+	images := [][]byte{
+		testJpeg(t, 300, 200, testCameraTags()...),
+		testJpeg(t, 200, 300, testTag{IFD_PATH, "Make", "FUJIFILM"}, testTag{IFD_PATH, "Model", "X-T5"}),
+		testJpeg(t, 300, 200, testCameraTags()...),
+	}
+
+	renderer, err := NewRenderer(testConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, data := range images {
+		var got, want bytes.Buffer
+		if err := renderer.Render(&got, bytes.NewReader(data)); err != nil {
+			t.Fatalf("image %d: Render: %v", i, err)
+		}
+		if err := FrameToWriter(&want, bytes.NewReader(data), testConfig(t)); err != nil {
+			t.Fatalf("image %d: FrameToWriter: %v", i, err)
+		}
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Errorf("image %d: output differs from FrameToWriter", i)
+		}
+	}
+}
+
+// 画像ごとに設定を準備する場合と、Renderer を使い回す場合
+func BenchmarkRender(b *testing.B) {
+	data := testJpeg(b, 600, 400, testCameraTags()...)
+	config := testConfig(b)
+
+	b.Run("per-call", func(b *testing.B) {
+		for b.Loop() {
+			renderer, err := NewRenderer(config)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := renderer.Render(io.Discard, bytes.NewReader(data)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("reused", func(b *testing.B) {
+		renderer, err := NewRenderer(config)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for b.Loop() {
+			if err := renderer.Render(io.Discard, bytes.NewReader(data)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Exif情報の充足度を数える項目 (ラベルの描画に使う主な項目)
+var REPORT_FIELDS = []struct {
+	name  string
+	value func(*ExifData) string
+}{
+	{"Make", func(e *ExifData) string { return e.Make }},
+	{"Model", func(e *ExifData) string { return e.Model }},
+	{"LensMake", func(e *ExifData) string { return e.LensMake }},
+	{"LensModel", func(e *ExifData) string { return e.LensModel }},
+	{"ExposureTime", func(e *ExifData) string { return e.ExposureTime }},
+	{"FNumber", func(e *ExifData) string { return e.FNumber }},
+	{"PhotographicSensitivity", func(e *ExifData) string { return e.PhotographicSensitivity }},
+	{"FocalLengthIn35mmFilm", func(e *ExifData) string { return e.FocalLengthIn35mmFilm }},
+	{"FocalLength", func(e *ExifData) string { return e.FocalLength }},
+	{"DateTimeOriginal", func(e *ExifData) string { return e.DateTimeOriginal }},
+}
+
+// 主な項目のうち値のある数と、値の無い項目の名前を返す
+func exifCompleteness(exifData *ExifData) (present int, missing []string) {
+	for _, field := range REPORT_FIELDS {
+		if field.value(exifData) == "" {
+			missing = append(missing, field.name)
+			continue
+		}
+		present++
+	}
+
+	return present, missing
+}
+
+// -report の1行 (例: "image.jpg: 8/10 key fields present (missing: LensMake, FocalLength)")
+func formatReport(fileName string, exifData *ExifData) string {
+	present, missing := exifCompleteness(exifData)
+
+	report := fmt.Sprintf("%s: %d/%d key fields present", fileName, present, len(REPORT_FIELDS))
+	if len(missing) > 0 {
+		report += " (missing: " + strings.Join(missing, ", ") + ")"
+	}
+
+	return report
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	REVEAL_WIDTH  = 1080 // GIFの幅 (SNS向けに縮小する)
+	REVEAL_FRAMES = 12   // ラベルが現れるまでのコマ数
+	REVEAL_DELAY  = 8    // 1コマの表示時間 (1/100秒)
+	REVEAL_HOLD   = 200  // 最後のコマの表示時間 (1/100秒)
+)
+
+// -reveal の出力ファイル名 (exiframe-*.gif)
+func revealPath(fileName string) string {
+	return FILE_NAME_PREFIX + strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ".gif"
+}
+
+// ラベル領域が左から (縦書きの場合は上から) 現れるアニメーションGIFを書き込む
+// 完成したフレームのラベル領域をフレームの色で塗りつぶした画像から始め、完成した画像を左から少しずつ重ねる
+func encodeReveal(w io.Writer, dst image.Image, layout *Layout, config *Config) error {
+	b := dst.Bounds()
+	width := min(REVEAL_WIDTH, b.Dx())
+	height := max(b.Dy()*width/b.Dx(), 1)
+	scale := float64(width) / float64(b.Dx())
+
+	final := imaging.Resize(dst, width, height, imaging.Lanczos)
+
+	start := image.NewNRGBA(final.Bounds())
+	draw.Draw(start, start.Bounds(), final, image.Point{}, draw.Src)
+	band := image.Rect(
+		int(float64(layout.Band.X)*scale), int(float64(layout.Band.Y)*scale),
+		int(float64(layout.Band.X+layout.Band.Width)*scale), int(float64(layout.Band.Y+layout.Band.Height)*scale),
+	).Intersect(start.Bounds())
+	draw.Draw(start, band, config.frameColor, image.Point{}, draw.Src)
+
+	// 減色は最初と最後の画像だけ行い、途中のコマは画素の行をコピーして作る
+	startPaletted := quantize(start)
+	finalPaletted := quantize(final)
+
+	anim := &gif.GIF{LoopCount: 0}
+	for i := 0; i <= REVEAL_FRAMES; i++ {
+		frame := image.NewPaletted(final.Bounds(), palette.Plan9)
+		copy(frame.Pix, startPaletted.Pix)
+
+		// 縦書きで右に置いたラベルは上から現れる
+		x, maxY := band.Min.X+band.Dx()*i/REVEAL_FRAMES, band.Max.Y
+		if band.Dy() > band.Dx() {
+			x, maxY = band.Max.X, band.Min.Y+band.Dy()*i/REVEAL_FRAMES
+		}
+		for y := band.Min.Y; y < maxY; y++ {
+			row := y * frame.Stride
+			copy(frame.Pix[row+band.Min.X:row+x], finalPaletted.Pix[row+band.Min.X:row+x])
+		}
+
+		delay := REVEAL_DELAY
+		if i == REVEAL_FRAMES {
+			delay = REVEAL_HOLD
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, delay)
+	}
+
+	err := gif.EncodeAll(w, anim)
+	if err != nil {
+		return fmt.Errorf("encoding GIF: %w", err)
+	}
+	return nil
+}
+
+func quantize(img image.Image) *image.Paletted {
+	paletted := image.NewPaletted(img.Bounds(), palette.Plan9)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), img, img.Bounds().Min)
+	return paletted
+}
+
+// -reveal のGIFを保存する
+func writeReveal(dst image.Image, layout *Layout, config *Config) (string, error) {
+	dstPath := revealPath(config.fileName)
+	f, err := os.Create(dstPath)
+	if err != nil {
+		return "", ioError(fmt.Errorf("reveal: creating file: %w", err))
+	}
+	defer f.Close()
+
+	err = encodeReveal(f, dst, layout, config)
+	if err != nil {
+		return "", ioError(fmt.Errorf("reveal: %w", err))
+	}
+
+	return dstPath, f.Close()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+/*
+# センサーサイズ (-sensor)
+
+FocalPlaneXResolution / FocalPlaneYResolution (撮像面の単位あたりの画素数) と FocalPlaneResolutionUnit から
+センサーの物理的な大きさを求め、35mmフルサイズに対する対角線の比 (クロップファクター) でフォーマットを分類する。
+-sensor の場合はカメラ名の後ろにフォーマット (例: "APS-C") を併記する。
+
+画素数には PixelXDimension / PixelYDimension を使う。いずれかのタグが無い場合や単位が不明な場合は表示しない。
+*/
+
+const (
+	FOCAL_PLANE_X_RESOLUTION_TAG_ID = 0xa20e
+	FOCAL_PLANE_Y_RESOLUTION_TAG_ID = 0xa20f
+	FOCAL_PLANE_UNIT_TAG_ID         = 0xa210
+
+	FULL_FRAME_DIAGONAL = 43.27 // 35mmフルサイズ (36x24mm) の対角線の長さ (mm)
+)
+
+// FocalPlaneResolutionUnit ごとの1単位の長さ (mm)
+// 3 (cm) までがExifの規格で、4 (mm) と 5 (µm) は一部のカメラが使う値
+var FOCAL_PLANE_UNIT_MM = map[uint16]float64{
+	2: 25.4,
+	3: 10,
+	4: 1,
+	5: 0.001,
+}
+
+// クロップファクターの上限ごとのフォーマット (小さい順に判定する)
+var SENSOR_FORMATS = []struct {
+	maxCrop float64
+	name    string
+}{
+	{0.9, "Medium Format"},
+	{1.15, "Full Frame"},
+	{1.4, "APS-H"},
+	{1.75, "APS-C"},
+	{2.3, "MFT"},
+	{3.2, "1-inch"},
+}
+
+// 撮像面の解像度と画素数からセンサーのフォーマットを求める (求められない場合は空文字列)
+func readSensorFormat(exifIfd *exif.Ifd, pixelX, pixelY int) (string, error) {
+	if pixelX <= 0 || pixelY <= 0 {
+		return "", nil
+	}
+
+	xResolution, err := readFocalPlaneResolution(exifIfd, FOCAL_PLANE_X_RESOLUTION_TAG_ID)
+	if err != nil || xResolution == 0 {
+		return "", err
+	}
+	yResolution, err := readFocalPlaneResolution(exifIfd, FOCAL_PLANE_Y_RESOLUTION_TAG_ID)
+	if err != nil || yResolution == 0 {
+		return "", err
+	}
+
+	// 単位が無い場合は規格の既定値 (インチ) とする
+	unit := uint16(2)
+	results, err := exifIfd.FindTagWithId(FOCAL_PLANE_UNIT_TAG_ID)
+	if err == nil && len(results) > 0 {
+		value, err := results[0].Value()
+		if err != nil {
+			return "", err
+		}
+		units, ok := value.([]uint16)
+		if !ok || len(units) == 0 {
+			return "", fmt.Errorf("unexpected FocalPlaneResolutionUnit %v", value)
+		}
+		unit = units[0]
+	}
+	unitMm, ok := FOCAL_PLANE_UNIT_MM[unit]
+	if !ok {
+		return "", nil
+	}
+
+	width := float64(pixelX) / xResolution * unitMm
+	height := float64(pixelY) / yResolution * unitMm
+	return sensorFormat(width, height), nil
+}
+
+// FocalPlaneXResolution / FocalPlaneYResolution の値 (無い場合は0)
+func readFocalPlaneResolution(exifIfd *exif.Ifd, tagId uint16) (float64, error) {
+	results, err := exifIfd.FindTagWithId(tagId)
+	if err != nil || len(results) == 0 {
+		return 0, nil
+	}
+
+	value, err := results[0].Value()
+	if err != nil {
+		return 0, err
+	}
+
+	rationals, ok := value.([]exifcommon.Rational)
+	if !ok || len(rationals) == 0 {
+		return 0, fmt.Errorf("unexpected value %v", value)
+	}
+	if rationals[0].Denominator == 0 {
+		return 0, nil
+	}
+
+	return float64(rationals[0].Numerator) / float64(rationals[0].Denominator), nil
+}
+
+// センサーの幅と高さ (mm) のフォーマット ("Full Frame", "APS-C" など、1インチより小さい場合は空文字列)
+func sensorFormat(width, height float64) string {
+	diagonal := math.Hypot(width, height)
+	if diagonal <= 0 {
+		return ""
+	}
+
+	crop := FULL_FRAME_DIAGONAL / diagonal
+	for _, f := range SENSOR_FORMATS {
+		if crop < f.maxCrop {
+			return f.name
+		}
+	}
+	return ""
+}
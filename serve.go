@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	SERVE_ADDR           = ":8080"
+	SERVE_MAX_UPLOAD_MB  = 32
+	SERVE_TIMEOUT        = 60 * time.Second
+	SERVE_MAX_MEGAPIXELS = 100
+	SERVE_FORM_FILE_NAME = "image"
+)
+
+// exiframe serve でアップロードされた画像にフレームを付けて返す
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", SERVE_ADDR, "Address to listen on")
+	maxUploadMB := fs.Int64("max-upload", SERVE_MAX_UPLOAD_MB, "Maximum upload size in MB")
+	timeout := fs.Duration("timeout", SERVE_TIMEOUT, "Timeout for each request")
+	maxMegapixels := fs.Float64("max-megapixels", SERVE_MAX_MEGAPIXELS, "Reject images larger than this many megapixels (0 for no limit)")
+	fs.Parse(args)
+
+	maxUpload := *maxUploadMB << 20
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /frame", func(w http.ResponseWriter, r *http.Request) {
+		handleFrame(w, r, maxUpload, *maxMegapixels)
+	})
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           http.TimeoutHandler(mux, *timeout, "request timed out"),
+		ReadHeaderTimeout: *timeout,
+		ReadTimeout:       *timeout,
+		WriteTimeout:      *timeout + 5*time.Second,
+	}
+
+	// サーバーは待ち受けるアドレスやリクエストごとの情報を表示する
+	logLevel = LOG_INFO
+	logInfo("Listening on", *addr)
+	err := server.ListenAndServe()
+	if err != nil {
+		logError("Error ListenAndServe:", err)
+		os.Exit(1)
+	}
+}
+
+// multipartの "image" フィールドの画像を受け取り、フレームを付けたJPEGを返す
+func handleFrame(w http.ResponseWriter, r *http.Request, maxUpload int64, maxMegapixels float64) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUpload)
+
+	err := r.ParseMultipartForm(maxUpload)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "upload too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile(SERVE_FORM_FILE_NAME)
+	if err != nil {
+		http.Error(w, "missing form file \""+SERVE_FORM_FILE_NAME+"\"", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	config, err := configFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	config.fileName = header.Filename
+	config.maxMegapixels = maxMegapixels
+
+	// エラー時にステータスを返せるよう、バッファに書き込んでから送信する
+	var buf bytes.Buffer
+	err = FrameToWriter(&buf, file, config)
+	if errors.Is(err, ErrImageTooLarge) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	buf.WriteTo(w)
+}
+
+// クエリパラメータからConfigを作成
+func configFromQuery(q url.Values) (*Config, error) {
+	config := &Config{
+		textOutlineWidth: TEXT_OUTLINE_WIDTH,
+		labelGap:         FRAME_PIXEL,
+		trimTolerance:    TRIM_TOLERANCE,
+		seed:             DEFAULT_SEED,
+		shadowWidth:      INNER_SHADOW_WIDTH,
+		shadowOpacity:    INNER_SHADOW_OPACITY,
+		dpi:              DEFAULT_DPI,
+	}
+
+	bools := map[string]*bool{
+		"black":              &config.frameColorBlack,
+		"no-frame":           &config.noFrame,
+		"band-only":          &config.bandOnly,
+		"no-model":           &config.noModelData,
+		"keep-exif":          &config.keepExif,
+		"strip-gps":          &config.stripGps,
+		"comment":            &config.drawComment,
+		"text-outline":       &config.textOutline,
+		"aperture-icon":      &config.apertureIcon,
+		"no-dedup":           &config.noDedup,
+		"gps-time":           &config.gpsTime,
+		"trim":               &config.trim,
+		"table":              &config.table,
+		"infer-tz":           &config.inferTz,
+		"no-iso-grouping":    &config.noIsoGrouping,
+		"progressive":        &config.progressive,
+		"rename-ignore-case": &config.renameIgnoreCase,
+		"focal-bar":          &config.focalBar,
+		"focus":              &config.focus,
+		"compact":            &config.compact,
+		"faststart":          &config.faststart,
+		"tonemap":            &config.tonemap,
+		"mark":               &config.mark,
+		"band-blur":          &config.bandBlur,
+		"inner-shadow":       &config.innerShadow,
+		"keywords":           &config.keywords,
+		"sensor":             &config.sensor,
+		"inventory":          &config.inventory,
+		"font-baselines":     &config.fontBaselines,
+		"fit-labels":         &config.fitLabels,
+		"round-frame":        &config.roundFrame,
+	}
+	for name, dst := range bools {
+		if !q.Has(name) {
+			continue
+		}
+
+		// ?black のように値が無い場合は true とする
+		value := q.Get(name)
+		if value == "" {
+			*dst = true
+			continue
+		}
+
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %q", name, value)
+		}
+		*dst = b
+	}
+
+	floats := map[string]*float64{
+		"brightness": &config.brightness,
+		"contrast":   &config.contrast,
+	}
+	for name, dst := range floats {
+		if !q.Has(name) {
+			continue
+		}
+
+		f, err := strconv.ParseFloat(q.Get(name), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %q", name, q.Get(name))
+		}
+		*dst = f
+	}
+
+	if q.Has("text-outline-width") {
+		w, err := strconv.Atoi(q.Get("text-outline-width"))
+		if err != nil || w < 1 || w > MAX_TEXT_OUTLINE_WIDTH {
+			return nil, fmt.Errorf("invalid value for text-outline-width: %q", q.Get("text-outline-width"))
+		}
+		config.textOutlineWidth = w
+	}
+
+	if q.Has("inner-shadow-width") {
+		w, err := strconv.Atoi(q.Get("inner-shadow-width"))
+		if err != nil || w < 1 {
+			return nil, fmt.Errorf("invalid value for inner-shadow-width: %q", q.Get("inner-shadow-width"))
+		}
+		config.shadowWidth = w
+	}
+
+	if q.Has("inner-shadow-opacity") {
+		f, err := strconv.ParseFloat(q.Get("inner-shadow-opacity"), 64)
+		if err != nil || f < 0 || f > 1 {
+			return nil, fmt.Errorf("invalid value for inner-shadow-opacity: %q", q.Get("inner-shadow-opacity"))
+		}
+		config.shadowOpacity = f
+	}
+
+	if q.Has("font-size-pt") {
+		f, err := strconv.ParseFloat(q.Get("font-size-pt"), 64)
+		if err != nil || f < 0 {
+			return nil, fmt.Errorf("invalid value for font-size-pt: %q", q.Get("font-size-pt"))
+		}
+		config.fontSizePt = f
+	}
+
+	if q.Has("dpi") {
+		f, err := strconv.ParseFloat(q.Get("dpi"), 64)
+		if err != nil || f <= 0 {
+			return nil, fmt.Errorf("invalid value for dpi: %q", q.Get("dpi"))
+		}
+		config.dpi = f
+	}
+
+	if q.Has("frame-radius") {
+		n, err := strconv.Atoi(q.Get("frame-radius"))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid value for frame-radius: %q", q.Get("frame-radius"))
+		}
+		config.frameRadius = n
+	}
+
+	if q.Has("columns") {
+		n, err := strconv.Atoi(q.Get("columns"))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid value for columns: %q", q.Get("columns"))
+		}
+		config.columns = n
+	}
+
+	if q.Has("max-label-lines") {
+		n, err := strconv.Atoi(q.Get("max-label-lines"))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid value for max-label-lines: %q", q.Get("max-label-lines"))
+		}
+		config.maxLabelLines = n
+	}
+
+	if q.Has("compact-separator") {
+		config.compactSeparator = q.Get("compact-separator")
+	}
+
+	if q.Has("frame-color") {
+		v := q.Get("frame-color")
+		if v != "" && v != FRAME_COLOR_PALETTE {
+			return nil, fmt.Errorf("invalid value for frame-color: %q", v)
+		}
+		config.frameColorMode = v
+	}
+
+	if q.Has("seed") {
+		seed, err := strconv.ParseUint(q.Get("seed"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for seed: %q", q.Get("seed"))
+		}
+		config.seed = seed
+	}
+
+	if q.Has("aspect") {
+		ratio, err := parseAspect(q.Get("aspect"))
+		if err != nil {
+			return nil, err
+		}
+		config.aspect = ratio
+	}
+
+	if q.Has("footer-text") {
+		config.footerText = q.Get("footer-text")
+	}
+
+	if q.Has("footer-align") {
+		v := q.Get("footer-align")
+		if v != FOOTER_ALIGN_CENTER && v != FOOTER_ALIGN_RIGHT {
+			return nil, fmt.Errorf("invalid value for footer-align: %q", v)
+		}
+		config.footerAlign = v
+	}
+
+	if q.Has("text-background") {
+		v := q.Get("text-background")
+		if v != TEXT_BACKGROUND_AUTO && v != TEXT_BACKGROUND_OFF {
+			return nil, fmt.Errorf("invalid value for text-background: %q", v)
+		}
+		config.textBackground = v
+	}
+
+	if q.Has("vertical-text") {
+		v := q.Get("vertical-text")
+		if v != VERTICAL_TEXT_CW && v != VERTICAL_TEXT_CCW {
+			return nil, fmt.Errorf("invalid value for vertical-text: %q", v)
+		}
+		config.verticalText = v
+	}
+
+	theme := DEFAULT_THEME
+	if q.Has("theme") {
+		theme = q.Get("theme")
+	}
+	err := applyTheme(config, theme, q.Has)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.Has("fields") {
+		spec := config.layoutSpec
+		if spec == nil {
+			spec = DEFAULT_LAYOUT_SPEC
+		}
+		config.fields, err = parseFields(q.Get("fields"), spec, config)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for fields: %w", err)
+		}
+	}
+
+	for _, value := range q["rename"] {
+		rule, err := parseRenameRule(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for rename: %w", err)
+		}
+		config.renames = append(config.renames, rule)
+	}
+
+	for _, value := range q["exif-field"] {
+		field, err := parseExifField(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for exif-field: %w", err)
+		}
+		config.exifFields = append(config.exifFields, field)
+	}
+
+	manual, err := parseManualExif(q.Get("manual-camera"), q.Get("manual-lens"), q.Get("manual-exposure"), q.Get("manual-date"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid manual value: %w", err)
+	}
+	config.manual = manual
+
+	if q.Has("frame-inset") {
+		n, err := strconv.Atoi(q.Get("frame-inset"))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid value for frame-inset: %q", q.Get("frame-inset"))
+		}
+		config.frameInset = n
+	}
+
+	if q.Has("trim-tolerance") {
+		n, err := strconv.Atoi(q.Get("trim-tolerance"))
+		if err != nil || n < 0 || n > 255 {
+			return nil, fmt.Errorf("invalid value for trim-tolerance: %q", q.Get("trim-tolerance"))
+		}
+		config.trimTolerance = n
+	}
+
+	if q.Has("label-gap") {
+		n, err := strconv.Atoi(q.Get("label-gap"))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid value for label-gap: %q", q.Get("label-gap"))
+		}
+		config.labelGap = n
+	}
+
+	if q.Has("inset-color") {
+		c, err := parseHexColor(q.Get("inset-color"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for inset-color: %q", q.Get("inset-color"))
+		}
+		config.insetColor = c
+	}
+
+	// ?mark の印にはクエリパラメータを記録する
+	if config.mark {
+		for _, name := range slices.Sorted(maps.Keys(q)) {
+			config.settings = append(config.settings, name+"="+q.Get(name))
+		}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// -sizes の値 (例: "400,800,1600") を幅の一覧にする
+func parseSizes(value string) ([]int, error) {
+	var sizes []int
+	seen := map[int]bool{}
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid size: %q", s)
+		}
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		sizes = append(sizes, n)
+	}
+
+	return sizes, nil
+}
+
+// 幅ごとの出力ファイル名 (exiframe-photo-400.jpg)
+func sizedOutputPath(fileName string, format string, width int) string {
+	path := outputPath(fileName, format)
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + strconv.Itoa(width) + ext
+}
+
+// フレームを付けた画像を -sizes の各幅に縮小し、出力形式ごとに保存する
+// 元の画像より大きい幅は拡大せず、元の大きさのまま保存する
+func writeSizedOutputs(dst image.Image, formats []string, config *Config) ([]string, error) {
+	var outputs []string
+	var errs []error
+	for _, width := range config.sizes {
+		resized := dst
+		if width < dst.Bounds().Dx() {
+			resized = imaging.Resize(dst, width, 0, imaging.Lanczos)
+		}
+
+		for _, format := range formats {
+			var buf bytes.Buffer
+			err := encodeFormat(&buf, resized, format, config)
+			if err != nil {
+				errs = append(errs, ioError(fmt.Errorf("%s %dpx: %w", format, width, err)))
+				continue
+			}
+
+			dstPath := sizedOutputPath(config.fileName, format, width)
+			err = os.WriteFile(dstPath, buf.Bytes(), 0666)
+			if err != nil {
+				errs = append(errs, ioError(fmt.Errorf("%s %dpx: creating file: %w", format, width, err)))
+				continue
+			}
+
+			outputs = append(outputs, dstPath)
+		}
+	}
+
+	return outputs, errors.Join(errs...)
+}
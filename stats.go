@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// -stats で表示する処理の段階
+const (
+	STAGE_DECODE = iota
+	STAGE_EXIF
+	STAGE_COMPOSE
+	STAGE_ENCODE
+)
+
+var STAGE_NAMES = [...]string{"decode", "exif", "compose", "encode"}
+
+// 1ファイル分の段階ごとの処理時間 (nilの場合は記録しない)
+type Stats [len(STAGE_NAMES)]time.Duration
+
+// start からの経過時間を stage に加える
+func (s *Stats) record(stage int, start time.Time) {
+	if s != nil {
+		s[stage] += time.Since(start)
+	}
+}
+
+func (s *Stats) total() time.Duration {
+	var total time.Duration
+	for _, d := range s {
+		total += d
+	}
+	return total
+}
+
+// 例: "decode 120ms, exif 3ms, compose 80ms, encode 400ms, total 603ms"
+func (s *Stats) String() string {
+	var parts []string
+	for i, d := range s {
+		parts = append(parts, fmt.Sprintf("%s %v", STAGE_NAMES[i], d.Round(time.Millisecond)))
+	}
+	parts = append(parts, fmt.Sprintf("total %v", s.total().Round(time.Millisecond)))
+
+	return strings.Join(parts, ", ")
+}
+
+// 複数ファイルの合計と段階ごとの平均
+func formatStatsSummary(results []Result) string {
+	var sum Stats
+	n := 0
+	for _, result := range results {
+		if result.Stats == nil {
+			continue
+		}
+		for i, d := range result.Stats {
+			sum[i] += d
+		}
+		n++
+	}
+	if n == 0 {
+		return ""
+	}
+
+	var parts []string
+	for i, d := range sum {
+		parts = append(parts, fmt.Sprintf("%s %v", STAGE_NAMES[i], (d/time.Duration(n)).Round(time.Millisecond)))
+	}
+
+	return fmt.Sprintf("Stats %d files: total %v (average %s)", n, sum.total().Round(time.Millisecond), strings.Join(parts, ", "))
+}
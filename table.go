@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/image/font"
+)
+
+/*
+# 表形式のラベル (-table)
+
+レイアウト定義の各行を「項目名 | 値」の2列の表としてラベル領域に並べる。
+項目名は行の name の先頭を大文字にしたもの (camera -> Camera)、値は text を置き換えた文字列。
+値が空の行は描画せず、ラベル領域の高さは行数に合わせて変わる。
+*/
+
+// 項目名と値の間隔 (ピクセル)
+const TABLE_COLUMN_GAP = 80
+
+// ラベルを表形式で並べるレイアウトを計算する
+func computeTableLayout(srcWidth, srcHeight int, config *Config, exifData *ExifData, faces *Faces) *Layout {
+	// 枠と画像の位置は通常のレイアウトと同じ
+	c := *config
+	c.table = false
+	layout := computeLayout(srcWidth, srcHeight, &c, exifData, faces)
+	layout.Lines = nil
+	layout.ApertureIcon = nil
+
+	spec := config.layoutSpec
+	if spec == nil {
+		spec = DEFAULT_LAYOUT_SPEC
+	}
+
+	fields := layoutFields(exifData, config)
+
+	type row struct{ name, key, value string }
+	var rows []row
+	lineText := func(lineSpec LineSpec) string {
+		value := os.Expand(lineSpec.Text, func(name string) string {
+			return fields[name]
+		})
+		return applyRenames(value, config.renames, config.renameIgnoreCase)
+	}
+
+	// -max-label-lines を超える行は省く
+	lines, dropped := limitLines(selectLines(spec, config.fields), config.maxLabelLines, func(lineSpec LineSpec) bool {
+		return strings.TrimSpace(lineText(lineSpec)) != ""
+	})
+	layout.DroppedLines = dropped
+
+	for _, lineSpec := range lines {
+		value := lineText(lineSpec)
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		key := strings.ToUpper(lineSpec.Name[:1]) + lineSpec.Name[1:]
+		rows = append(rows, row{lineSpec.Name, key, value})
+	}
+
+	keyFace := faces.face("regular", FONT_SIZE)
+	valueFace := faces.face("bold", FONT_SIZE)
+
+	keyWidth := 0
+	for _, r := range rows {
+		keyWidth = max(keyWidth, font.MeasureString(keyFace, r.key).Ceil())
+	}
+
+	// 枠の有無にかかわらず、文字は左端から FRAME_PIXEL の位置に揃える
+	keyX := FRAME_PIXEL
+	valueX := keyX + keyWidth + TABLE_COLUMN_GAP
+	rowHeight := valueFace.Metrics().Height.Ceil() * 5 / 4
+	y := layout.Band.Y + config.labelGap + valueFace.Metrics().Ascent.Ceil()
+
+	for _, r := range rows {
+		valueWidth := font.MeasureString(valueFace, r.value).Ceil()
+		layout.Lines = append(layout.Lines,
+			LayoutLine{Name: r.name + "_key", Text: r.key, X: keyX, Y: y, Width: font.MeasureString(keyFace, r.key).Ceil(), face: keyFace},
+			LayoutLine{Name: r.name, Text: r.value, X: valueX, Y: y, Width: valueWidth, face: valueFace},
+		)
+
+		// 絞りのアイコンは撮影データの値の右に置く
+		if f := layout.fNumber; r.name == "exposure" && config.apertureIcon && f > 0 {
+			size := valueFace.Metrics().Ascent.Ceil()
+			layout.ApertureIcon = &LayoutRect{valueX + valueWidth + size/2, y - size, size, size}
+		}
+
+		y += rowHeight
+	}
+
+	// ラベル領域の高さを行数に合わせる (下の余白は上と同じ)
+	bandHeight := config.labelGap + len(rows)*rowHeight + config.labelGap
+	layout.Canvas.Height += bandHeight - layout.Band.Height
+	layout.Band.Height = bandHeight
+
+	return layout
+}
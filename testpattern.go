@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+# テストパターン (-test-pattern、デバッグ用)
+
+レイアウトを調整するための隠しオプション。-h には表示しない。
+写真の代わりに指定した大きさのグラデーションと市松模様の画像を作り、作り物のExif情報で通常と同じ処理を行って
+exiframe-test-pattern-WxH.jpg (-format の形式) に保存する。フォント、間隔、色などを実際の写真なしで確認できる。
+*/
+
+// -h に表示しないオプション
+var HIDDEN_FLAGS = map[string]bool{
+	"test-pattern": true,
+}
+
+const TEST_PATTERN_CHECKERS = 16 // 短い辺に並べる市松模様の数
+
+// テストパターンのExif情報
+var TEST_PATTERN_EXIF = ExifData{
+	Make:                    "EXIFRAME",
+	Model:                   "TEST PATTERN",
+	LensMake:                "EXIFRAME",
+	LensModel:               "TEST 24-70mm F2.8",
+	ExposureTime:            "1/250",
+	FNumber:                 "2.8",
+	PhotographicSensitivity: "400",
+	FocalLengthIn35mmFilm:   "50",
+	FocalLength:             "50",
+	DateTimeOriginal:        "2024/01/01 12:00",
+	Orientation:             "1",
+}
+
+// HIDDEN_FLAGS を除いてオプションの一覧を表示する
+func usageWithoutHidden() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage of %s:\n", os.Args[0])
+
+	visible := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	visible.SetOutput(out)
+	flag.VisitAll(func(f *flag.Flag) {
+		if !HIDDEN_FLAGS[f.Name] {
+			visible.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	visible.PrintDefaults()
+}
+
+// -test-pattern の値 ("WxH") を幅と高さにする
+func parseTestPatternSize(value string) (int, int, error) {
+	w, h, ok := strings.Cut(strings.ToLower(value), "x")
+	width, err1 := strconv.Atoi(w)
+	height, err2 := strconv.Atoi(h)
+	if !ok || err1 != nil || err2 != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid test pattern size: %q (e.g. 6000x4000)", value)
+	}
+	return width, height, nil
+}
+
+// 左右と上下で色が変わるグラデーションに、少し暗くした市松模様を重ねた画像
+func testPatternImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	cell := max(min(width, height)/TEST_PATTERN_CHECKERS, 1)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b := 255*x/max(width-1, 1), 255*y/max(height-1, 1), 255-255*x/max(width-1, 1)
+			if (x/cell+y/cell)%2 == 1 {
+				r, g, b = r*3/4, g*3/4, b*3/4
+			}
+			img.SetRGBA(x, y, color.RGBA{uint8(r), uint8(g), uint8(b), 255})
+		}
+	}
+	return img
+}
+
+// テストパターンにフレームを付けて保存する
+func writeTestPattern(value string, config *Config) ([]string, error) {
+	width, height, err := parseTestPatternSize(value)
+	if err != nil {
+		return nil, err
+	}
+
+	exifData := TEST_PATTERN_EXIF
+	if config.manual != nil {
+		applyManualExif(&exifData, config.manual)
+	}
+	config.exifData = &exifData
+	config.fileName = fmt.Sprintf("test-pattern-%dx%d.png", width, height)
+
+	// 写真と同じように読み込みから処理する
+	var buf bytes.Buffer
+	err = png.Encode(&buf, testPatternImage(width, height))
+	if err != nil {
+		return nil, fmt.Errorf("encoding test pattern: %w", err)
+	}
+
+	dst, _, err := composeFrame(buf.Bytes(), config, &exifData)
+	if err != nil {
+		return nil, err
+	}
+
+	return writeOutputs(dst, config)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"testing"
+)
+
+func TestParseTestPatternSize(t *testing.T) {
+	tests := []struct {
+		value         string
+		width, height int
+		wantErr       bool
+	}{
+		{"6000x4000", 6000, 4000, false},
+		{"640X480", 640, 480, false},
+		{"640", 0, 0, true},
+		{"0x480", 0, 0, true},
+		{"640x-1", 0, 0, true},
+		{"wxh", 0, 0, true},
+	}
+	for _, tt := range tests {
+		width, height, err := parseTestPatternSize(tt.value)
+		if (err != nil) != tt.wantErr || width != tt.width || height != tt.height {
+			t.Errorf("parseTestPatternSize(%q) = %d, %d, %v, want %d, %d (error %v)", tt.value, width, height, err, tt.width, tt.height, tt.wantErr)
+		}
+	}
+}
+
+func TestTestPatternImage(t *testing.T) {
+	img := testPatternImage(320, 160)
+	cell := 160 / TEST_PATTERN_CHECKERS
+
+	tests := []struct {
+		name string
+		x, y int
+		want color.RGBA
+	}{
+		{"top left", 0, 0, color.RGBA{0, 0, 255, 255}},
+		{"top right", 319, 0, color.RGBA{255 * 3 / 4, 0, 0, 255}},
+		{"bottom left", 0, 159, color.RGBA{0, 255 * 3 / 4, 255 * 3 / 4, 255}},
+		// 市松模様の暗いマス
+		{"dark cell", cell, 0, color.RGBA{uint8(255 * cell / 319 * 3 / 4), 0, uint8((255 - 255*cell/319) * 3 / 4), 255}},
+	}
+	for _, tt := range tests {
+		if got := img.RGBAAt(tt.x, tt.y); got != tt.want {
+			t.Errorf("%s pixel = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// テストパターンは通常と同じ処理でフレームを付けて保存する
+func TestWriteTestPattern(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := testConfig(t)
+	outputs, err := writeTestPattern("600x400", config)
+	if err != nil {
+		t.Fatalf("writeTestPattern: %v", err)
+	}
+	if len(outputs) != 1 || outputs[0] != "exiframe-test-pattern-600x400.jpg" {
+		t.Fatalf("outputs = %v", outputs)
+	}
+
+	f, err := os.Open(outputs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, _, err := image.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exifData := TEST_PATTERN_EXIF
+	width, height, err := OutputSize(600, 400, &exifData, testConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Width != width || got.Height != height {
+		t.Errorf("size = %dx%d, want %dx%d", got.Width, got.Height, width, height)
+	}
+
+	if _, err := writeTestPattern("600", testConfig(t)); err == nil {
+		t.Error("writeTestPattern with an invalid size succeeded, want error")
+	}
+}
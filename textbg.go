@@ -0,0 +1,65 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// -text-background の値
+const (
+	TEXT_BACKGROUND_AUTO = "auto" // 文字の下の色が一様でない場合のみ下地を塗る
+	TEXT_BACKGROUND_OFF  = "off"
+)
+
+// 文字の下地の余白 (ピクセル)
+const TEXT_BACKGROUND_PADDING = 8
+
+// 文字のアンチエイリアスは描画先の色と混ざるため、下の色が途中で変わると縁に色ずれが出る
+// (-background の画像や -band-only の枠の色の境目など)
+// その場合は文字を描画する前に、その範囲の平均の色で一様な下地を塗る
+// -band-blur のぼかした背景は色が滑らかに変わり色ずれが目立たないため、下地は塗らない
+func drawTextBackground(dst *image.RGBA, line LayoutLine, config *Config) {
+	if config.textBackground == TEXT_BACKGROUND_OFF || config.bandBlur || line.Rotate != "" || line.face == nil {
+		return
+	}
+
+	metrics := line.face.Metrics()
+	r := image.Rect(
+		line.X-TEXT_BACKGROUND_PADDING, line.Y-metrics.Ascent.Ceil()-TEXT_BACKGROUND_PADDING,
+		line.X+line.Width+TEXT_BACKGROUND_PADDING, line.Y+metrics.Descent.Ceil()+TEXT_BACKGROUND_PADDING,
+	).Intersect(dst.Bounds())
+	if r.Empty() {
+		return
+	}
+
+	uniform, average := regionColor(dst, r)
+	if uniform {
+		return
+	}
+
+	draw.Draw(dst, r, image.NewUniform(average), image.Point{}, draw.Src)
+}
+
+// 範囲の色が一様かどうかと平均の色
+func regionColor(img *image.RGBA, r image.Rectangle) (bool, color.RGBA) {
+	first := img.RGBAAt(r.Min.X, r.Min.Y)
+	uniform := true
+
+	var sum [4]int
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			if c != first {
+				uniform = false
+			}
+			sum[0] += int(c.R)
+			sum[1] += int(c.G)
+			sum[2] += int(c.B)
+			sum[3] += int(c.A)
+		}
+	}
+
+	n := r.Dx() * r.Dy()
+	return uniform, color.RGBA{uint8(sum[0] / n), uint8(sum[1] / n), uint8(sum[2] / n), uint8(sum[3] / n)}
+}
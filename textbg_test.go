@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"golang.org/x/image/font"
+)
+
+// 左右で色の変わる画像 (-band-only の枠の色の境目を再現する)
+func testSplitImage(width, height int, left, right color.Color) *image.RGBA {
+	img := testImage(width, height, left)
+	draw.Draw(img, image.Rect(width/2, 0, width, height), image.NewUniform(right), image.Point{}, draw.Src)
+	return img
+}
+
+func testLine(t testing.TB, text string, x, y int) LayoutLine {
+	t.Helper()
+
+	faces, err := loadFaces(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	face := faces.face("regular", 60)
+	return LayoutLine{Text: text, X: x, Y: y, Width: font.MeasureString(face, text).Ceil(), face: face}
+}
+
+func TestDrawTextBackground(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+
+	tests := []struct {
+		name           string
+		dst            *image.RGBA
+		textBackground string
+		bandBlur       bool
+		want           bool // 下地を塗るか
+	}{
+		{"uniform", testImage(400, 120, red), TEXT_BACKGROUND_AUTO, false, false},
+		{"split", testSplitImage(400, 120, red, blue), TEXT_BACKGROUND_AUTO, false, true},
+		{"off", testSplitImage(400, 120, red, blue), TEXT_BACKGROUND_OFF, false, false},
+		{"band-blur", testSplitImage(400, 120, red, blue), TEXT_BACKGROUND_AUTO, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig(t)
+			config.textBackground = tt.textBackground
+			config.bandBlur = tt.bandBlur
+
+			before := image.NewRGBA(tt.dst.Bounds())
+			draw.Draw(before, before.Bounds(), tt.dst, image.Point{}, draw.Src)
+
+			line := testLine(t, "ILCE-7M4", 60, 80)
+			drawTextBackground(tt.dst, line, config)
+
+			filled := !bytes.Equal(before.Pix, tt.dst.Pix)
+			if filled != tt.want {
+				t.Fatalf("filled = %v, want %v", filled, tt.want)
+			}
+			if !filled {
+				return
+			}
+
+			metrics := line.face.Metrics()
+			r := image.Rect(
+				line.X-TEXT_BACKGROUND_PADDING, line.Y-metrics.Ascent.Ceil()-TEXT_BACKGROUND_PADDING,
+				line.X+line.Width+TEXT_BACKGROUND_PADDING, line.Y+metrics.Descent.Ceil()+TEXT_BACKGROUND_PADDING,
+			).Intersect(tt.dst.Bounds())
+			if uniform, _ := regionColor(tt.dst, r); !uniform {
+				t.Errorf("backing %v is not uniform", r)
+			}
+			if _, average := regionColor(before, r); tt.dst.RGBAAt(r.Min.X, r.Min.Y) != average {
+				t.Errorf("backing = %v, want the average %v", tt.dst.RGBAAt(r.Min.X, r.Min.Y), average)
+			}
+		})
+	}
+}
+
+// 色の境目をまたぐ文字の縁に、下の色 (赤や青) が混ざらない
+func TestTextBackgroundFringe(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+
+	for _, textBackground := range []string{TEXT_BACKGROUND_AUTO, TEXT_BACKGROUND_OFF} {
+		config := testConfig(t)
+		config.textBackground = textBackground
+		config.textColor = image.Black
+
+		dst := testSplitImage(400, 120, red, blue)
+		line := testLine(t, "ILCE-7M4", 60, 80)
+		drawTextBackground(dst, line, config)
+		backing := dst.RGBAAt(line.X-TEXT_BACKGROUND_PADDING, line.Y)
+		drawLine(dst, line, config)
+
+		// 黒い文字と一様な下地だけなら、どの画素も下地と赤と青の割合が同じになる
+		fringe := 0
+		bounds := font.MeasureString(line.face, line.Text).Ceil()
+		for y := line.Y - line.face.Metrics().Ascent.Ceil(); y < line.Y+line.face.Metrics().Descent.Ceil(); y++ {
+			for x := line.X; x < line.X+bounds; x++ {
+				c := dst.RGBAAt(x, y)
+				if absDiff(uint8(int(c.R)*int(backing.B)/255), uint8(int(c.B)*int(backing.R)/255)) > 1 {
+					fringe++
+				}
+			}
+		}
+
+		if textBackground == TEXT_BACKGROUND_AUTO && fringe != 0 {
+			t.Errorf("auto: %d pixels with a color fringe, want 0", fringe)
+		}
+		if textBackground == TEXT_BACKGROUND_OFF && fringe == 0 {
+			t.Error("off: no pixels keep the colors under the text")
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+/*
+# テーマ (-theme)
+
+フレームの色、文字色、レイアウト、余白をまとめた設定。コマンドラインで指定したオプションはテーマの値より優先する。
+*/
+
+type Theme struct {
+	black      bool   // -black
+	frameColor string // フレームの色 ("#rrggbb"、空の場合は -black に従う)
+	textColor  string // 文字色 ("#rrggbb"、空の場合は -black に従う)
+	layoutSpec string // -layout-spec のJSON (空の場合は既定のレイアウト)
+	frameInset int    // -frame-inset
+	insetColor string // -inset-color
+}
+
+const DEFAULT_THEME = "classic-white"
+
+var THEMES = map[string]Theme{
+	"classic-white": {},
+	"classic-black": {
+		black: true,
+	},
+	"minimal": {
+		layoutSpec: `{
+			"band_height": 360,
+			"lines": [
+				{"name": "exposure", "text": "${FocalLengthIn35mmFilm}mm  f/${FNumber}  ${ExposureTime}s  ISO${PhotographicSensitivity}", "font": "regular", "size": 120, "align": "center", "y": 150}
+			]
+		}`,
+	},
+	"polaroid": {
+		frameColor: "#f4f1ea",
+		textColor:  "#3a3a3a",
+		layoutSpec: `{
+			"band_height": 900,
+			"lines": [
+				{"name": "camera", "text": "${Camera}", "font": "bold", "size": 180, "align": "center", "y": 330},
+				{"name": "date", "text": "${DateTimeOriginal}", "font": "regular", "size": 130, "align": "center", "y": 560},
+				{"name": "comment", "text": "${UserComment}", "font": "regular", "size": 130, "align": "center", "y": 730, "optional": true}
+			]
+		}`,
+	},
+	"film": {
+		black:      true,
+		textColor:  "#f2a33a",
+		frameInset: 40,
+		insetColor: "#1c1c1c",
+	},
+}
+
+// テーマの名前の一覧 (ヘルプ用)
+func themeNames() string {
+	var names []string
+	for name := range THEMES {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return strings.Join(names, ", ")
+}
+
+// テーマの値をconfigに設定する
+// isSetがtrueを返すオプション (コマンドラインやクエリで指定されたもの) は上書きしない
+func applyTheme(config *Config, name string, isSet func(name string) bool) error {
+	theme, ok := THEMES[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q (available: %s)", name, themeNames())
+	}
+
+	if !isSet("black") {
+		config.frameColorBlack = theme.black
+
+		var err error
+		if theme.frameColor != "" {
+			config.themeFrameColor, err = parseHexColor(theme.frameColor)
+			if err != nil {
+				return err
+			}
+		}
+		if theme.textColor != "" {
+			config.themeTextColor, err = parseHexColor(theme.textColor)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !isSet("layout-spec") && theme.layoutSpec != "" {
+		spec, err := parseLayoutSpec([]byte(theme.layoutSpec))
+		if err != nil {
+			return fmt.Errorf("theme %q: %w", name, err)
+		}
+		config.layoutSpec = spec
+	}
+
+	if !isSet("frame-inset") {
+		config.frameInset = theme.frameInset
+	}
+
+	if !isSet("inset-color") && theme.insetColor != "" {
+		insetColor, err := parseHexColor(theme.insetColor)
+		if err != nil {
+			return err
+		}
+		config.insetColor = insetColor
+	}
+
+	return nil
+}
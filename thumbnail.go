@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+/*
+# 埋め込みのサムネイル (-extract-thumb)
+
+Exifの IFD1 に入っているJPEGのサムネイルを取り出して exiframe-*-thumb.jpg に保存する。
+サムネイルの向きは本体と異なる場合があるため、IFD1 の Orientation (無ければ IFD0 の Orientation) に従って正位置にしてから保存する。
+回転が不要な場合は取り出したJPEGをそのまま保存する。
+*/
+
+// -extract-thumb の出力ファイル名 (exiframe-*-thumb.jpg)
+func thumbPath(fileName string) string {
+	return FILE_NAME_PREFIX + strings.TrimSuffix(fileName, filepath.Ext(fileName)) + "-thumb.jpg"
+}
+
+// IFD1 のサムネイルとその Orientation を取り出す
+func extractThumbnail(rawExif []byte) ([]byte, int, error) {
+	im, err := exifcommon.NewIfdMappingWithStandard()
+	if err != nil {
+		return nil, 0, fmt.Errorf("NewIfdMappingWithStandard: %w", err)
+	}
+
+	_, index, err := exif.Collect(im, exif.NewTagIndex(), rawExif)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Collect: %w", err)
+	}
+
+	ifd1 := index.RootIfd.NextIfd()
+	if ifd1 == nil {
+		return nil, 0, fmt.Errorf("no thumbnail")
+	}
+
+	thumb, err := ifd1.Thumbnail()
+	if err != nil {
+		return nil, 0, fmt.Errorf("no thumbnail: %w", err)
+	}
+
+	orientation := ifdOrientation(ifd1)
+	if orientation == 0 {
+		orientation = ifdOrientation(index.RootIfd)
+	}
+
+	return thumb, orientation, nil
+}
+
+// -extract-thumb のサムネイルを保存する
+func writeThumbnail(config *Config) (string, error) {
+	thumb, orientation, err := extractThumbnail(config.rawExif)
+	if err != nil {
+		return "", decodeError(fmt.Errorf("extract-thumb: %w", err))
+	}
+
+	if orientation > 1 {
+		img, err := imaging.Decode(bytes.NewReader(thumb))
+		if err != nil {
+			return "", decodeError(fmt.Errorf("extract-thumb: decoding thumbnail: %w", err))
+		}
+
+		var buf bytes.Buffer
+		err = jpeg.Encode(&buf, applyOrientation(img, orientation), &jpeg.Options{Quality: 100})
+		if err != nil {
+			return "", fmt.Errorf("extract-thumb: encoding thumbnail: %w", err)
+		}
+		thumb = buf.Bytes()
+	}
+
+	dstPath := thumbPath(config.fileName)
+	err = os.WriteFile(dstPath, thumb, 0666)
+	if err != nil {
+		return "", ioError(fmt.Errorf("extract-thumb: creating file: %w", err))
+	}
+
+	return dstPath, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"os"
+	"testing"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+// 左半分が白、右半分が黒の 40x20 のサムネイル
+func testThumbnail(t testing.TB) []byte {
+	t.Helper()
+
+	img := testImage(40, 20, color.Black)
+	draw.Draw(img, image.Rect(0, 0, 20, 20), image.White, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// IFD0 と IFD1 (サムネイル) にそれぞれの Orientation を持つExif (0の場合はタグを書かない)
+func testThumbnailExif(t testing.TB, orientation, thumbOrientation uint16, thumb []byte) []byte {
+	t.Helper()
+
+	im, err := exifcommon.NewIfdMappingWithStandard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ti := exif.NewTagIndex()
+
+	root := exif.NewIfdBuilder(im, ti, exifcommon.IfdStandardIfdIdentity, binary.BigEndian)
+	ifd1 := exif.NewIfdBuilder(im, ti, exifcommon.Ifd1StandardIfdIdentity, binary.BigEndian)
+	for ib, value := range map[*exif.IfdBuilder]uint16{root: orientation, ifd1: thumbOrientation} {
+		if value == 0 {
+			continue
+		}
+		if err := ib.AddStandardWithName("Orientation", []uint16{value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ifd1.SetThumbnail(thumb); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetNextIb(ifd1); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := exif.NewIfdByteEncoder().EncodeToExif(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// サムネイルの向きが本体と異なっても、保存したサムネイルは正位置になる
+func TestWriteThumbnailUpright(t *testing.T) {
+	thumb := testThumbnail(t)
+
+	tests := []struct {
+		name                          string
+		orientation, thumbOrientation uint16
+		rotated                       bool
+	}{
+		{"thumbnail rotated", 1, 6, true},
+		{"main rotated", 6, 1, false},
+		{"thumbnail without orientation", 6, 0, true},
+		{"upright", 1, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+
+			config := testConfig(t)
+			config.fileName = "photo.jpg"
+			config.rawExif = testThumbnailExif(t, tt.orientation, tt.thumbOrientation, thumb)
+
+			path, err := writeThumbnail(config)
+			if err != nil {
+				t.Fatalf("writeThumbnail: %v", err)
+			}
+			if path != "exiframe-photo-thumb.jpg" {
+				t.Errorf("path = %q", path)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.rotated {
+				// 回転が不要な場合は取り出したJPEGのまま
+				if !bytes.Equal(data, thumb) {
+					t.Error("thumbnail was re-encoded, want the embedded JPEG as is")
+				}
+				return
+			}
+
+			img, err := jpeg.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 右に90度回すと、左半分の白は上半分になる
+			if b := img.Bounds(); b.Dx() != 20 || b.Dy() != 40 {
+				t.Fatalf("size = %dx%d, want 20x40", b.Dx(), b.Dy())
+			}
+			top, _, _, _ := img.At(10, 5).RGBA()
+			bottom, _, _, _ := img.At(10, 35).RGBA()
+			if top < 0xf000 || bottom > 0x1000 {
+				t.Errorf("top, bottom = %#x, %#x, want white above black", top, bottom)
+			}
+		})
+	}
+}
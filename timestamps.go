@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// -keep-timestamps の場合に出力ファイルに付ける日時
+// 元のファイルの更新日時、-timestamp-from-exif の場合は撮影日時 (読めない場合は元のファイルの更新日時)
+// -in-place では元のファイルを置き換えるため、書き込む前に呼ぶ
+func outputTimestamp(exifData *ExifData, config *Config) (time.Time, error) {
+	if config.exifTimestamp {
+		t, err := captureTime(exifData)
+		if err == nil {
+			return t, nil
+		}
+		logWarn("Using file modification time:", err)
+	}
+
+	info, err := os.Stat(config.filePath)
+	if err != nil {
+		return time.Time{}, ioError(fmt.Errorf("reading file time: %w", err))
+	}
+	return info.ModTime(), nil
+}
+
+// DateTimeOriginal の撮影日時
+// OffsetTimeOriginal があればそのタイムゾーン、無ければローカル時刻として読む
+func captureTime(exifData *ExifData) (time.Time, error) {
+	loc := time.Local
+	if offset, err := time.Parse("-07:00", exifData.OffsetTimeOriginal); err == nil {
+		_, seconds := offset.Zone()
+		loc = time.FixedZone(exifData.OffsetTimeOriginal, seconds)
+	}
+
+	for _, l := range DATE_TIME_LAYOUTS {
+		t, err := time.ParseInLocation(l.output, exifData.DateTimeOriginal, loc)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unsupported date and time: %q", exifData.DateTimeOriginal)
+}
+
+// 出力ファイルのアクセス日時と更新日時を設定する
+func setTimestamps(paths []string, t time.Time) error {
+	for _, path := range paths {
+		err := os.Chtimes(path, t, t)
+		if err != nil {
+			return ioError(fmt.Errorf("setting file time: %w", err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// 撮影日時のタイムゾーン ("+09:00" の形式)
+// OffsetTimeOriginalが無い場合は、撮影日時 (現地時刻) とGPS時刻 (UTC) の差から推定する
+// 推定できない場合は空文字列を返す
+func captureTimeZone(exifData *ExifData) string {
+	if exifData.OffsetTimeOriginal != "" {
+		return exifData.OffsetTimeOriginal
+	}
+
+	local, err := time.Parse("2006/01/02 15:04", exifData.DateTimeOriginal)
+	if err != nil {
+		return ""
+	}
+
+	// GPSDateStampが無い場合は時刻のみのため、撮影日と同じ日として差を求める
+	utc, err := time.Parse("2006/01/02 15:04", exifData.GPSDateTime)
+	if err != nil {
+		t, err := time.Parse("15:04", exifData.GPSDateTime)
+		if err != nil {
+			return ""
+		}
+		utc = time.Date(local.Year(), local.Month(), local.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+	}
+
+	// 15分単位に丸め、時刻のみの場合は日付をまたぐ差を補正する
+	offset := local.Sub(utc).Round(15 * time.Minute)
+	if exifData.GPSDateTime == utc.Format("15:04") {
+		for offset > 14*time.Hour {
+			offset -= 24 * time.Hour
+		}
+		for offset < -12*time.Hour {
+			offset += 24 * time.Hour
+		}
+	}
+
+	// UTC-12:00 から UTC+14:00 以外はGPS時刻が正しくないものとする
+	if offset < -12*time.Hour || offset > 14*time.Hour {
+		return ""
+	}
+
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+
+	return fmt.Sprintf("%s%02d:%02d", sign, int(offset.Hours()), int(offset.Minutes())%60)
+}
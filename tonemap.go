@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+)
+
+/*
+# HDR画像のトーンマッピング (-tonemap)
+
+HDRで書き出したPNG (cICP チャンクの伝達特性が PQ または HLG) は、そのまま8ビットのsRGBとして扱うと暗く眠い画像になり、
+明るい部分の階調も失われる。-tonemap の場合は画素を輝度 (cd/m²) に戻し、拡張 Reinhard で SDR の範囲に収めてからsRGBにする。
+
+チャンネルごとの単純な変換で、色域の変換やメタデータ (マスタリング輝度など) は扱わない。完全なHDRの処理ではない。
+*/
+
+// cICP の伝達特性
+const (
+	TRANSFER_PQ  = 16 // SMPTE ST 2084
+	TRANSFER_HLG = 18 // ARIB STD-B67
+)
+
+const (
+	TONEMAP_REFERENCE_WHITE = 203.0   // SDRの白に対応する輝度 (ITU-R BT.2408)
+	PQ_PEAK_LUMINANCE       = 10000.0 // PQ の最大輝度
+	HLG_PEAK_LUMINANCE      = 1000.0  // HLG の公称の最大輝度
+)
+
+// PNGの cICP チャンクの伝達特性 (無い場合やPNGでない場合は0)
+func pngTransfer(data []byte) int {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return 0
+	}
+
+	for pos := len(signature); pos+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		chunkType := string(data[pos+4 : pos+8])
+		if length < 0 || pos+12+length > len(data) || chunkType == "IDAT" {
+			return 0
+		}
+		if chunkType == "cICP" && length >= 2 {
+			return int(data[pos+9])
+		}
+		pos += 12 + length
+	}
+
+	return 0
+}
+
+// HDRの画像をSDRに変換する (HDRでない場合はそのまま返す)
+func tonemapImage(img image.Image, data []byte) image.Image {
+	var toLuminance func(float64) float64
+	var peak float64
+	switch pngTransfer(data) {
+	case TRANSFER_PQ:
+		toLuminance, peak = pqLuminance, PQ_PEAK_LUMINANCE
+	case TRANSFER_HLG:
+		toLuminance, peak = hlgLuminance, HLG_PEAK_LUMINANCE
+	default:
+		return img
+	}
+
+	// チャンネルごとに同じ変換をするため、16ビットの値ごとの表を作る
+	lut := make([]uint8, 1<<16)
+	whitePoint := peak / TONEMAP_REFERENCE_WHITE
+	for v := range lut {
+		l := toLuminance(float64(v)/0xffff) / TONEMAP_REFERENCE_WHITE
+		// 拡張 Reinhard (最大輝度がちょうど1になる)
+		mapped := l * (1 + l/(whitePoint*whitePoint)) / (1 + l)
+		lut[v] = uint8(math.Round(srgbEncode(min(mapped, 1)) * 0xff))
+	}
+
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+			dst.SetNRGBA(x-b.Min.X, y-b.Min.Y, color.NRGBA{lut[c.R], lut[c.G], lut[c.B], uint8(c.A >> 8)})
+		}
+	}
+
+	return dst
+}
+
+// PQ の信号値 (0〜1) を輝度にする
+func pqLuminance(e float64) float64 {
+	const (
+		m1 = 2610.0 / 16384
+		m2 = 2523.0 / 4096 * 128
+		c1 = 3424.0 / 4096
+		c2 = 2413.0 / 4096 * 32
+		c3 = 2392.0 / 4096 * 32
+	)
+	p := math.Pow(e, 1/m2)
+	return PQ_PEAK_LUMINANCE * math.Pow(max(p-c1, 0)/(c2-c3*p), 1/m1)
+}
+
+// HLG の信号値 (0〜1) を輝度にする (OOTF はガンマ1.2の簡易的なもの)
+func hlgLuminance(e float64) float64 {
+	const (
+		a = 0.17883277
+		b = 0.28466892
+		c = 0.55991073
+	)
+	var scene float64
+	if e <= 0.5 {
+		scene = e * e / 3
+	} else {
+		scene = (math.Exp((e-c)/a) + b) / 12
+	}
+	return HLG_PEAK_LUMINANCE * math.Pow(scene, 1.2)
+}
+
+// 線形の値 (0〜1) をsRGBの信号値にする
+func srgbEncode(v float64) float64 {
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
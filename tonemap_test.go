@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+)
+
+// 輝度 (cd/m²) を PQ の信号値 (0〜1) にする
+func testPqSignal(l float64) float64 {
+	const (
+		m1 = 2610.0 / 16384
+		m2 = 2523.0 / 4096 * 128
+		c1 = 3424.0 / 4096
+		c2 = 2413.0 / 4096 * 32
+		c3 = 2392.0 / 4096 * 32
+	)
+	y := math.Pow(l/PQ_PEAK_LUMINANCE, m1)
+	return math.Pow((c1+c2*y)/(1+c3*y), m2)
+}
+
+// 1行に輝度を並べた16ビットのHDR PNG (transfer が0の場合は cICP チャンクを付けない)
+func testHdrPng(t testing.TB, transfer int, luminances []float64) []byte {
+	t.Helper()
+
+	img := image.NewRGBA64(image.Rect(0, 0, len(luminances), 1))
+	for x, l := range luminances {
+		v := uint16(math.Round(testPqSignal(l) * 0xffff))
+		img.SetRGBA64(x, 0, color.RGBA64{v, v, v, 0xffff})
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if transfer == 0 {
+		return buf.Bytes()
+	}
+	// BT.2020, 伝達特性, 行列なし (RGB), フルレンジ
+	return testPngChunk(buf.Bytes(), "cICP", []byte{9, byte(transfer), 0, 1})
+}
+
+func TestPngTransfer(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"pq", testHdrPng(t, TRANSFER_PQ, []float64{100}), TRANSFER_PQ},
+		{"hlg", testHdrPng(t, TRANSFER_HLG, []float64{100}), TRANSFER_HLG},
+		{"sdr", testHdrPng(t, 0, []float64{100}), 0},
+		{"jpeg", testJpeg(t, 4, 4), 0},
+	}
+	for _, tt := range tests {
+		if got := pngTransfer(tt.data); got != tt.want {
+			t.Errorf("%s: pngTransfer = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// PQ で記録した明るい部分は白に飛ばず、階調を残して SDR の範囲に収まる
+func TestTonemapPq(t *testing.T) {
+	luminances := []float64{0, 1, 50, TONEMAP_REFERENCE_WHITE, 400, 1000, 2000, 4000, PQ_PEAK_LUMINANCE}
+	data := testHdrPng(t, TRANSFER_PQ, luminances)
+
+	src, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := tonemapImage(src, data)
+
+	levels := make([]uint8, len(luminances))
+	for x := range luminances {
+		c := color.NRGBAModel.Convert(dst.At(x, 0)).(color.NRGBA)
+		if c.R != c.G || c.G != c.B || c.A != 0xff {
+			t.Errorf("%g cd/m²: pixel = %v, want an opaque gray", luminances[x], c)
+		}
+		levels[x] = c.R
+	}
+
+	if levels[0] != 0 || levels[len(levels)-1] != 0xff {
+		t.Errorf("black and peak = %d, %d, want 0, 255", levels[0], levels[len(levels)-1])
+	}
+	// 明るくなるほど値が大きくなり、1000 cd/m² 以上の部分も区別できる
+	for i := 1; i < len(levels); i++ {
+		if levels[i] <= levels[i-1] {
+			t.Errorf("%g cd/m² = %d, want brighter than %g cd/m² = %d", luminances[i], levels[i], luminances[i-1], levels[i-1])
+		}
+	}
+	// SDRの白 (203 cd/m²) は中間より明るく、飛ばない
+	if white := levels[3]; white < 0x80 || white == 0xff {
+		t.Errorf("reference white = %d, want between 128 and 254", white)
+	}
+}
+
+// cICP の無い画像はそのまま返す
+func TestTonemapSdr(t *testing.T) {
+	data := testHdrPng(t, 0, []float64{100, 1000})
+	src, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst := tonemapImage(src, data); dst != src {
+		t.Error("tonemapImage changed an SDR image")
+	}
+}
+
+// -tonemap を指定した場合のみ loadImage で変換する
+func TestLoadImageTonemap(t *testing.T) {
+	data := testHdrPng(t, TRANSFER_PQ, []float64{TONEMAP_REFERENCE_WHITE})
+	src, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// PQ の信号値のまま (約0.58) か、トーンマッピングした値か
+	wants := map[bool]uint8{
+		false: uint8(math.Round(testPqSignal(TONEMAP_REFERENCE_WHITE) * 0xff)),
+		true:  color.NRGBAModel.Convert(tonemapImage(src, data).At(0, 0)).(color.NRGBA).R,
+	}
+
+	for _, tonemap := range []bool{false, true} {
+		config := testConfig(t)
+		config.tonemap = tonemap
+
+		img, err := loadImage(data, config)
+		if err != nil {
+			t.Fatalf("loadImage: %v", err)
+		}
+		got := color.NRGBAModel.Convert(img.At(0, 0)).(color.NRGBA).R
+		if absDiff(got, wants[tonemap]) > 1 {
+			t.Errorf("tonemap %v: pixel = %d, want %d", tonemap, got, wants[tonemap])
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/disintegration/imaging"
+)
+
+// -trim-tolerance の既定値 (0から255)
+const TRIM_TOLERANCE = 8
+
+// 画像の周囲の余白を切り取る (-trim)
+// 透過のある画像は不透明度が tolerance を超える画素、それ以外は左上の画素の色との差が tolerance を超える画素を内容とする
+// 内容が無い場合はそのまま返す
+func trimImage(img image.Image, tolerance int) image.Image {
+	b := img.Bounds()
+	alpha := hasAlpha(img)
+	bg := color.NRGBAModel.Convert(img.At(b.Min.X, b.Min.Y)).(color.NRGBA)
+
+	isContent := func(x, y int) bool {
+		c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+		if alpha {
+			return int(c.A) > tolerance
+		}
+		return absDiff(c.R, bg.R) > tolerance || absDiff(c.G, bg.G) > tolerance || absDiff(c.B, bg.B) > tolerance
+	}
+
+	content := image.Rectangle{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if isContent(x, y) {
+				content = content.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+
+	if content.Empty() || content == b {
+		return img
+	}
+
+	return imaging.Crop(img, content)
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+)
+
+// 日時のタグの長さ (NULを含む)
+const DATE_TIME_LENGTH = 20
+
+var (
+	DATE_TIME_PATTERN = regexp.MustCompile(`^\d{4}:\d{2}:\d{2} \d{2}:\d{2}:\d{2}$`)
+
+	// 日時不明の場合はコロン以外を空白で埋めるか、全てを空白で埋める
+	UNKNOWN_DATE_TIME_PATTERN = regexp.MustCompile(`^(    :  :     :  :  |                   )$`)
+
+	// 日時のタグ
+	DATE_TIME_TAGS = []struct {
+		name  string
+		tagId uint16
+		path  string
+	}{
+		{"DateTime", 0x0132, IFD_PATH},
+		{"DateTimeOriginal", 0x9003, EXIF_IFD_PATH},
+		{"DateTimeDigitized", 0x9004, EXIF_IFD_PATH},
+	}
+
+	// 正の値であるべき有理数のタグ
+	POSITIVE_RATIONAL_TAGS = []string{"ExposureTime", "FNumber", "FocalLength"}
+)
+
+// 各ファイルのExifを検査し、規格に合わない箇所を表示する
+// 違反が1つでもあればfalseを返す
+func validateFiles(paths []string, config *Config) bool {
+	valid := true
+	for _, path := range paths {
+		c := *config
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logError("Error opening file:", err)
+			valid = false
+			continue
+		}
+
+		_, err = getExif(data, &c)
+		if err != nil {
+			logError(fmt.Sprintf("%s: Error %v", path, err))
+			valid = false
+			continue
+		}
+
+		violations, err := validateExif(c.rawExif)
+		if err != nil {
+			logError(fmt.Sprintf("%s: Error %v", path, err))
+			valid = false
+			continue
+		}
+
+		if len(violations) == 0 {
+			fmt.Printf("%s: OK\n", path)
+			continue
+		}
+
+		valid = false
+		for _, v := range violations {
+			fmt.Printf("%s: %s\n", path, v)
+		}
+	}
+
+	return valid
+}
+
+// CIPA DC-008 の基本的な要件を検査し、違反の一覧を返す
+func validateExif(rawExif []byte) ([]string, error) {
+	im, err := exifcommon.NewIfdMappingWithStandard()
+	if err != nil {
+		return nil, fmt.Errorf("NewIfdMappingWithStandard: %w", err)
+	}
+
+	ti := exif.NewTagIndex()
+
+	_, index, err := exif.Collect(im, ti, rawExif)
+	if err != nil {
+		return nil, fmt.Errorf("Collect: %w", err)
+	}
+
+	var violations []string
+
+	// タグを探す (無い場合はnil)
+	find := func(path string, tagId uint16) (*exif.IfdTagEntry, error) {
+		ifd, err := exif.FindIfdFromRootIfd(index.RootIfd, path)
+		if err != nil {
+			return nil, nil
+		}
+
+		results, err := ifd.FindTagWithId(tagId)
+		if errors.Is(err, exif.ErrTagNotFound) || len(results) == 0 {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("FindTagWithId: %w", err)
+		}
+
+		return results[0], nil
+	}
+
+	// 日時: "YYYY:MM:DD HH:MM:SS" + NUL の20Byte
+	for _, tag := range DATE_TIME_TAGS {
+		entry, err := find(tag.path, tag.tagId)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+
+		if entry.UnitCount() != DATE_TIME_LENGTH {
+			violations = append(violations, fmt.Sprintf("%s: length is %d bytes, expected %d", tag.name, entry.UnitCount(), DATE_TIME_LENGTH))
+		}
+
+		value, err := entry.Value()
+		if err != nil {
+			violations = append(violations, fmt.Sprintf("%s: unreadable value: %v", tag.name, err))
+			continue
+		}
+
+		s, _ := value.(string)
+		if !DATE_TIME_PATTERN.MatchString(s) && !UNKNOWN_DATE_TIME_PATTERN.MatchString(s) {
+			violations = append(violations, fmt.Sprintf("%s: %q is not in \"YYYY:MM:DD HH:MM:SS\" format", tag.name, s))
+		}
+	}
+
+	// 画像の向き: 1〜8
+	info := IFD_PATH_MAP["Orientation"]
+	entry, err := find(info.path, info.tagId)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		value, err := entry.Value()
+		orientation, ok := value.([]uint16)
+		if err != nil || !ok || len(orientation) == 0 || orientation[0] < 1 || orientation[0] > 8 {
+			violations = append(violations, fmt.Sprintf("Orientation: %v is not in 1 to 8", value))
+		}
+	}
+
+	// 露出時間, F値, 焦点距離: 正の値
+	for _, name := range POSITIVE_RATIONAL_TAGS {
+		info := IFD_PATH_MAP[name]
+		entry, err := find(info.path, info.tagId)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+
+		value, err := entry.Value()
+		rationals, ok := value.([]exifcommon.Rational)
+		if err != nil || !ok || len(rationals) == 0 {
+			violations = append(violations, fmt.Sprintf("%s: %v is not a rational value", name, value))
+			continue
+		}
+
+		r := rationals[0]
+		if r.Numerator == 0 || r.Denominator == 0 {
+			violations = append(violations, fmt.Sprintf("%s: %d/%d is not a positive value", name, r.Numerator, r.Denominator))
+		}
+	}
+
+	return violations, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/disintegration/imaging"
+)
+
+/*
+# 縦書きのラベル (-vertical-text)
+
+縦長の画像ではラベルを画像の右側に置き、文字を90度回転して描画する。
+
+- ccw: 反時計回りに回転 (下から上に読む)
+- cw: 時計回りに回転 (上から下に読む)
+
+配置は画像を横長に回転したとして通常のレイアウトを計算し、ラベル領域の座標を右側に写して求める。
+*/
+
+const (
+	VERTICAL_TEXT_CW  = "cw"
+	VERTICAL_TEXT_CCW = "ccw"
+)
+
+// ラベルを右側に置くレイアウトを計算する
+func computeSideLayout(srcWidth, srcHeight int, config *Config, exifData *ExifData, faces *Faces) *Layout {
+	// 横長にした画像の通常のレイアウト
+	v := computeLayout(srcHeight, srcWidth, config, exifData, faces)
+
+	framePixel := v.Image.X - config.frameInset
+	imageWidth := srcWidth + config.frameInset*2
+	imageHeight := srcHeight + config.frameInset*2
+
+	layout := &Layout{
+		Canvas: LayoutRect{0, 0, imageWidth + framePixel + v.Band.Height, v.Canvas.Width},
+		Image:  LayoutRect{framePixel + config.frameInset, framePixel + config.frameInset, srcWidth, srcHeight},
+		Band:   LayoutRect{framePixel + imageWidth, 0, v.Band.Height, v.Canvas.Width},
+	}
+	if v.Inset != nil {
+		layout.Inset = &LayoutRect{framePixel, framePixel, imageWidth, imageHeight}
+	}
+
+	// ラベル領域の点 (x, y) を右側のラベル領域に写す
+	// x は文字の進む向き、y はラベル領域の上端からの距離
+	mapPoint := func(x, y int) (int, int) {
+		y -= v.Band.Y
+		if config.verticalText == VERTICAL_TEXT_CW {
+			return layout.Band.X + layout.Band.Width - y, x
+		}
+		return layout.Band.X + y, layout.Canvas.Height - x
+	}
+
+	for _, line := range v.Lines {
+		line.X, line.Y = mapPoint(line.X, line.Y)
+		line.Rotate = config.verticalText
+		layout.Lines = append(layout.Lines, line)
+	}
+
+	if v.ApertureIcon != nil {
+		icon := *v.ApertureIcon
+		x0, y0 := mapPoint(icon.X, icon.Y)
+		x1, y1 := mapPoint(icon.X+icon.Width, icon.Y+icon.Height)
+		layout.ApertureIcon = &LayoutRect{min(x0, x1), min(y0, y1), icon.Height, icon.Width}
+		layout.fNumber = v.fNumber
+	}
+
+	return layout
+}
+
+// 回転したラベルの1行を描画する
+// 一時的な画像に横書きで描画してから回転し、ベースラインの始点が (line.X, line.Y) になるよう合成する
+func drawRotatedLine(dst draw.Image, line LayoutLine, config *Config) {
+	metrics := line.face.Metrics()
+	ascent, descent := metrics.Ascent.Ceil(), metrics.Descent.Ceil()
+
+	// -text-outline の輪郭がはみ出さないよう余白を取る
+	pad := 0
+	if config.textOutline {
+		pad = config.textOutlineWidth
+	}
+
+	tmp := image.NewRGBA(image.Rect(0, 0, line.Width+pad*2, ascent+descent+pad*2))
+	flat := line
+	flat.X, flat.Y, flat.Rotate = pad, pad+ascent, ""
+	drawLine(tmp, flat, config)
+
+	// 一時的な画像のベースラインの始点 (pad, pad+ascent) の回転後の位置
+	var rotated *image.NRGBA
+	var origin image.Point
+	if line.Rotate == VERTICAL_TEXT_CW {
+		rotated = imaging.Rotate270(tmp)
+		origin = image.Point{tmp.Rect.Dy() - (pad + ascent), pad}
+	} else {
+		rotated = imaging.Rotate90(tmp)
+		origin = image.Point{pad + ascent, tmp.Rect.Dx() - pad}
+	}
+
+	at := image.Point{line.X, line.Y}.Sub(origin)
+	draw.Draw(dst, rotated.Bounds().Add(at), rotated, image.Point{}, draw.Over)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+/*
+# XMPのキーワード (-keywords)
+
+JPEGのXMP (識別子 "http://ns.adobe.com/xap/1.0/" のAPP1セグメント) から dc:subject のキーワードを読み込む。
+-keywords の場合は、キーワードを ", " でつないだ小さな行をラベルの最後の行の下に追加し、幅に収まらない場合は末尾を "…" で省略する。
+XMPが無い画像やキーワードが無い画像では行を追加しない。
+
+XMPは dc:subject の rdf:li だけを読む最小限の解析で、拡張XMP (複数のセグメントに分けたもの) には対応しない。
+*/
+
+const (
+	XMP_HEADER        = "http://ns.adobe.com/xap/1.0/\x00"
+	XMP_DC_NAMESPACE  = "http://purl.org/dc/elements/1.1/"
+	XMP_RDF_NAMESPACE = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+)
+
+const KEYWORDS_SEPARATOR = ", "
+
+// JPEGのXMPパケット (無い場合はnil)
+func findXmpPacket(data []byte) []byte {
+	segments, _, err := splitJpegSegments(data)
+	if err != nil {
+		return nil
+	}
+
+	for _, s := range segments {
+		if s.marker == APP1_MARKER && bytes.HasPrefix(s.data[4:], []byte(XMP_HEADER)) {
+			return s.data[4+len(XMP_HEADER):]
+		}
+	}
+	return nil
+}
+
+// XMPの dc:subject のキーワード
+func xmpKeywords(packet []byte) ([]string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+
+	var keywords []string
+	inSubject, inItem := false, false
+	var item strings.Builder
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return keywords, nil
+		}
+		if err != nil {
+			return keywords, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space == XMP_DC_NAMESPACE && t.Name.Local == "subject" {
+				inSubject = true
+			}
+			if inSubject && t.Name.Space == XMP_RDF_NAMESPACE && t.Name.Local == "li" {
+				inItem = true
+				item.Reset()
+			}
+		case xml.CharData:
+			if inItem {
+				item.Write(t)
+			}
+		case xml.EndElement:
+			if inItem && t.Name.Space == XMP_RDF_NAMESPACE && t.Name.Local == "li" {
+				inItem = false
+				if keyword := strings.TrimSpace(item.String()); keyword != "" {
+					keywords = append(keywords, keyword)
+				}
+			}
+			if t.Name.Space == XMP_DC_NAMESPACE && t.Name.Local == "subject" {
+				inSubject = false
+			}
+		}
+	}
+}
+
+// 画像のXMPのキーワードを ", " でつないだもの (無い場合は空文字列)
+func readKeywords(data []byte) string {
+	packet := findXmpPacket(data)
+	if packet == nil {
+		return ""
+	}
+
+	keywords, err := xmpKeywords(packet)
+	if err != nil {
+		logWarn("Error parsing XMP:", err)
+	}
+	return strings.Join(keywords, KEYWORDS_SEPARATOR)
+}
+
+// ラベルの最後の行の下にキーワードの行を追加し、その分だけラベル領域を広げる
+func addKeywordsLine(layout *Layout, keywords string, leftX, rightX int, faces *Faces) {
+	if keywords == "" {
+		return
+	}
+	appendSmallLine(layout, "keywords", keywords, leftX, rightX, faces)
+}
+
+// ラベルの最後の行の下に通常の大きさの行を追加し、その分だけラベル領域を広げる (-keywords, -exif-field)
+// 幅に収まらない場合は末尾を "…" で省略する
+func appendSmallLine(layout *Layout, name string, line string, leftX, rightX int, faces *Faces) {
+	face := faces.face("regular", FONT_SIZE)
+	text, width := truncateText(face, line, rightX-leftX)
+	// 上の行と詰まらないよう、フォントの高さの1.5倍の位置に置く
+	height := face.Metrics().Height.Ceil() * 3 / 2
+
+	baseline := layout.Band.Y
+	for _, line := range layout.Lines {
+		baseline = max(baseline, line.Y)
+	}
+
+	layout.Lines = append(layout.Lines, LayoutLine{
+		Name:  name,
+		Text:  text,
+		X:     leftX,
+		Y:     baseline + height,
+		Width: width,
+		face:  face,
+	})
+	layout.Canvas.Height += height
+	layout.Band.Height += height
+}
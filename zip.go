@@ -0,0 +1,153 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// zipファイル内の画像にフレームを付ける (-zip)
+// 出力は outDir に保存するか、zipOut が指定された場合は新しいzipファイルにまとめる
+// 画像として読めないエントリは飛ばし、エントリごとの結果を返す (PathはアーカイブのパスとエントリのPath)
+func frameZip(ctx context.Context, zipPath string, outDir string, zipOut string, config *Config) ([]Result, error) {
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, ioError(fmt.Errorf("opening zip: %w", err))
+	}
+	defer archive.Close()
+
+	// 出力先 (ディレクトリかzipファイル)
+	write := func(name string, data []byte) (string, error) {
+		// "../" などで outDir の外に書き込むエントリは拒否する
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return "", fmt.Errorf("invalid entry name: %q", name)
+		}
+
+		dstPath := filepath.Join(outDir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(dstPath), 0777)
+		if err != nil {
+			return "", err
+		}
+		return dstPath, os.WriteFile(dstPath, data, 0666)
+	}
+
+	var zw *zip.Writer
+	if zipOut != "" {
+		f, err := os.Create(zipOut)
+		if err != nil {
+			return nil, ioError(fmt.Errorf("creating zip: %w", err))
+		}
+		defer f.Close()
+
+		zw = zip.NewWriter(f)
+		write = func(name string, data []byte) (string, error) {
+			w, err := zw.Create(name)
+			if err != nil {
+				return "", err
+			}
+			_, err = w.Write(data)
+			return zipOut + ":" + name, err
+		}
+	}
+
+	var results []Result
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+
+		entryPath := zipPath + ":" + entry.Name
+		if excluded(entry.Name, config.excludes) {
+			logInfo("Exclude", entryPath)
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			results = append(results, Result{Path: entryPath, Err: err})
+			continue
+		}
+
+		data, err := readZipEntry(entry)
+		if err != nil {
+			results = append(results, Result{Path: entryPath, Err: ioError(err)})
+			continue
+		}
+
+		// 画像として読めないエントリは飛ばす
+		if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+			continue
+		}
+
+		outputs, err := frameZipEntry(data, entry.Name, write, config)
+		results = append(results, Result{Path: entryPath, Outputs: outputs, Err: err})
+	}
+
+	if zw != nil {
+		err = zw.Close()
+		if err != nil {
+			return results, ioError(fmt.Errorf("writing zip: %w", err))
+		}
+	}
+
+	return results, nil
+}
+
+func readZipEntry(entry *zip.File) ([]byte, error) {
+	r, err := entry.Open()
+	if err != nil {
+		return nil, fmt.Errorf("opening entry: %w", err)
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// zipの1エントリ分の処理 (出力のエントリ名は exiframe-* で、ディレクトリはそのまま)
+func frameZipEntry(data []byte, name string, write func(string, []byte) (string, error), config *Config) ([]string, error) {
+	c := *config
+	c.filePath = name
+	c.fileName = path.Base(name)
+
+	exifData, err := getExif(data, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkMinRating(exifData, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	dst, _, err := composeFrame(data, &c, exifData)
+	if err != nil {
+		return nil, err
+	}
+
+	formats := c.formats
+	if len(formats) == 0 {
+		formats = []string{DEFAULT_OUTPUT_FORMAT}
+	}
+
+	var outputs []string
+	for _, format := range formats {
+		var buf bytes.Buffer
+		err := encodeFormat(&buf, dst, format, &c)
+		if err != nil {
+			return outputs, ioError(fmt.Errorf("%s: %w", format, err))
+		}
+
+		output, err := write(path.Join(path.Dir(name), outputPath(c.fileName, format)), buf.Bytes())
+		if err != nil {
+			return outputs, ioError(fmt.Errorf("%s: writing output: %w", format, err))
+		}
+		outputs = append(outputs, output)
+	}
+
+	return outputs, nil
+}